@@ -1,6 +1,8 @@
 package auction
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
@@ -8,65 +10,564 @@ import (
 
 const AuctionDuration = 100 * time.Second
 
+// SchedulerInterval is how often the primary checks whether the auction
+// is due to open or close.
+const SchedulerInterval = 100 * time.Millisecond
+
+// MaxAmount caps what a bid commits in total, its amount per unit times its
+// quantity, in minor units. It keeps totals, and credit exposures summed
+// over auctions, well clear of overflowing int64.
+const MaxAmount int64 = 1_000_000_000_000_000
+
+// BidTotal is what a bid of amount per unit for quantity units commits,
+// and whether that is positive and within MaxAmount.
+func BidTotal(amount int64, quantity int32) (int64, bool) {
+	if amount <= 0 || quantity <= 0 || amount > MaxAmount/int64(quantity) {
+		return 0, false
+	}
+	return amount * int64(quantity), true
+}
+
+// TransitionRequestID is the fixed request ID under which a scheduled OPEN
+// or CLOSE of the auction is replicated.
+func TransitionRequestID(updateType pb.UpdateType) string {
+	return fmt.Sprintf("%s-%s", DefaultAuctionID, strings.ToLower(updateType.String()))
+}
+
+// Config describes the lot being sold and how winners are charged.
+//
+// BuyNowPrice, when positive, lets a bidder take the whole lot at that
+// price per unit and end the auction immediately. The offer is withdrawn
+// once the highest bid reaches BuyNowThreshold, or after the first bid
+// when no threshold is set, even if that bid is later retracted. While the
+// offer stands, a bid at or above BuyNowPrice for the whole lot buys it and
+// a bid at that price for part of the lot is refused.
+//
+// All amounts are in minor units (e.g. cents) of Currency, an ISO 4217 code.
+type Config struct {
+	Quantity        int32
+	Pricing         pb.PricingRule
+	Currency        string
+	BuyNowPrice     int64
+	BuyNowThreshold int64
+	Retraction      RetractionPolicy
+	TiePolicy       pb.TiePolicy
+	// Rules is the auction format. Nil means English rules built from the
+	// fields above.
+	Rules AuctionRules
+}
+
+// RetractionPolicy limits when a bidder may withdraw a bid. The zero value
+// lets bidders retract their latest bid at any time before the close.
+type RetractionPolicy struct {
+	// FinalPeriod refuses retractions this close to the end of the auction.
+	FinalPeriod time.Duration
+	// LatestOnly only allows retracting the most recent bid in the auction.
+	LatestOnly bool
+	// AdminOnly only allows admins to retract bids.
+	AdminOnly bool
+}
+
+const DefaultCurrency = "USD"
+
+// DefaultConfig is a single item sold to the highest bidder.
+func DefaultConfig() Config {
+	return Config{Quantity: 1, Pricing: pb.PricingRule_UNIFORM_PRICE, Currency: DefaultCurrency}
+}
+
+// ParseCurrency normalizes an ISO 4217 currency code such as "usd".
+func ParseCurrency(s string) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(s))
+	if len(code) != 3 {
+		return "", fmt.Errorf("invalid currency code %q (want three letters, e.g. USD)", s)
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", fmt.Errorf("invalid currency code %q (want three letters, e.g. USD)", s)
+		}
+	}
+	return code, nil
+}
+
+// zeroDecimalCurrencies have no minor unit, so amounts are whole units.
+var zeroDecimalCurrencies = map[string]bool{
+	"CLP": true, "ISK": true, "JPY": true, "KRW": true, "VND": true,
+}
+
+// FormatAmount renders an amount in minor units for display, e.g.
+// 12345 USD as "123.45 USD".
+func FormatAmount(amount int64, currency string) string {
+	if zeroDecimalCurrencies[currency] {
+		return fmt.Sprintf("%d %s", amount, currency)
+	}
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, amount/100, amount%100, currency)
+}
+
+// ParseTiePolicy maps a flag value to a tie policy.
+func ParseTiePolicy(s string) (pb.TiePolicy, error) {
+	switch s {
+	case "reject-equal":
+		return pb.TiePolicy_REJECT_EQUAL, nil
+	case "earliest-wins":
+		return pb.TiePolicy_EARLIEST_WINS, nil
+	default:
+		return 0, fmt.Errorf("unknown tie policy %q (want reject-equal or earliest-wins)", s)
+	}
+}
+
+// Stamp is the replication sequence number and timestamp the primary
+// assigns to an update. Replicas apply updates at the primary's time
+// rather than their own, so they all reach the same decisions.
+type Stamp struct {
+	Sequence uint64
+	Time     time.Time
+}
+
+// ParseStartTime parses an RFC 3339 start time. Primary and backup must be
+// given the same start time, so there is no default: each node's own
+// startup time would differ.
+func ParseStartTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("a start time is required and must be the same on primary and backup (RFC 3339, e.g. 2024-06-01T12:00:00Z)")
+	}
+	startTime, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start time %q (want RFC 3339, e.g. 2024-06-01T12:00:00Z)", s)
+	}
+	return startTime, nil
+}
+
+// ParsePricing maps a flag value to a pricing rule.
+func ParsePricing(s string) (pb.PricingRule, error) {
+	switch s {
+	case "uniform":
+		return pb.PricingRule_UNIFORM_PRICE, nil
+	case "pay-as-bid":
+		return pb.PricingRule_PAY_AS_BID, nil
+	default:
+		return 0, fmt.Errorf("unknown pricing rule %q (want uniform or pay-as-bid)", s)
+	}
+}
+
+// historyEntry is one accepted bid in the bid history.
+type historyEntry struct {
+	Bid
+	retracted bool
+	buyNow    bool
+}
+
 type Auction struct {
-	highestBid    int32
-	highestBidder string
-	bidders       map[string]int32
-	startTime     time.Time
-	closed        bool
+	highestBid      int64
+	highestBidder   string
+	rules           AuctionRules
+	standing        []Bid
+	history         []*historyEntry
+	quantity        int32
+	pricing         pb.PricingRule
+	currency        string
+	buyNowPrice     int64
+	buyNowThreshold int64
+	retraction      RetractionPolicy
+	buyer           string
+	bidPlaced       bool
+	startTime       time.Time
+	status          pb.AuctionStatus
+	settlement      *AuctionSettled
 }
 
-func NewAuction(startTime time.Time) *Auction {
+func NewAuction(startTime time.Time, config Config) *Auction {
+	if config.Quantity <= 0 {
+		config.Quantity = 1
+	}
+	if config.Currency == "" {
+		config.Currency = DefaultCurrency
+	}
+	if config.Rules == nil {
+		config.Rules = NewEnglishRules(config)
+	}
 	return &Auction{
-		highestBid:    0,
-		highestBidder: "",
-		bidders:       make(map[string]int32),
-		startTime:     startTime,
-		closed:        false,
+		highestBid:      0,
+		highestBidder:   "",
+		rules:           config.Rules,
+		quantity:        config.Quantity,
+		pricing:         config.Pricing,
+		currency:        config.Currency,
+		buyNowPrice:     config.BuyNowPrice,
+		buyNowThreshold: config.BuyNowThreshold,
+		retraction:      config.Retraction,
+		startTime:       startTime,
+		status:          pb.AuctionStatus_SCHEDULED,
 	}
 }
 
-func (a *Auction) PlaceBid(clientID string, amount int32, currentTime time.Time) pb.Outcome {
-	if a.IsClosed(currentTime) {
-		return pb.Outcome_FAIL
+// PlaceBid records a bid of amount per unit for quantity units. A quantity
+// of zero means a single unit and an empty currency means the auction's
+// own, so single-item clients that predate currencies keep working.
+//
+// Whether the bid stands against the others is up to the auction rules.
+//
+// Rejected bids come with the reason they were rejected.
+func (a *Auction) PlaceBid(clientID string, amount int64, quantity int32, currency string, stamp Stamp) (pb.Outcome, pb.RejectReason) {
+	if a.IsClosed(stamp.Time) {
+		return pb.Outcome_FAIL, a.closedReason()
+	}
+
+	if stamp.Time.Before(a.startTime) {
+		return pb.Outcome_FAIL, pb.RejectReason_AUCTION_NOT_STARTED
+	}
+
+	if quantity == 0 {
+		quantity = 1
 	}
 
 	if amount <= 0 {
-		return pb.Outcome_EXCEPTION
+		return pb.Outcome_EXCEPTION, pb.RejectReason_INVALID_AMOUNT
 	}
 
-	if amount <= a.highestBid {
-		return pb.Outcome_FAIL
+	if quantity < 0 || quantity > a.quantity {
+		return pb.Outcome_EXCEPTION, pb.RejectReason_INVALID_QUANTITY
 	}
 
-	previousBid, exists := a.bidders[clientID]
-	if exists && amount <= previousBid {
-		return pb.Outcome_FAIL
+	if _, ok := BidTotal(amount, quantity); !ok {
+		return pb.Outcome_EXCEPTION, pb.RejectReason_INVALID_AMOUNT
 	}
 
-	a.bidders[clientID] = amount
-	a.highestBid = amount
-	a.highestBidder = clientID
+	if currency != "" && !strings.EqualFold(currency, a.currency) {
+		return pb.Outcome_EXCEPTION, pb.RejectReason_CURRENCY_MISMATCH
+	}
 
-	return pb.Outcome_SUCCESS
+	if a.BuyNowAvailable() && amount >= a.buyNowPrice {
+		// Buy-now sells only the whole lot
+		if quantity != a.quantity {
+			return pb.Outcome_FAIL, pb.RejectReason_BUY_NOW_UNAVAILABLE
+		}
+		return a.BuyNow(clientID, stamp)
+	}
+
+	bid := Bid{Bidder: clientID, Amount: amount, Quantity: quantity, Stamp: stamp}
+	if outcome, reason := a.rules.ValidateBid(bid, a.standing); outcome != pb.Outcome_SUCCESS {
+		return outcome, reason
+	}
+
+	a.history = append(a.history, &historyEntry{Bid: bid})
+	a.standing = a.rules.ApplyBid(bid, a.standing)
+	a.bidPlaced = true
+	a.updateLeader()
+
+	return pb.Outcome_SUCCESS, pb.RejectReason_REASON_NONE
 }
 
-func (a *Auction) GetResult(currentTime time.Time) (pb.AuctionStatus, int32, string) {
+// RetractBid withdraws the latest bid of clientID, subject to the
+// retraction policy. The bidder's previous bid, if any, stands again and
+// the leader is recomputed from the remaining history.
+func (a *Auction) RetractBid(clientID string, byAdmin bool, currentTime time.Time) (pb.Outcome, pb.RejectReason) {
 	if a.IsClosed(currentTime) {
-		return pb.AuctionStatus_CLOSED, a.highestBid, a.highestBidder
+		return pb.Outcome_FAIL, a.closedReason()
+	}
+
+	if a.retraction.AdminOnly && !byAdmin {
+		return pb.Outcome_FAIL, pb.RejectReason_RETRACTION_NOT_ALLOWED
+	}
+
+	if a.retraction.FinalPeriod > 0 && a.EndTime().Sub(currentTime) < a.retraction.FinalPeriod {
+		return pb.Outcome_FAIL, pb.RejectReason_RETRACTION_NOT_ALLOWED
+	}
+
+	bid := a.latestBidOf(clientID)
+	if bid == nil {
+		return pb.Outcome_FAIL, pb.RejectReason_NO_BID_TO_RETRACT
+	}
+
+	if a.retraction.LatestOnly && bid != a.latestBid() {
+		return pb.Outcome_FAIL, pb.RejectReason_RETRACTION_NOT_ALLOWED
+	}
+
+	bid.retracted = true
+	a.rebuildStandingBids()
+
+	return pb.Outcome_SUCCESS, pb.RejectReason_REASON_NONE
+}
+
+// latestBid is the most recent bid that has not been retracted.
+func (a *Auction) latestBid() *historyEntry {
+	return a.latestBidOf("")
+}
+
+// latestBidOf is the most recent unretracted bid of clientID, or of anyone
+// if clientID is empty.
+func (a *Auction) latestBidOf(clientID string) *historyEntry {
+	for i := len(a.history) - 1; i >= 0; i-- {
+		bid := a.history[i]
+		if !bid.retracted && !bid.buyNow && (clientID == "" || bid.Bidder == clientID) {
+			return bid
+		}
+	}
+	return nil
+}
+
+// rebuildStandingBids replays the unretracted history through the rules.
+func (a *Auction) rebuildStandingBids() {
+	a.standing = nil
+	for _, bid := range a.history {
+		if !bid.retracted && !bid.buyNow {
+			a.standing = a.rules.ApplyBid(bid.Bid, a.standing)
+		}
+	}
+	a.updateLeader()
+}
+
+func (a *Auction) updateLeader() {
+	allocations := a.rules.Allocations(a.standing)
+	if len(allocations) == 0 {
+		a.highestBid = 0
+		a.highestBidder = ""
+		return
+	}
+	a.highestBid = allocations[0].BidPrice
+	a.highestBidder = allocations[0].Bidder
+}
+
+// BuyNowAvailable reports whether the buy-now offer still stands.
+func (a *Auction) BuyNowAvailable() bool {
+	if a.buyNowPrice <= 0 || a.ended() {
+		return false
+	}
+	if a.buyNowThreshold > 0 {
+		return a.highestBid < a.buyNowThreshold
+	}
+	return !a.bidPlaced
+}
+
+// TriggersBuyNow reports whether a bid of amount per unit for quantity
+// units meets the buy-now price for the whole lot and should be handled as
+// a purchase rather than a regular bid. A quantity of zero means a single
+// unit, as in PlaceBid.
+func (a *Auction) TriggersBuyNow(amount int64, quantity int32) bool {
+	if quantity == 0 {
+		quantity = 1
+	}
+	return a.BuyNowAvailable() && amount >= a.buyNowPrice && quantity == a.quantity
+}
+
+// BuyNow sells the whole lot to clientID at the buy-now price and closes
+// the auction.
+func (a *Auction) BuyNow(clientID string, stamp Stamp) (pb.Outcome, pb.RejectReason) {
+	if a.IsClosed(stamp.Time) {
+		return pb.Outcome_FAIL, a.closedReason()
+	}
+
+	if stamp.Time.Before(a.startTime) {
+		return pb.Outcome_FAIL, pb.RejectReason_AUCTION_NOT_STARTED
+	}
+
+	if !a.BuyNowAvailable() {
+		return pb.Outcome_FAIL, pb.RejectReason_BUY_NOW_UNAVAILABLE
+	}
+
+	if _, ok := BidTotal(a.buyNowPrice, a.quantity); !ok {
+		return pb.Outcome_EXCEPTION, pb.RejectReason_INVALID_AMOUNT
+	}
+
+	a.history = append(a.history, &historyEntry{
+		Bid:    Bid{Bidder: clientID, Amount: a.buyNowPrice, Quantity: a.quantity, Stamp: stamp},
+		buyNow: true,
+	})
+	a.buyer = clientID
+	a.highestBid = a.buyNowPrice
+	a.highestBidder = clientID
+	a.status = pb.AuctionStatus_CLOSED
+
+	return pb.Outcome_SUCCESS, pb.RejectReason_REASON_NONE
+}
+
+// BuyNowPrice is the current buy-now offer, or zero if there is none.
+func (a *Auction) BuyNowPrice() int64 {
+	if !a.BuyNowAvailable() {
+		return 0
+	}
+	return a.buyNowPrice
+}
+
+// Buyer is the bidder who ended the auction with buy-now, if any.
+func (a *Auction) Buyer() string {
+	return a.buyer
+}
+
+// Allocations assigns the lot according to the auction rules. A buy-now
+// purchase takes the whole lot and a cancelled auction has no winners.
+func (a *Auction) Allocations() []*pb.Allocation {
+	if a.status == pb.AuctionStatus_CANCELLED {
+		return nil
+	}
+
+	if a.buyer != "" {
+		purchase := a.history[len(a.history)-1]
+		return []*pb.Allocation{{
+			Bidder:    a.buyer,
+			Quantity:  a.quantity,
+			BidPrice:  a.buyNowPrice,
+			Price:     a.buyNowPrice,
+			Sequence:  purchase.Stamp.Sequence,
+			Timestamp: purchase.Stamp.Time.UnixNano(),
+		}}
+	}
+
+	return a.rules.Allocations(a.standing)
+}
+
+// History lists every accepted bid in the order it was placed, including
+// retracted ones.
+func (a *Auction) History() []*pb.BidRecord {
+	records := make([]*pb.BidRecord, 0, len(a.history))
+	for _, bid := range a.history {
+		records = append(records, &pb.BidRecord{
+			Sequence:  bid.Stamp.Sequence,
+			Timestamp: bid.Stamp.Time.UnixNano(),
+			Bidder:    bid.Bidder,
+			Amount:    bid.Amount,
+			Quantity:  bid.Quantity,
+			Retracted: bid.retracted,
+			BuyNow:    bid.buyNow,
+		})
+	}
+	return records
+}
+
+// HighestBid is the best standing price per unit.
+func (a *Auction) HighestBid() int64 {
+	return a.highestBid
+}
+
+func (a *Auction) Quantity() int32 {
+	return a.quantity
+}
+
+func (a *Auction) Pricing() pb.PricingRule {
+	return a.pricing
+}
+
+func (a *Auction) Currency() string {
+	return a.currency
+}
+
+// GetResult reports the status at currentTime with the leading bid. A
+// cancelled auction has no leader.
+func (a *Auction) GetResult(currentTime time.Time) (pb.AuctionStatus, int64, string) {
+	status := a.Status(currentTime)
+	if status == pb.AuctionStatus_CANCELLED {
+		return status, 0, ""
+	}
+	return status, a.highestBid, a.highestBidder
+}
+
+// Status is the auction's status at currentTime. Bids are judged by their
+// own timestamps, so an auction counts as open or closed from its start or
+// end time even before the scheduler has recorded the transition.
+func (a *Auction) Status(currentTime time.Time) pb.AuctionStatus {
+	switch {
+	case a.ended():
+		return a.status
+	case !currentTime.Before(a.EndTime()):
+		return pb.AuctionStatus_CLOSED
+	case a.status == pb.AuctionStatus_ONGOING || !currentTime.Before(a.startTime):
+		return pb.AuctionStatus_ONGOING
+	default:
+		return pb.AuctionStatus_SCHEDULED
 	}
-	return pb.AuctionStatus_ONGOING, a.highestBid, a.highestBidder
 }
 
 func (a *Auction) IsClosed(currentTime time.Time) bool {
-	if a.closed {
-		return true
+	status := a.Status(currentTime)
+	return status == pb.AuctionStatus_CLOSED || status == pb.AuctionStatus_CANCELLED
+}
+
+// DueTransition returns the scheduled transition, OPEN, CLOSE or SETTLE,
+// that is due at currentTime but has not been recorded yet. A closed
+// auction is due for settlement however it closed.
+func (a *Auction) DueTransition(currentTime time.Time) (pb.UpdateType, bool) {
+	switch {
+	case a.status == pb.AuctionStatus_SCHEDULED && !currentTime.Before(a.startTime):
+		return pb.UpdateType_OPEN, true
+	case a.status == pb.AuctionStatus_ONGOING && !currentTime.Before(a.EndTime()):
+		return pb.UpdateType_CLOSE, true
+	case a.status == pb.AuctionStatus_CLOSED && a.settlement == nil:
+		return pb.UpdateType_SETTLE, true
+	}
+	return pb.UpdateType_BID, false
+}
+
+// Open records that a scheduled auction has opened. It reports whether
+// the auction was still scheduled, so replaying it is harmless.
+func (a *Auction) Open() bool {
+	if a.status != pb.AuctionStatus_SCHEDULED {
+		return false
+	}
+	a.status = pb.AuctionStatus_ONGOING
+	return true
+}
+
+// Close records that the auction has closed. Like Open it only changes an
+// auction that has not ended yet.
+func (a *Auction) Close() bool {
+	if a.ended() {
+		return false
+	}
+	a.status = pb.AuctionStatus_CLOSED
+	return true
+}
+
+// Settle records that the closed auction has been settled at settledAt,
+// with its settlement event. It reports whether it was closed and not
+// settled yet.
+func (a *Auction) Settle(settledAt time.Time) bool {
+	if a.status != pb.AuctionStatus_CLOSED || a.settlement != nil {
+		return false
 	}
+	event := a.Settlement(settledAt)
+	a.settlement = &event
+	return true
+}
+
+// Settled returns the settlement event Settle recorded, if any.
+func (a *Auction) Settled() (AuctionSettled, bool) {
+	if a.settlement == nil {
+		return AuctionSettled{}, false
+	}
+	return *a.settlement, true
+}
 
-	if currentTime.Sub(a.startTime) >= AuctionDuration {
-		a.closed = true
-		return true
+// Cancel calls the auction off before it ends. Nobody wins a cancelled
+// auction, so every bidder's credit is freed.
+func (a *Auction) Cancel(currentTime time.Time) (pb.Outcome, pb.RejectReason) {
+	if a.IsClosed(currentTime) {
+		return pb.Outcome_FAIL, a.closedReason()
 	}
+	a.status = pb.AuctionStatus_CANCELLED
+	return pb.Outcome_SUCCESS, pb.RejectReason_REASON_NONE
+}
 
-	return false
+func (a *Auction) StartTime() time.Time {
+	return a.startTime
+}
+
+// EndTime is when the auction rules close the auction.
+func (a *Auction) EndTime() time.Time {
+	return a.rules.EndTime(a.startTime, a.standing)
+}
+
+// ended reports whether a close or cancellation has been recorded.
+func (a *Auction) ended() bool {
+	return a.status == pb.AuctionStatus_CLOSED || a.status == pb.AuctionStatus_CANCELLED
+}
+
+func (a *Auction) closedReason() pb.RejectReason {
+	if a.status == pb.AuctionStatus_CANCELLED {
+		return pb.RejectReason_AUCTION_CANCELLED
+	}
+	return pb.RejectReason_AUCTION_CLOSED
 }
@@ -0,0 +1,94 @@
+package auction
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// at is the stamp of the nth update, n seconds into the auction.
+func at(n int) Stamp {
+	return Stamp{Sequence: uint64(n), Time: start.Add(time.Duration(n) * time.Second)}
+}
+
+func TestBuyNowTakesOnlyTheWholeLot(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity int32
+		want     pb.RejectReason
+	}{
+		{"part of the lot", 2, pb.RejectReason_BUY_NOW_UNAVAILABLE},
+		{"whole lot", 3, pb.RejectReason_REASON_NONE},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := NewAuction(start, Config{Quantity: 3, BuyNowPrice: 500})
+			a.Open()
+			if got := a.TriggersBuyNow(600, test.quantity); got != (test.want == pb.RejectReason_REASON_NONE) {
+				t.Errorf("TriggersBuyNow = %v", got)
+			}
+			if _, reason := a.PlaceBid("alice", 600, test.quantity, "", at(1)); reason != test.want {
+				t.Fatalf("bid for %d units at the buy-now price: %v, want %v", test.quantity, reason, test.want)
+			}
+			if test.want != pb.RejectReason_REASON_NONE {
+				if !a.BuyNowAvailable() || len(a.History()) != 0 {
+					t.Error("a refused bid changed the auction")
+				}
+				return
+			}
+			if a.Buyer() != "alice" || !a.IsClosed(at(2).Time) {
+				t.Errorf("buyer %q, closed %v, want alice to have bought the lot", a.Buyer(), a.IsClosed(at(2).Time))
+			}
+			allocations := a.Allocations()
+			if len(allocations) != 1 || allocations[0].Quantity != 3 || allocations[0].Price != 500 {
+				t.Errorf("allocations = %v, want the whole lot to alice at 500", allocations)
+			}
+		})
+	}
+}
+
+func TestBuyNowWithdrawnForGoodByTheFirstBid(t *testing.T) {
+	a := NewAuction(start, Config{BuyNowPrice: 500})
+	a.Open()
+	if !a.BuyNowAvailable() {
+		t.Fatal("buy-now unavailable before any bid")
+	}
+
+	if _, reason := a.PlaceBid("alice", 100, 1, "", at(1)); reason != pb.RejectReason_REASON_NONE {
+		t.Fatal(reason)
+	}
+	if _, reason := a.RetractBid("alice", false, at(2).Time); reason != pb.RejectReason_REASON_NONE {
+		t.Fatal(reason)
+	}
+	if a.BuyNowAvailable() || a.BuyNowPrice() != 0 {
+		t.Error("buy-now offered again after the only bid was retracted")
+	}
+	if _, reason := a.BuyNow("bob", at(3)); reason != pb.RejectReason_BUY_NOW_UNAVAILABLE {
+		t.Errorf("BuyNow = %v, want BUY_NOW_UNAVAILABLE", reason)
+	}
+	// A bid at the buy-now price is now an ordinary bid
+	if _, reason := a.PlaceBid("bob", 600, 1, "", at(4)); reason != pb.RejectReason_REASON_NONE || a.Buyer() != "" {
+		t.Errorf("bid at the buy-now price: %v, buyer %q, want an ordinary bid", reason, a.Buyer())
+	}
+}
+
+func TestBuyNowThreshold(t *testing.T) {
+	a := NewAuction(start, Config{BuyNowPrice: 500, BuyNowThreshold: 300})
+	a.Open()
+
+	if _, reason := a.PlaceBid("alice", 200, 1, "", at(1)); reason != pb.RejectReason_REASON_NONE {
+		t.Fatal(reason)
+	}
+	if !a.BuyNowAvailable() {
+		t.Error("buy-now withdrawn below the threshold")
+	}
+	if _, reason := a.PlaceBid("bob", 300, 1, "", at(2)); reason != pb.RejectReason_REASON_NONE {
+		t.Fatal(reason)
+	}
+	if a.BuyNowAvailable() {
+		t.Error("buy-now still offered with the highest bid at the threshold")
+	}
+}
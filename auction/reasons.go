@@ -0,0 +1,73 @@
+package auction
+
+import (
+	"errors"
+
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+)
+
+// ReasonMessage describes a rejection reason in words. Servers put it in
+// BidResponse.message and clients can use it to explain a reason code.
+func ReasonMessage(reason pb.RejectReason) string {
+	switch reason {
+	case pb.RejectReason_REASON_NONE:
+		return "ok"
+	case pb.RejectReason_BELOW_HIGHEST:
+		return "bid rejected - not above the current highest bid"
+	case pb.RejectReason_BELOW_OWN_PREVIOUS:
+		return "bid rejected - not above your previous bid"
+	case pb.RejectReason_AUCTION_CLOSED:
+		return "auction closed"
+	case pb.RejectReason_AUCTION_NOT_STARTED:
+		return "auction has not started yet"
+	case pb.RejectReason_INVALID_AMOUNT:
+		return "invalid bid amount"
+	case pb.RejectReason_INVALID_QUANTITY:
+		return "invalid quantity - must be between 1 and the lot size"
+	case pb.RejectReason_CURRENCY_MISMATCH:
+		return "bid currency does not match the auction currency"
+	case pb.RejectReason_NOT_PRIMARY:
+		return "requests must be directed to primary"
+	case pb.RejectReason_REPLICATION_FAILED:
+		return "replication failed"
+	case pb.RejectReason_NOT_REGISTERED:
+		return ErrNotRegistered.Error()
+	case pb.RejectReason_NOT_APPROVED:
+		return ErrNotApproved.Error()
+	case pb.RejectReason_CREDIT_LIMIT_EXCEEDED:
+		return "credit limit exceeded"
+	case pb.RejectReason_ALREADY_REGISTERED:
+		return "bidder already registered"
+	case pb.RejectReason_INVALID_REGISTRATION:
+		return "registration needs a client ID"
+	case pb.RejectReason_BUY_NOW_UNAVAILABLE:
+		return "buy-now unavailable - there is no buy-now price, bidding passed the threshold or the bid is for part of the lot"
+	case pb.RejectReason_NO_BID_TO_RETRACT:
+		return "no bid to retract"
+	case pb.RejectReason_RETRACTION_NOT_ALLOWED:
+		return "retraction not allowed by policy"
+	case pb.RejectReason_NOT_AUTHORIZED:
+		return "not authorized"
+	case pb.RejectReason_AUCTION_CANCELLED:
+		return "auction cancelled"
+	default:
+		return "unknown reason"
+	}
+}
+
+// RejectReasonOf maps an error from Registry.CheckBid to its reason code.
+func RejectReasonOf(err error) pb.RejectReason {
+	var creditErr *CreditLimitError
+	switch {
+	case err == nil:
+		return pb.RejectReason_REASON_NONE
+	case errors.Is(err, ErrNotRegistered):
+		return pb.RejectReason_NOT_REGISTERED
+	case errors.Is(err, ErrNotApproved):
+		return pb.RejectReason_NOT_APPROVED
+	case errors.As(err, &creditErr):
+		return pb.RejectReason_CREDIT_LIMIT_EXCEEDED
+	default:
+		return pb.RejectReason_NOT_AUTHORIZED
+	}
+}
@@ -0,0 +1,154 @@
+package auction
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+)
+
+// DefaultAuctionID identifies the auction a server runs when no other
+// auction is named.
+const DefaultAuctionID = "default"
+
+var (
+	ErrNotRegistered = errors.New("bidder not registered")
+	ErrNotApproved   = errors.New("bidder registered but not yet approved")
+)
+
+// CreditLimitError reports a bid that would push a bidder's outstanding
+// winning bids over their credit limit.
+type CreditLimitError struct {
+	Outstanding int64
+	Limit       int64
+}
+
+func (e *CreditLimitError) Error() string {
+	return fmt.Sprintf("credit limit exceeded - bid would bring outstanding winning bids to %d of a %d limit", e.Outstanding, e.Limit)
+}
+
+// Bidder is a registered bidder. CreditLimit, or the deposit they lodged,
+// caps the total of their winning bids across all auctions; zero means no
+// limit.
+type Bidder struct {
+	ID          string
+	CreditLimit int64
+	Approved    bool
+}
+
+// Registry tracks registered bidders and what they stand to pay in every
+// auction they are currently winning.
+type Registry struct {
+	bidders             map[string]*Bidder
+	exposures           map[string]map[string]int64 // auction ID -> bidder -> winning total
+	requireRegistration bool
+	requireApproval     bool
+	defaultCreditLimit  int64
+}
+
+// NewRegistry returns an empty registry. Bidders register with
+// defaultCreditLimit, zero meaning no limit, until an admin sets theirs.
+func NewRegistry(requireRegistration, requireApproval bool, defaultCreditLimit int64) *Registry {
+	return &Registry{
+		bidders:             make(map[string]*Bidder),
+		exposures:           make(map[string]map[string]int64),
+		requireRegistration: requireRegistration,
+		requireApproval:     requireApproval,
+		defaultCreditLimit:  defaultCreditLimit,
+	}
+}
+
+// DefaultCreditLimit is the credit limit bidders register with.
+func (r *Registry) DefaultCreditLimit() int64 {
+	return r.defaultCreditLimit
+}
+
+// Register adds a bidder with the given credit limit. Bidders are approved
+// straight away unless the registry requires approval.
+func (r *Registry) Register(clientID string, creditLimit int64) (pb.Outcome, pb.RejectReason) {
+	if clientID == "" || creditLimit < 0 {
+		return pb.Outcome_EXCEPTION, pb.RejectReason_INVALID_REGISTRATION
+	}
+
+	if _, exists := r.bidders[clientID]; exists {
+		return pb.Outcome_FAIL, pb.RejectReason_ALREADY_REGISTERED
+	}
+
+	r.bidders[clientID] = &Bidder{
+		ID:          clientID,
+		CreditLimit: creditLimit,
+		Approved:    !r.requireApproval,
+	}
+
+	return pb.Outcome_SUCCESS, pb.RejectReason_REASON_NONE
+}
+
+// Approve lets a registered bidder start bidding, with creditLimit in place
+// of the one they registered with unless it is zero.
+func (r *Registry) Approve(clientID string, creditLimit int64) (pb.Outcome, pb.RejectReason) {
+	if creditLimit < 0 {
+		return pb.Outcome_EXCEPTION, pb.RejectReason_INVALID_REGISTRATION
+	}
+
+	bidder, exists := r.bidders[clientID]
+	if !exists {
+		return pb.Outcome_FAIL, pb.RejectReason_NOT_REGISTERED
+	}
+
+	bidder.Approved = true
+	if creditLimit > 0 {
+		bidder.CreditLimit = creditLimit
+	}
+
+	return pb.Outcome_SUCCESS, pb.RejectReason_REASON_NONE
+}
+
+// CheckBid decides whether clientID may commit amount in auctionID, on top
+// of what they are already winning elsewhere. Unregistered bidders are only
+// turned away when registration is required.
+func (r *Registry) CheckBid(clientID string, auctionID string, amount int64) error {
+	bidder, exists := r.bidders[clientID]
+	if !exists {
+		if r.requireRegistration {
+			return ErrNotRegistered
+		}
+		return nil
+	}
+
+	if !bidder.Approved {
+		return ErrNotApproved
+	}
+
+	outstanding := amount
+	for id, exposures := range r.exposures {
+		if id != auctionID {
+			outstanding += exposures[clientID]
+		}
+	}
+
+	if bidder.CreditLimit > 0 && outstanding > bidder.CreditLimit {
+		return &CreditLimitError{Outstanding: outstanding, Limit: bidder.CreditLimit}
+	}
+
+	return nil
+}
+
+// UpdateExposures records the current winners of auctionID. It must be
+// called after every change to the auction, since being outbid frees up
+// a bidder's credit.
+func (r *Registry) UpdateExposures(auctionID string, allocations []*pb.Allocation) {
+	exposures := make(map[string]int64)
+	for _, allocation := range allocations {
+		exposures[allocation.Bidder] += allocation.BidPrice * int64(allocation.Quantity)
+	}
+	r.exposures[auctionID] = exposures
+}
+
+// Bidder looks up a registered bidder.
+func (r *Registry) Bidder(clientID string) (Bidder, bool) {
+	bidder, exists := r.bidders[clientID]
+	if !exists {
+		return Bidder{}, false
+	}
+	return *bidder, true
+}
@@ -0,0 +1,153 @@
+package auction
+
+import (
+	"sort"
+	"time"
+
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+)
+
+// Bid is an accepted or proposed bid as auction rules see it: amount per
+// unit for quantity units, stamped by the primary.
+type Bid struct {
+	Bidder   string
+	Amount   int64
+	Quantity int32
+	Stamp    Stamp
+}
+
+// AuctionRules is an auction format: which bids it accepts, how they stand
+// and who wins. Standing bids are passed in the order they were placed.
+//
+// Rules must be deterministic, since primary and backup apply the same
+// bids independently. Everything that does not depend on the format (the
+// schedule, currency, lot size, buy-now, retraction and the bid history)
+// is handled by Auction, so a new format only has to implement this.
+type AuctionRules interface {
+	// ValidateBid accepts or rejects bid against the standing bids.
+	ValidateBid(bid Bid, standing []Bid) (pb.Outcome, pb.RejectReason)
+	// ApplyBid returns the standing bids once bid has been accepted.
+	ApplyBid(bid Bid, standing []Bid) []Bid
+	// Allocations assigns the lot to the standing bids, leader first.
+	Allocations(standing []Bid) []*pb.Allocation
+	// EndTime is when the auction closes, given when it started and the
+	// standing bids.
+	EndTime(startTime time.Time, standing []Bid) time.Time
+}
+
+// EnglishRules is an ascending auction for a lot of Quantity units. Each
+// bidder has one standing bid, which they may only raise; a new bid must
+// beat the lowest bid that still wins a unit. Equal bids are accepted or
+// not according to TiePolicy and rank behind the earlier ones.
+type EnglishRules struct {
+	Quantity  int32
+	Pricing   pb.PricingRule
+	TiePolicy pb.TiePolicy
+	Duration  time.Duration
+}
+
+// NewEnglishRules builds the default rules for config.
+func NewEnglishRules(config Config) *EnglishRules {
+	quantity := config.Quantity
+	if quantity <= 0 {
+		quantity = 1
+	}
+	return &EnglishRules{
+		Quantity:  quantity,
+		Pricing:   config.Pricing,
+		TiePolicy: config.TiePolicy,
+		Duration:  AuctionDuration,
+	}
+}
+
+func (r *EnglishRules) ValidateBid(bid Bid, standing []Bid) (pb.Outcome, pb.RejectReason) {
+	priceToBeat := r.priceToBeat(bid.Bidder, standing)
+	if bid.Amount < priceToBeat || (bid.Amount == priceToBeat && r.TiePolicy == pb.TiePolicy_REJECT_EQUAL) {
+		return pb.Outcome_FAIL, pb.RejectReason_BELOW_HIGHEST
+	}
+
+	for _, previous := range standing {
+		if previous.Bidder == bid.Bidder && bid.Amount <= previous.Amount {
+			return pb.Outcome_FAIL, pb.RejectReason_BELOW_OWN_PREVIOUS
+		}
+	}
+
+	return pb.Outcome_SUCCESS, pb.RejectReason_REASON_NONE
+}
+
+// ApplyBid replaces the bidder's previous bid, if any.
+func (r *EnglishRules) ApplyBid(bid Bid, standing []Bid) []Bid {
+	next := make([]Bid, 0, len(standing)+1)
+	for _, previous := range standing {
+		if previous.Bidder != bid.Bidder {
+			next = append(next, previous)
+		}
+	}
+	return append(next, bid)
+}
+
+// Allocations assigns the lot to the top bids, unit by unit. Under uniform
+// pricing every winner pays the lowest winning bid; under pay-as-bid each
+// winner pays their own bid.
+func (r *EnglishRules) Allocations(standing []Bid) []*pb.Allocation {
+	var allocations []*pb.Allocation
+	remaining := r.Quantity
+	for _, b := range ranked(standing) {
+		if remaining == 0 {
+			break
+		}
+		units := b.Quantity
+		if units > remaining {
+			units = remaining
+		}
+		remaining -= units
+		allocations = append(allocations, &pb.Allocation{
+			Bidder:    b.Bidder,
+			Quantity:  units,
+			BidPrice:  b.Amount,
+			Price:     b.Amount,
+			Sequence:  b.Stamp.Sequence,
+			Timestamp: b.Stamp.Time.UnixNano(),
+		})
+	}
+
+	if r.Pricing == pb.PricingRule_UNIFORM_PRICE && len(allocations) > 0 {
+		clearingPrice := allocations[len(allocations)-1].BidPrice
+		for _, allocation := range allocations {
+			allocation.Price = clearingPrice
+		}
+	}
+
+	return allocations
+}
+
+// EndTime is a fixed Duration after the start.
+func (r *EnglishRules) EndTime(startTime time.Time, standing []Bid) time.Time {
+	return startTime.Add(r.Duration)
+}
+
+// priceToBeat is the lowest price that still wins a unit when the other
+// bidders already demand the whole lot, or zero if units are left over.
+func (r *EnglishRules) priceToBeat(clientID string, standing []Bid) int64 {
+	remaining := r.Quantity
+	for _, b := range ranked(standing) {
+		if b.Bidder == clientID {
+			continue
+		}
+		remaining -= b.Quantity
+		if remaining <= 0 {
+			return b.Amount
+		}
+	}
+	return 0
+}
+
+// ranked returns the standing bids from best to worst: highest price
+// first, earliest bid first among equal prices.
+func ranked(standing []Bid) []Bid {
+	ranked := append([]Bid(nil), standing...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Amount > ranked[j].Amount
+	})
+	return ranked
+}
@@ -0,0 +1,190 @@
+package auction
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+)
+
+func bids(standing ...Bid) []Bid {
+	return standing
+}
+
+func TestEnglishRulesPriceToBeat(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity int32
+		standing []Bid
+		bidder   string
+		want     int64
+	}{
+		{"no bids", 1, nil, "carol", 0},
+		{"single unit", 1, bids(Bid{Bidder: "alice", Amount: 100, Quantity: 1}), "carol", 100},
+		{"own bid does not count", 1, bids(Bid{Bidder: "alice", Amount: 100, Quantity: 1}), "alice", 0},
+		{
+			name:     "units left over",
+			quantity: 3,
+			standing: bids(Bid{Bidder: "alice", Amount: 100, Quantity: 1}, Bid{Bidder: "bob", Amount: 90, Quantity: 1}),
+			bidder:   "carol",
+			want:     0,
+		},
+		{
+			name:     "lowest bid that still wins",
+			quantity: 3,
+			standing: bids(Bid{Bidder: "alice", Amount: 100, Quantity: 2}, Bid{Bidder: "bob", Amount: 90, Quantity: 1}, Bid{Bidder: "dave", Amount: 80, Quantity: 1}),
+			bidder:   "carol",
+			want:     90,
+		},
+		{
+			name:     "the bidder's own units are free for them",
+			quantity: 3,
+			standing: bids(Bid{Bidder: "alice", Amount: 100, Quantity: 2}, Bid{Bidder: "bob", Amount: 90, Quantity: 1}, Bid{Bidder: "dave", Amount: 80, Quantity: 1}),
+			bidder:   "bob",
+			want:     80,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules := &EnglishRules{Quantity: test.quantity}
+			if got := rules.priceToBeat(test.bidder, test.standing); got != test.want {
+				t.Errorf("priceToBeat = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEnglishRulesValidateBid(t *testing.T) {
+	standing := bids(Bid{Bidder: "alice", Amount: 100, Quantity: 1})
+	tests := []struct {
+		name       string
+		tiePolicy  pb.TiePolicy
+		bid        Bid
+		wantReason pb.RejectReason
+	}{
+		{"above the highest", pb.TiePolicy_REJECT_EQUAL, Bid{Bidder: "bob", Amount: 101, Quantity: 1}, pb.RejectReason_REASON_NONE},
+		{"below the highest", pb.TiePolicy_REJECT_EQUAL, Bid{Bidder: "bob", Amount: 99, Quantity: 1}, pb.RejectReason_BELOW_HIGHEST},
+		{"equal, rejected", pb.TiePolicy_REJECT_EQUAL, Bid{Bidder: "bob", Amount: 100, Quantity: 1}, pb.RejectReason_BELOW_HIGHEST},
+		{"equal, accepted", pb.TiePolicy_EARLIEST_WINS, Bid{Bidder: "bob", Amount: 100, Quantity: 1}, pb.RejectReason_REASON_NONE},
+		{"raising the own bid", pb.TiePolicy_REJECT_EQUAL, Bid{Bidder: "alice", Amount: 101, Quantity: 1}, pb.RejectReason_REASON_NONE},
+		{"repeating the own bid", pb.TiePolicy_EARLIEST_WINS, Bid{Bidder: "alice", Amount: 100, Quantity: 1}, pb.RejectReason_BELOW_OWN_PREVIOUS},
+		{"lowering the own bid", pb.TiePolicy_REJECT_EQUAL, Bid{Bidder: "alice", Amount: 90, Quantity: 1}, pb.RejectReason_BELOW_OWN_PREVIOUS},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules := &EnglishRules{Quantity: 1, TiePolicy: test.tiePolicy}
+			wantOutcome := pb.Outcome_SUCCESS
+			if test.wantReason != pb.RejectReason_REASON_NONE {
+				wantOutcome = pb.Outcome_FAIL
+			}
+			outcome, reason := rules.ValidateBid(test.bid, standing)
+			if outcome != wantOutcome || reason != test.wantReason {
+				t.Errorf("ValidateBid = %v %v, want %v %v", outcome, reason, wantOutcome, test.wantReason)
+			}
+		})
+	}
+}
+
+// TestEnglishRulesTieOrdering checks that equal bids rank in the order
+// they were placed, and that raising a bid places it again.
+func TestEnglishRulesTieOrdering(t *testing.T) {
+	rules := &EnglishRules{Quantity: 1, TiePolicy: pb.TiePolicy_EARLIEST_WINS}
+
+	var standing []Bid
+	for _, bid := range []Bid{
+		{Bidder: "alice", Amount: 100, Quantity: 1},
+		{Bidder: "bob", Amount: 110, Quantity: 1},
+		{Bidder: "carol", Amount: 110, Quantity: 1},
+	} {
+		if outcome, reason := rules.ValidateBid(bid, standing); outcome != pb.Outcome_SUCCESS {
+			t.Fatalf("%s %d: %v %v", bid.Bidder, bid.Amount, outcome, reason)
+		}
+		standing = rules.ApplyBid(bid, standing)
+	}
+	if leader := rules.Allocations(standing)[0].Bidder; leader != "bob" {
+		t.Fatalf("leader = %s, want bob, who bid 110 first", leader)
+	}
+
+	// alice's raise to 110 replaces her bid of 100 and ranks behind both
+	// earlier bids of 110
+	raise := Bid{Bidder: "alice", Amount: 110, Quantity: 1}
+	if outcome, reason := rules.ValidateBid(raise, standing); outcome != pb.Outcome_SUCCESS {
+		t.Fatalf("raise: %v %v", outcome, reason)
+	}
+	standing = rules.ApplyBid(raise, standing)
+	if len(standing) != 3 {
+		t.Fatalf("%d standing bids, want 3", len(standing))
+	}
+	var order []string
+	for _, bid := range ranked(standing) {
+		order = append(order, bid.Bidder)
+	}
+	if want := []string{"bob", "carol", "alice"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("ranking = %v, want %v", order, want)
+	}
+}
+
+func TestEnglishRulesAllocations(t *testing.T) {
+	standing := bids(
+		Bid{Bidder: "alice", Amount: 100, Quantity: 1},
+		Bid{Bidder: "bob", Amount: 120, Quantity: 1},
+		Bid{Bidder: "carol", Amount: 90, Quantity: 2},
+		Bid{Bidder: "dave", Amount: 80, Quantity: 1},
+	)
+	type allocation struct {
+		bidder   string
+		quantity int32
+		price    int64
+	}
+	tests := []struct {
+		name    string
+		pricing pb.PricingRule
+		want    []allocation
+	}{
+		{
+			name:    "uniform price",
+			pricing: pb.PricingRule_UNIFORM_PRICE,
+			want:    []allocation{{"bob", 1, 90}, {"alice", 1, 90}, {"carol", 1, 90}},
+		},
+		{
+			name:    "pay as bid",
+			pricing: pb.PricingRule_PAY_AS_BID,
+			want:    []allocation{{"bob", 1, 120}, {"alice", 1, 100}, {"carol", 1, 90}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules := &EnglishRules{Quantity: 3, Pricing: test.pricing}
+			allocations := rules.Allocations(standing)
+			if len(allocations) != len(test.want) {
+				t.Fatalf("%d allocations, want %d: %v", len(allocations), len(test.want), allocations)
+			}
+			for i, want := range test.want {
+				got := allocations[i]
+				if got.Bidder != want.bidder || got.Quantity != want.quantity || got.Price != want.price {
+					t.Errorf("allocation %d = %s x %d at %d, want %s x %d at %d", i, got.Bidder, got.Quantity, got.Price, want.bidder, want.quantity, want.price)
+				}
+			}
+			// The bid price is always the bidder's own, whatever they pay
+			if allocations[0].BidPrice != 120 {
+				t.Errorf("bid price = %d, want 120", allocations[0].BidPrice)
+			}
+		})
+	}
+}
+
+func TestEnglishRulesEndTime(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	rules := NewEnglishRules(Config{})
+	standing := bids(Bid{Bidder: "alice", Amount: 100, Quantity: 1})
+
+	for _, bids := range [][]Bid{nil, standing} {
+		if end := rules.EndTime(start, bids); !end.Equal(start.Add(AuctionDuration)) {
+			t.Errorf("EndTime with %d bids = %v, want %v", len(bids), end, start.Add(AuctionDuration))
+		}
+	}
+}
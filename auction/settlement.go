@@ -0,0 +1,260 @@
+package auction
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+)
+
+// AuctionSettled is emitted once an auction has closed and its winners are
+// final. It is recorded and replicated with the settlement, so every node
+// emits the same event with the same ID, and sinks can drop the duplicate
+// a failover during delivery may cause.
+type AuctionSettled struct {
+	ID          string              `json:"id"`
+	AuctionID   string              `json:"auction_id"`
+	Winner      string              `json:"winner,omitempty"`
+	Price       int64               `json:"price"`
+	Currency    string              `json:"currency"`
+	BidCount    int                 `json:"bid_count"`
+	BoughtNow   bool                `json:"bought_now,omitempty"`
+	Allocations []SettledAllocation `json:"allocations,omitempty"`
+	SettledAt   time.Time           `json:"settled_at"`
+}
+
+// SettledAllocation is what one winner takes and pays per unit.
+type SettledAllocation struct {
+	Bidder   string `json:"bidder"`
+	Quantity int32  `json:"quantity"`
+	Price    int64  `json:"price"`
+}
+
+// Settlement describes the auction's outcome for settlement. Price is what
+// the top winner pays per unit; BidCount leaves out retracted bids.
+func (a *Auction) Settlement(settledAt time.Time) AuctionSettled {
+	event := AuctionSettled{
+		ID:        TransitionRequestID(pb.UpdateType_SETTLE),
+		AuctionID: DefaultAuctionID,
+		Currency:  a.currency,
+		BoughtNow: a.buyer != "",
+		SettledAt: settledAt,
+	}
+	for _, bid := range a.history {
+		if !bid.retracted {
+			event.BidCount++
+		}
+	}
+	for _, allocation := range a.Allocations() {
+		event.Allocations = append(event.Allocations, SettledAllocation{
+			Bidder:   allocation.Bidder,
+			Quantity: allocation.Quantity,
+			Price:    allocation.Price,
+		})
+	}
+	if len(event.Allocations) > 0 {
+		event.Winner = event.Allocations[0].Bidder
+		event.Price = event.Allocations[0].Price
+	}
+	return event
+}
+
+// SettlementSink receives settlement events.
+type SettlementSink interface {
+	Publish(event AuctionSettled) error
+}
+
+// WriterSink writes each event as a line of JSON, e.g. to stdout.
+type WriterSink struct {
+	W io.Writer
+}
+
+func (s WriterSink) Publish(event AuctionSettled) error {
+	return json.NewEncoder(s.W).Encode(event)
+}
+
+// FileSink appends each event as a line of JSON to the file at Path.
+type FileSink struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (s *FileSink) Publish(event AuctionSettled) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(event)
+}
+
+// WebhookSink POSTs each event as JSON to URL. The event ID is also sent as
+// the Idempotency-Key header.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s WebhookSink) Publish(event AuctionSettled) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.ID)
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", s.URL, resp.Status)
+	}
+	return nil
+}
+
+// ParseSettlementSinks parses a comma-separated list of sinks: "stdout",
+// "file:<path>" or an http(s) webhook URL.
+func ParseSettlementSinks(spec string) ([]SettlementSink, error) {
+	var sinks []SettlementSink
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+			continue
+		case item == "stdout":
+			sinks = append(sinks, WriterSink{W: os.Stdout})
+		case strings.HasPrefix(item, "file:"):
+			sinks = append(sinks, &FileSink{Path: strings.TrimPrefix(item, "file:")})
+		case strings.HasPrefix(item, "http://"), strings.HasPrefix(item, "https://"):
+			sinks = append(sinks, WebhookSink{URL: item})
+		default:
+			return nil, fmt.Errorf("unknown settlement sink %q (want stdout, file:<path> or a webhook URL)", item)
+		}
+	}
+	return sinks, nil
+}
+
+// OutboxInterval is how often the serving node flushes its outbox, and so
+// how often a failing sink is retried.
+const OutboxInterval = time.Second
+
+// Outbox delivers settlement events to sinks at least once. Events are
+// keyed by ID, so adding one again changes nothing, and Flush retries every
+// sink that has not accepted an event yet. Sinks are only called from
+// Flush, never from Add, so a slow sink does not hold up whoever adds.
+type Outbox struct {
+	sinks  []SettlementSink
+	logger *slog.Logger
+
+	mutex   sync.Mutex
+	entries map[string]*outboxEntry
+	order   []string
+
+	// Held while publishing, so flushes do not deliver an event twice
+	flushMutex sync.Mutex
+}
+
+type outboxEntry struct {
+	event     AuctionSettled
+	delivered []bool // by sink
+}
+
+// NewOutbox returns an empty outbox for sinks. A nil logger means the
+// default logger.
+func NewOutbox(sinks []SettlementSink, logger *slog.Logger) *Outbox {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Outbox{
+		sinks:   sinks,
+		logger:  logger,
+		entries: make(map[string]*outboxEntry),
+	}
+}
+
+// Add queues event for delivery unless an event with its ID was added
+// before.
+func (o *Outbox) Add(event AuctionSettled) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if _, exists := o.entries[event.ID]; exists {
+		return
+	}
+	o.entries[event.ID] = &outboxEntry{event: event, delivered: make([]bool, len(o.sinks))}
+	o.order = append(o.order, event.ID)
+}
+
+// Pending returns how many events some sink has not accepted yet.
+func (o *Outbox) Pending() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	pending := 0
+	for _, id := range o.order {
+		for _, delivered := range o.entries[id].delivered {
+			if !delivered {
+				pending++
+				break
+			}
+		}
+	}
+	return pending
+}
+
+// Flush hands every queued event to each sink that has not accepted it
+// yet. A failing sink is logged and tried again on the next Flush; it
+// does not stop the others.
+func (o *Outbox) Flush() {
+	o.flushMutex.Lock()
+	defer o.flushMutex.Unlock()
+
+	type delivery struct {
+		entry *outboxEntry
+		sink  int
+	}
+	var deliveries []delivery
+	o.mutex.Lock()
+	for _, id := range o.order {
+		entry := o.entries[id]
+		for sink, delivered := range entry.delivered {
+			if !delivered {
+				deliveries = append(deliveries, delivery{entry, sink})
+			}
+		}
+	}
+	o.mutex.Unlock()
+
+	for _, d := range deliveries {
+		if err := o.sinks[d.sink].Publish(d.entry.event); err != nil {
+			o.logger.Error("Failed to publish settlement, will retry", "settlement", d.entry.event.ID, "err", err)
+			continue
+		}
+		o.mutex.Lock()
+		d.entry.delivered[d.sink] = true
+		o.mutex.Unlock()
+	}
+}
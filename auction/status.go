@@ -0,0 +1,129 @@
+package auction
+
+import (
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// ErrorDomain is the ErrorInfo domain servers use for infrastructure
+// failures. Business rejections are reported through BidResponse.outcome
+// and never as a gRPC error.
+const ErrorDomain = "auction"
+
+// ErrorInfo reasons attached to infrastructure failures.
+const (
+	ReasonNotPrimary        = "NOT_PRIMARY"
+	ReasonReplicationFailed = "REPLICATION_FAILED"
+	ReasonRateLimited       = "RATE_LIMITED"
+	ReasonOutOfOrder        = "OUT_OF_ORDER"
+	ReasonStaleEpoch        = "STALE_EPOCH"
+)
+
+// RetryDelay is how long servers ask clients to wait before retrying.
+const RetryDelay = 500 * time.Millisecond
+
+// NotPrimaryError is returned by a server that cannot accept writes because
+// it is not the primary. leader, when known, is the address to retry on.
+func NotPrimaryError(leader string) error {
+	info := &errdetails.ErrorInfo{Reason: ReasonNotPrimary, Domain: ErrorDomain}
+	if leader != "" {
+		info.Metadata = map[string]string{"leader": leader}
+	}
+	return statusError(codes.Unavailable, "requests must be directed to primary", info, RetryDelay)
+}
+
+// ReplicationError is returned when an update could not be replicated. An
+// unreachable backup is Unavailable; a backup that refused the update is
+// Aborted. Both are safe to retry with the same request ID.
+func ReplicationError(err error) error {
+	code := codes.Aborted
+	if s, ok := status.FromError(err); ok && (s.Code() == codes.Unavailable || s.Code() == codes.DeadlineExceeded) {
+		code = codes.Unavailable
+	}
+	info := &errdetails.ErrorInfo{Reason: ReasonReplicationFailed, Domain: ErrorDomain}
+	return statusError(code, "replication failed: "+err.Error(), info, RetryDelay)
+}
+
+// RateLimitedError is returned for a call over one of the server's rate
+// limits: "client", "connection" or "concurrency". The call was not run and
+// can be retried after retryAfter.
+func RateLimitedError(limit string, retryAfter time.Duration) error {
+	info := &errdetails.ErrorInfo{Reason: ReasonRateLimited, Domain: ErrorDomain, Metadata: map[string]string{"limit": limit}}
+	return statusError(codes.ResourceExhausted, limit+" rate limit exceeded", info, retryAfter)
+}
+
+// OutOfOrderError is returned by a backup for an update that does not
+// follow the last one it applied. next is the sequence it expects, from
+// which the primary resends.
+func OutOfOrderError(next uint64) error {
+	info := &errdetails.ErrorInfo{Reason: ReasonOutOfOrder, Domain: ErrorDomain, Metadata: map[string]string{"next": strconv.FormatUint(next, 10)}}
+	return statusError(codes.FailedPrecondition, "update out of order, expected sequence "+strconv.FormatUint(next, 10), info, RetryDelay)
+}
+
+// StaleEpochError is returned by a backup for replication from a primary
+// in an epoch older than epoch, the one the backup is in, or from any
+// primary once the backup has taken over. The sender has been replaced
+// and must step down.
+func StaleEpochError(epoch uint64) error {
+	info := &errdetails.ErrorInfo{Reason: ReasonStaleEpoch, Domain: ErrorDomain, Metadata: map[string]string{"epoch": strconv.FormatUint(epoch, 10)}}
+	return statusError(codes.FailedPrecondition, "sender is not primary in epoch "+strconv.FormatUint(epoch, 10), info, RetryDelay)
+}
+
+func statusError(code codes.Code, message string, info *errdetails.ErrorInfo, retryDelay time.Duration) error {
+	st := status.New(code, message)
+	detailed, err := st.WithDetails(info, &errdetails.RetryInfo{RetryDelay: durationpb.New(retryDelay)})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// LeaderHint returns the leader address carried by a NotPrimaryError.
+func LeaderHint(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == ErrorDomain {
+			return info.Metadata["leader"]
+		}
+	}
+	return ""
+}
+
+// ExpectedSequence returns the sequence an OutOfOrderError asks for, and
+// whether err is one.
+func ExpectedSequence(err error) (uint64, bool) {
+	return metadataUint(err, ReasonOutOfOrder, "next")
+}
+
+// metadataUint reads the number under key in the ErrorInfo of err, if it
+// has the given reason.
+func metadataUint(err error, reason, key string) (uint64, bool) {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == ErrorDomain && info.Reason == reason {
+			n, err := strconv.ParseUint(info.Metadata[key], 10, 64)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+// FencedEpoch returns the epoch a StaleEpochError reports, and whether err
+// is one.
+func FencedEpoch(err error) (uint64, bool) {
+	return metadataUint(err, ReasonStaleEpoch, "epoch")
+}
+
+// RetryAfter returns the delay a server asked for before retrying, and
+// whether it asked at all. Transport failures carry no retry info.
+func RetryAfter(err error) (time.Duration, bool) {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			return info.RetryDelay.AsDuration(), true
+		}
+	}
+	return 0, false
+}
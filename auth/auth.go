@@ -0,0 +1,284 @@
+// Package auth authenticates the callers of AuctionService and
+// AdminService with JSON Web Tokens, so nobody can bid, retract or approve
+// under another client ID.
+//
+// A token is signed with a private key kept by whoever issues tokens and
+// verified by the servers against the matching public key; its subject is
+// the caller's client ID. Clients send it in the authorization metadata of
+// every call, as "Bearer <token>". The server derives the caller from the
+// token: a request that leaves its caller field empty gets the subject
+// filled in, and one that names someone else is refused.
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// metadataKey carries the token in gRPC metadata.
+const metadataKey = "authorization"
+
+// Verifier checks tokens against a public key.
+type Verifier struct {
+	key     crypto.PublicKey
+	methods []string
+}
+
+// FromFlags returns a verifier for the PEM public key (ECDSA, RSA or
+// Ed25519) at path, or nil if path is empty, which leaves authentication
+// off.
+func FromFlags(path string) (*Verifier, error) {
+	if path == "" {
+		return nil, nil
+	}
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	method, err := signingMethod(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &Verifier{key: key, methods: []string{method.Alg()}}, nil
+}
+
+// Verify returns the subject of token if it is signed with the verifier's
+// key and has not expired. Tokens must carry an expiry time.
+func (v *Verifier) Verify(token string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return v.key, nil
+	}, jwt.WithValidMethods(v.methods), jwt.WithExpirationRequired())
+	if err != nil {
+		return "", err
+	}
+	if claims.Subject == "" {
+		return "", fmt.Errorf("token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// Signer issues tokens with a private key.
+type Signer struct {
+	key    crypto.Signer
+	method jwt.SigningMethod
+}
+
+// LoadSigner reads a PEM private key (ECDSA, RSA or Ed25519; PKCS #8, SEC 1
+// or PKCS #1) from path.
+func LoadSigner(path string) (*Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	var key interface{}
+	if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+			if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+				return nil, fmt.Errorf("%s: not an ECDSA, RSA or Ed25519 private key", path)
+			}
+		}
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key", path)
+	}
+	method, err := signingMethod(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &Signer{key: signer, method: method}, nil
+}
+
+// Token returns a token for subject that expires after ttl.
+func (s *Signer) Token(subject string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   subject,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+	return jwt.NewWithClaims(s.method, claims).SignedString(s.key)
+}
+
+// signingMethod is the algorithm tokens are signed with for a key.
+func signingMethod(key crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return jwt.SigningMethodES256, nil
+		case 384:
+			return jwt.SigningMethodES384, nil
+		case 521:
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported ECDSA curve %s", key.Curve.Params().Name)
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block, nil
+}
+
+type subjectKey struct{}
+
+// WithSubject returns ctx for a call authenticated as subject.
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// Subject returns the authenticated caller of the call ctx belongs to, if
+// authentication is on.
+func Subject(ctx context.Context) (string, bool) {
+	subject, ok := ctx.Value(subjectKey{}).(string)
+	return subject, ok
+}
+
+// ServerOptions authenticate every call to AuctionService and
+// AdminService with v, if non-nil. Calls without a valid token are refused
+// with Unauthenticated, and calls on behalf of someone other than the
+// token's subject with PermissionDenied. Replication is authenticated by
+// mutual TLS instead, and health checks are open.
+func ServerOptions(v *Verifier) []grpc.ServerOption {
+	if v == nil {
+		return nil
+	}
+	return []grpc.ServerOption{grpc.ChainUnaryInterceptor(v.authenticate)}
+}
+
+func (v *Verifier) authenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !strings.HasPrefix(info.FullMethod, "/"+pb.AuctionService_ServiceDesc.ServiceName+"/") &&
+		!strings.HasPrefix(info.FullMethod, "/"+pb.AdminService_ServiceDesc.ServiceName+"/") {
+		return handler(ctx, req)
+	}
+
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	subject, err := v.Verify(token)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+	}
+	if err := bindCaller(req, subject); err != nil {
+		return nil, err
+	}
+	return handler(WithSubject(ctx, subject), req)
+}
+
+func bearerToken(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(metadataKey)
+	if len(values) == 0 {
+		return "", fmt.Errorf("missing %s metadata", metadataKey)
+	}
+	token, found := strings.CutPrefix(values[0], "Bearer ")
+	if !found {
+		return "", fmt.Errorf("%s metadata must be \"Bearer <token>\"", metadataKey)
+	}
+	return token, nil
+}
+
+// bindCaller makes the field of req that names its caller the token's
+// subject: an empty one is filled in, and one naming someone else is
+// refused. A retraction's caller is requested_by, and a retraction that
+// names no bidder retracts the caller's own bid.
+func bindCaller(req interface{}, subject string) error {
+	var caller *string
+	switch req := req.(type) {
+	case *pb.BidRequest:
+		caller = &req.ClientId
+	case *pb.BuyNowRequest:
+		caller = &req.ClientId
+	case *pb.RegisterRequest:
+		caller = &req.ClientId
+	case *pb.RetractRequest:
+		if req.RequestedBy == "" {
+			req.RequestedBy = subject
+		}
+		if req.ClientId == "" {
+			req.ClientId = req.RequestedBy
+		}
+		caller = &req.RequestedBy
+	case *pb.ApproveRequest:
+		caller = &req.ApprovedBy
+	case *pb.CancelRequest:
+		caller = &req.CancelledBy
+	case *pb.SetFaultsRequest:
+		caller = &req.RequestedBy
+	case *pb.TransferLeadershipRequest:
+		caller = &req.RequestedBy
+	case *pb.SetRateLimitsRequest:
+		caller = &req.RequestedBy
+	default:
+		return nil
+	}
+
+	if *caller == "" {
+		*caller = subject
+	}
+	if *caller != subject {
+		return status.Errorf(codes.PermissionDenied, "authenticated as %q, not %q", subject, *caller)
+	}
+	return nil
+}
+
+// DialOptions send token, if non-empty, with every call made on a gRPC
+// client connection. The token is a bearer credential, so it is only sent
+// over TLS.
+func DialOptions(token string) []grpc.DialOption {
+	if token == "" {
+		return nil
+	}
+	return []grpc.DialOption{grpc.WithPerRPCCredentials(bearer(token))}
+}
+
+// bearer sends a token in the authorization metadata.
+type bearer string
+
+var _ credentials.PerRPCCredentials = bearer("")
+
+func (b bearer) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{metadataKey: "Bearer " + string(b)}, nil
+}
+
+func (b bearer) RequireTransportSecurity() bool {
+	return true
+}
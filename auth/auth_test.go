@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// newKeys writes a fresh P-256 key pair to a temporary directory and
+// returns a signer and a verifier for it.
+func newKeys(t *testing.T) (*Signer, *Verifier, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	privatePath, publicPath := filepath.Join(dir, "key.pem"), filepath.Join(dir, "pub.pem")
+	writePEM(t, privatePath, "PRIVATE KEY", private)
+	writePEM(t, publicPath, "PUBLIC KEY", public)
+
+	signer, err := LoadSigner(privatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := FromFlags(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, verifier, key
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestVerify(t *testing.T) {
+	signer, verifier, key := newKeys(t)
+	_, _, otherKey := newKeys(t)
+	now := time.Now()
+	valid := jwt.RegisteredClaims{Subject: "alice", ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour))}
+
+	good, err := signer.Token("alice", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A token whose payload names someone else, with the original signature
+	parts := strings.Split(good, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(payload), `"alice"`, `"mallory"`, 1)))
+	tampered := strings.Join(parts, ".")
+
+	publicDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  string // the subject, or empty if the token must be refused
+	}{
+		{"valid", good, "alice"},
+		{"expired", sign(t, jwt.SigningMethodES256, key, jwt.RegisteredClaims{Subject: "alice", ExpiresAt: jwt.NewNumericDate(now.Add(-time.Minute))}), ""},
+		{"no expiry", sign(t, jwt.SigningMethodES256, key, jwt.RegisteredClaims{Subject: "alice"}), ""},
+		{"no subject", sign(t, jwt.SigningMethodES256, key, jwt.RegisteredClaims{ExpiresAt: valid.ExpiresAt}), ""},
+		{"tampered", tampered, ""},
+		{"signed by another key", sign(t, jwt.SigningMethodES256, otherKey, valid), ""},
+		{"wrong algorithm", sign(t, jwt.SigningMethodES384, mustKey(t, elliptic.P384()), valid), ""},
+		{"HMAC with the public key", sign(t, jwt.SigningMethodHS256, publicDER, valid), ""},
+		{"unsigned", sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid), ""},
+		{"garbage", "not.a.token", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subject, err := verifier.Verify(test.token)
+			if test.want == "" {
+				if err == nil {
+					t.Fatalf("Verify accepted the token as %q", subject)
+				}
+				return
+			}
+			if err != nil || subject != test.want {
+				t.Fatalf("Verify = %q, %v, want %q", subject, err, test.want)
+			}
+		})
+	}
+}
+
+func mustKey(t *testing.T, curve elliptic.Curve) crypto.Signer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestBindCaller(t *testing.T) {
+	tests := []struct {
+		name string
+		req  proto.Message
+		want proto.Message // req after binding, if allowed
+		code codes.Code
+	}{
+		{"bid fills in the caller", &pb.BidRequest{Amount: 100}, &pb.BidRequest{ClientId: "alice", Amount: 100}, codes.OK},
+		{"bid as the caller", &pb.BidRequest{ClientId: "alice"}, &pb.BidRequest{ClientId: "alice"}, codes.OK},
+		{"bid as someone else", &pb.BidRequest{ClientId: "bob"}, nil, codes.PermissionDenied},
+		{"buy-now as someone else", &pb.BuyNowRequest{ClientId: "bob"}, nil, codes.PermissionDenied},
+		{"register someone else", &pb.RegisterRequest{ClientId: "bob"}, nil, codes.PermissionDenied},
+		{"retract the own bid", &pb.RetractRequest{}, &pb.RetractRequest{ClientId: "alice", RequestedBy: "alice"}, codes.OK},
+		{"retract another's bid", &pb.RetractRequest{ClientId: "bob"}, &pb.RetractRequest{ClientId: "bob", RequestedBy: "alice"}, codes.OK},
+		{"retract as someone else", &pb.RetractRequest{ClientId: "bob", RequestedBy: "bob"}, nil, codes.PermissionDenied},
+		{"approve as someone else", &pb.ApproveRequest{ClientId: "bob", ApprovedBy: "carol"}, nil, codes.PermissionDenied},
+		{"cancel as someone else", &pb.CancelRequest{CancelledBy: "carol"}, nil, codes.PermissionDenied},
+		{"set faults as someone else", &pb.SetFaultsRequest{RequestedBy: "ops"}, nil, codes.PermissionDenied},
+		{"transfer as someone else", &pb.TransferLeadershipRequest{RequestedBy: "ops"}, nil, codes.PermissionDenied},
+		{"set rate limits as someone else", &pb.SetRateLimitsRequest{RequestedBy: "ops"}, nil, codes.PermissionDenied},
+		{"a request without a caller", &pb.ResultRequest{}, &pb.ResultRequest{}, codes.OK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := bindCaller(test.req, "alice")
+			if code := status.Code(err); code != test.code {
+				t.Fatalf("bindCaller = %v, want %v", err, test.code)
+			}
+			if test.want != nil && !proto.Equal(test.req, test.want) {
+				t.Errorf("request = %v, want %v", test.req, test.want)
+			}
+		})
+	}
+}
+
+// TestAuthenticate runs calls through the interceptor ServerOptions
+// installs.
+func TestAuthenticate(t *testing.T) {
+	signer, verifier, _ := newKeys(t)
+	token, err := signer.Token("alice", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := signer.Token("alice", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bid := &grpc.UnaryServerInfo{FullMethod: "/" + pb.AuctionService_ServiceDesc.ServiceName + "/Bid"}
+	health := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	tests := []struct {
+		name     string
+		metadata metadata.MD
+		info     *grpc.UnaryServerInfo
+		req      interface{}
+		code     codes.Code
+	}{
+		{"valid token", metadata.Pairs(metadataKey, "Bearer "+token), bid, &pb.BidRequest{}, codes.OK},
+		{"no token", metadata.MD{}, bid, &pb.BidRequest{}, codes.Unauthenticated},
+		{"not a bearer token", metadata.Pairs(metadataKey, token), bid, &pb.BidRequest{}, codes.Unauthenticated},
+		{"expired token", metadata.Pairs(metadataKey, "Bearer "+expired), bid, &pb.BidRequest{}, codes.Unauthenticated},
+		{"someone else's bid", metadata.Pairs(metadataKey, "Bearer "+token), bid, &pb.BidRequest{ClientId: "bob"}, codes.PermissionDenied},
+		{"health checks are open", metadata.MD{}, health, nil, codes.OK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), test.metadata)
+			var called bool
+			_, err := verifier.authenticate(ctx, test.req, test.info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				if test.info == bid {
+					if subject, ok := Subject(ctx); !ok || subject != "alice" {
+						t.Errorf("Subject = %q, %v, want alice", subject, ok)
+					}
+				}
+				return nil, nil
+			})
+			if code := status.Code(err); code != test.code {
+				t.Fatalf("authenticate = %v, want %v", err, test.code)
+			}
+			if called != (test.code == codes.OK) {
+				t.Errorf("handler called = %v", called)
+			}
+		})
+	}
+}
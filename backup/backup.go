@@ -1,175 +1,501 @@
-package main
+package backup
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/clock"
+	"github.com/joachimblom-hanssen/Distributed_5/faults"
+	"github.com/joachimblom-hanssen/Distributed_5/logging"
+	"github.com/joachimblom-hanssen/Distributed_5/metrics"
 	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"github.com/joachimblom-hanssen/Distributed_5/ratelimit"
+	"github.com/joachimblom-hanssen/Distributed_5/rbac"
+	"github.com/joachimblom-hanssen/Distributed_5/replica"
+	"github.com/joachimblom-hanssen/Distributed_5/tracing"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
 type BackupServer struct {
 	pb.UnimplementedReplicationServiceServer
 	pb.UnimplementedAuctionServiceServer
-	auctionState      *auction.Auction
-	processedRequests map[string]*pb.BidResponse
-	mutex             sync.Mutex
-	
+	pb.UnimplementedAdminServiceServer
+	state       *replica.State
+	outbox      *auction.Outbox
+	primaryAddr string
+	clock       clock.Clock
+	mutex       sync.Mutex
+	faults      *faults.Injector
+	rateLimits  *ratelimit.Limiter
+	metrics     *metrics.Metrics
+	node        *logging.Node
+	logger      *slog.Logger
+	health      *health.Server
+	stops       []func()
+	closeOnce   sync.Once
+
 	// Failure detection and promotion
-	isPrimary         bool
-	lastHeartbeat     time.Time
-	heartbeatMutex    sync.Mutex
+	isPrimary      bool
+	epoch          uint64
+	lastHeartbeat  time.Time
+	heartbeatMutex sync.Mutex
+}
+
+// Options configures a BackupServer.
+type Options struct {
+	StartTime time.Time
+	Config    auction.Config
+	Registry  *auction.Registry // nil means no registration
+	Admins    []string
+	Sinks     []auction.SettlementSink
+	// PrimaryAddress is given to clients that send writes here.
+	PrimaryAddress string
+	// Clock defaults to the wall clock.
+	Clock clock.Clock
+	// Faults is the injector on the calls this server receives. SetFaults
+	// changes its rules; nil leaves fault injection off.
+	Faults *faults.Injector
+	// RateLimits limits the AuctionService calls this server receives.
+	// SetRateLimits changes its limits; nil leaves rate limiting off.
+	RateLimits *ratelimit.Limiter
+	// Metrics records what the server does; nil records nothing.
+	Metrics *metrics.Metrics
+	// Node is the server's identity in its logs, with the role and epoch
+	// it serves in kept up to date; nil means a node called "backup". Logger is where it logs;
+	// nil means the default logger.
+	Node   *logging.Node
+	Logger *slog.Logger
+	// Policy, if set, decides who may use admin permissions in place of
+	// Admins.
+	Policy *rbac.Policy
+	// Health, if set, is told which services the server is serving:
+	// AuctionService only once it has taken over.
+	Health *health.Server
 }
 
-func NewBackupServer(startTime time.Time) *BackupServer {
+func NewBackupServer(opts Options) *BackupServer {
+	if opts.Clock == nil {
+		opts.Clock = clock.Real
+	}
+	if opts.Node == nil {
+		opts.Node = logging.NewNode(metrics.RoleBackup, metrics.RoleBackup, 1)
+	}
+
 	s := &BackupServer{
-		auctionState:      auction.NewAuction(startTime),
-		processedRequests: make(map[string]*pb.BidResponse),
-		isPrimary:         false,
-		lastHeartbeat:     time.Now(),
-	}
-	
-	// Start monitoring for primary failure
-	go s.monitorPrimaryHealth()
-	
+		state: replica.New(replica.Options{
+			StartTime: opts.StartTime,
+			Config:    opts.Config,
+			Registry:  opts.Registry,
+			Admins:    opts.Admins,
+			Policy:    opts.Policy,
+			Clock:     opts.Clock,
+			Metrics:   opts.Metrics,
+		}),
+		primaryAddr:   opts.PrimaryAddress,
+		clock:         opts.Clock,
+		faults:        opts.Faults,
+		rateLimits:    opts.RateLimits,
+		metrics:       opts.Metrics,
+		node:          opts.Node,
+		health:        opts.Health,
+		isPrimary:     false,
+		epoch:         1,
+		lastHeartbeat: opts.Clock.Now(),
+	}
+	s.logger = s.node.Logger(opts.Logger)
+	s.outbox = auction.NewOutbox(opts.Sinks, s.logger)
+	if s.health != nil {
+		s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+		s.health.SetServingStatus(pb.AuctionService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+		s.health.SetServingStatus(pb.AdminService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+		s.health.SetServingStatus(pb.ReplicationService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	}
+
+	// Start monitoring for primary failure and take over the auction
+	// schedule and settlement delivery once promoted
+	s.stops = append(s.stops,
+		s.clock.Every(1*time.Second, s.checkPrimaryHealth),
+		s.clock.Every(auction.SchedulerInterval, s.runScheduler),
+		s.clock.Every(auction.OutboxInterval, s.deliverSettlement),
+	)
+
 	return s
 }
 
-// monitorPrimaryHealth checks if heartbeats from primary have stopped
-func (s *BackupServer) monitorPrimaryHealth() {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-	
-	for range ticker.C {
-		s.heartbeatMutex.Lock()
-		timeSinceLastHeartbeat := time.Since(s.lastHeartbeat)
-		s.heartbeatMutex.Unlock()
-		
-		// If no heartbeat/update for 5 seconds and we're not already primary, promote
-		if timeSinceLastHeartbeat > 5*time.Second && !s.isPrimary {
-			s.promoteToPrimary()
+// Close stops failure detection and the scheduler and reports every
+// service as not serving. It does not stop the gRPC server serving s.
+func (s *BackupServer) Close() {
+	s.closeOnce.Do(func() {
+		for _, stop := range s.stops {
+			stop()
 		}
+		if s.health != nil {
+			s.health.Shutdown()
+		}
+	})
+}
+
+// checkPrimaryHealth checks if heartbeats from primary have stopped
+func (s *BackupServer) checkPrimaryHealth() {
+	s.heartbeatMutex.Lock()
+	timeSinceLastHeartbeat := s.clock.Now().Sub(s.lastHeartbeat)
+	s.heartbeatMutex.Unlock()
+
+	// If no heartbeat/update for 5 seconds and we're not already primary, promote
+	if timeSinceLastHeartbeat > 5*time.Second && !s.IsPrimary() {
+		s.promoteToPrimary()
 	}
 }
 
+// IsPrimary reports whether the backup has taken over as primary.
+func (s *BackupServer) IsPrimary() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.isPrimary
+}
+
 // promoteToPrimary handles the transition from backup to primary
 func (s *BackupServer) promoteToPrimary() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	
+
 	if s.isPrimary {
 		return // Already promoted
 	}
-	
-	log.Println("=== PRIMARY FAILURE DETECTED ===")
-	log.Println("Promoting backup to primary role")
-	
+
+	s.logger.Warn("Primary failure detected, promoting backup to primary")
+	s.promote(metrics.PromotionFailover)
+}
+
+// TakeOver promotes the backup when the primary hands over to it. It is
+// refused unless the backup has applied every update up to the primary's
+// last sequence, so no acknowledged write is lost. The backup applies
+// updates strictly in order, so the last sequence it applied is also the
+// highest with no gap behind it. Taking over again returns the same epoch.
+func (s *BackupServer) TakeOver(ctx context.Context, req *pb.TakeOverRequest) (*pb.TakeOverResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.isPrimary {
+		if applied := s.state.Sequence(); applied != req.Sequence {
+			return nil, status.Errorf(codes.FailedPrecondition, "backup has applied every update up to sequence %d, not %d", applied, req.Sequence)
+		}
+		s.logger.InfoContext(ctx, "Primary handed over, promoting backup to primary")
+		s.promote(metrics.PromotionHandover)
+	}
+	return &pb.TakeOverResponse{Epoch: s.epoch}, nil
+}
+
+// promote makes the backup serve as primary in the next epoch; reason is
+// why, for the metrics. Must be called with s.mutex held.
+func (s *BackupServer) promote(reason string) {
 	s.isPrimary = true
-	
-	log.Println("Backup is now serving as PRIMARY")
-	log.Println("System continues operating with current auction state")
+	s.epoch++
+	s.node.SetRole(metrics.RolePrimary, s.epoch)
+	s.metrics.Promoted(s.epoch, reason)
+	if s.health != nil {
+		s.health.SetServingStatus(pb.AuctionService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	}
+
+	s.logger.Info("Backup is now serving as primary with the current auction state", "sequence", s.state.Sequence())
 }
 
 func (s *BackupServer) ReplicateUpdate(ctx context.Context, req *pb.UpdateRequest) (*pb.UpdateResponse, error) {
+	stages := tracing.NewStages(ctx, "lock")
+	defer stages.End()
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	
-	// Update heartbeat timestamp - receiving updates means primary is alive
-	s.heartbeatMutex.Lock()
-	s.lastHeartbeat = time.Now()
-	s.heartbeatMutex.Unlock()
+
+	if err := s.fence(req.Epoch); err != nil {
+		stages.Fail(err)
+		s.logger.WarnContext(ctx, "Refused update from a stale primary", "epoch", req.Epoch, "err", err)
+		return nil, err
+	}
 
 	// Check for duplicate
-	if _, exists := s.processedRequests[req.RequestId]; exists {
-		log.Printf("Duplicate update %s, acknowledging with cached response", req.RequestId)
+	stages.Next("dedup")
+	if response, exists := s.state.Processed(req.RequestId); exists {
+		// The same request at another sequence comes from a primary whose
+		// history is not the backup's, e.g. one that restarted empty
+		if response.Sequence != req.Sequence {
+			err := status.Errorf(codes.FailedPrecondition, "request was applied at sequence %d, not %d", response.Sequence, req.Sequence)
+			stages.Fail(err)
+			s.logger.WarnContext(ctx, "Refused update", "type", req.Type.String(), "sequence", req.Sequence, "err", err)
+			return nil, err
+		}
+		s.logger.InfoContext(ctx, "Duplicate update, acknowledging with cached response")
+		s.metrics.DedupHit()
+		s.heardFrom()
 		return &pb.UpdateResponse{Acknowledged: true}, nil
 	}
 
-	// Apply the operation with the same outcome as primary decided
-	// This ensures consistency - we don't re-execute, we just record
-	s.auctionState.PlaceBid(req.ClientId, req.Amount, time.Now())
-	
-	// Store the response for idempotency
-	response := &pb.BidResponse{
-		Outcome: req.Outcome,
-		Message: outcomeMessage(req.Outcome, req.Amount),
+	// Apply the operation with the same outcome as primary decided, in
+	// sequence order
+	stages.Next("apply")
+	if _, err := s.state.Apply(req); err != nil {
+		stages.Fail(err)
+		s.logger.WarnContext(ctx, "Refused update", "type", req.Type.String(), "sequence", req.Sequence, "err", err)
+		return nil, err
 	}
-	s.processedRequests[req.RequestId] = response
 
-	log.Printf("Replicated bid from %s: %d, outcome: %s", req.ClientId, req.Amount, req.Outcome)
+	// Receiving updates means primary is alive
+	s.heardFrom()
+
+	s.logger.InfoContext(ctx, "Replicated update", "type", req.Type.String(), "client_id", req.ClientId, "amount", req.Amount, "outcome", req.Outcome.String(), "sequence", req.Sequence)
 
 	return &pb.UpdateResponse{Acknowledged: true}, nil
 }
 
 // Heartbeat handles heartbeat messages from primary
 func (s *BackupServer) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest) (*pb.HeartbeatResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.fence(req.Epoch); err != nil {
+		return nil, err
+	}
+	// A primary that does not know the backup applied its last update has
+	// lost updates, e.g. by restarting empty, and must not keep the backup
+	// from taking over
+	if req.Sequence < s.state.Sequence() {
+		return nil, status.Errorf(codes.FailedPrecondition, "primary knows of updates up to sequence %d, behind the backup at %d", req.Sequence, s.state.Sequence())
+	}
+	s.heardFrom()
+
+	return &pb.HeartbeatResponse{Alive: true}, nil
+}
+
+// fence refuses replication and heartbeats from a primary serving in
+// epoch, unless it is the primary of the backup's epoch or of a newer one,
+// which the backup follows from then on. Once the backup has taken over
+// it refuses every primary, so two primaries can never both accept
+// writes: the one it replaced can no longer replicate, and steps down when
+// told its epoch is stale. Must be called with s.mutex held.
+func (s *BackupServer) fence(epoch uint64) error {
+	if s.isPrimary || epoch < s.epoch {
+		return auction.StaleEpochError(s.epoch)
+	}
+	if epoch > s.epoch {
+		s.epoch = epoch
+		s.node.SetRole(metrics.RoleBackup, s.epoch)
+	}
+	return nil
+}
+
+// heardFrom records that the primary is alive. Only updates the backup
+// accepts count, so a primary that keeps sending ones it refuses does not
+// keep it from taking over.
+func (s *BackupServer) heardFrom() {
 	s.heartbeatMutex.Lock()
-	s.lastHeartbeat = time.Now()
+	s.lastHeartbeat = s.clock.Now()
 	s.heartbeatMutex.Unlock()
-	
-	return &pb.HeartbeatResponse{Alive: true}, nil
 }
 
-func (s *BackupServer) Result(ctx context.Context, req *pb.ResultRequest) (*pb.ResultResponse, error) {
+// GetClusterStatus reports this node's role and epoch, the primary, when
+// it last heard from the primary and the last sequence it applied or
+// assigned
+func (s *BackupServer) GetClusterStatus(ctx context.Context, req *pb.ClusterStatusRequest) (*pb.ClusterStatus, error) {
+	s.heartbeatMutex.Lock()
+	lastHeartbeat := s.lastHeartbeat
+	s.heartbeatMutex.Unlock()
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	status, highestBid, winner := s.auctionState.GetResult(time.Now())
-
-	return &pb.ResultResponse{
-		Status:     status,
-		HighestBid: highestBid,
-		Winner:     winner,
+	// Once promoted, the old primary has failed or handed over
+	role, peerRole := pb.Role_ROLE_BACKUP, pb.Role_ROLE_PRIMARY
+	if s.isPrimary {
+		role, peerRole = pb.Role_ROLE_PRIMARY, pb.Role_ROLE_NONE
+	}
+	return &pb.ClusterStatus{
+		NodeId:              s.node.ID(),
+		Role:                role,
+		Epoch:               s.epoch,
+		Peers:               []*pb.Peer{{Address: s.primaryAddr, Role: peerRole}},
+		LastHeartbeat:       lastHeartbeat.UnixNano(),
+		LastAppliedSequence: s.state.Sequence(),
 	}, nil
 }
 
+// History returns every bid with the timestamp and sequence the primary
+// assigned to it
+func (s *BackupServer) History(ctx context.Context, req *pb.HistoryRequest) (*pb.HistoryResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.state.History(), nil
+}
+
+// Result is only served once the backup has taken over: until then its
+// state can lag the primary's.
+func (s *BackupServer) Result(ctx context.Context, req *pb.ResultRequest) (*pb.ResultResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.isPrimary {
+		return nil, auction.NotPrimaryError(s.primaryAddr)
+	}
+	return s.state.Result(), nil
+}
+
 // Bid now works if we've been promoted to primary
 func (s *BackupServer) Bid(ctx context.Context, req *pb.BidRequest) (*pb.BidResponse, error) {
+	return s.execute(ctx, s.state.BidUpdate(req))
+}
+
+// BuyNow works like Bid: only once we've been promoted to primary
+func (s *BackupServer) BuyNow(ctx context.Context, req *pb.BuyNowRequest) (*pb.BidResponse, error) {
+	return s.execute(ctx, s.state.BuyNowUpdate(req))
+}
+
+// RetractBid works like Bid: only once we've been promoted to primary
+func (s *BackupServer) RetractBid(ctx context.Context, req *pb.RetractRequest) (*pb.BidResponse, error) {
+	return s.execute(ctx, s.state.RetractUpdate(req))
+}
+
+// Register works like Bid: only once we've been promoted to primary
+func (s *BackupServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.BidResponse, error) {
+	return s.execute(ctx, s.state.RegisterUpdate(req))
+}
+
+// ApproveBidder works like Bid: only once we've been promoted to primary
+func (s *BackupServer) ApproveBidder(ctx context.Context, req *pb.ApproveRequest) (*pb.BidResponse, error) {
+	return s.execute(ctx, s.state.ApproveUpdate(req))
+}
+
+// CancelAuction works like Bid - only once promoted to primary
+func (s *BackupServer) CancelAuction(ctx context.Context, req *pb.CancelRequest) (*pb.BidResponse, error) {
+	return s.execute(ctx, s.state.CancelUpdate(req))
+}
+
+// execute runs a write once we've been promoted to primary: a retry gets
+// the response decided the first time, and a new request is executed
+// without replication since we're operating with f=0.
+func (s *BackupServer) execute(ctx context.Context, update *pb.UpdateRequest) (*pb.BidResponse, error) {
+	stages := tracing.NewStages(ctx, "lock")
+	defer stages.End()
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	
+
 	// If we're not primary, reject
 	if !s.isPrimary {
-		return &pb.BidResponse{
-			Outcome: pb.Outcome_EXCEPTION,
-			Message: "bids must be directed to primary",
-		}, nil
+		return nil, auction.NotPrimaryError(s.primaryAddr)
 	}
-	
-	// We're now primary - handle bid directly
+
 	// Stage 2: Coordination - check for duplicate request
-	if cachedResponse, exists := s.processedRequests[req.RequestId]; exists {
-		log.Printf("Duplicate request %s, returning cached response", req.RequestId)
+	stages.Next("dedup")
+	if cachedResponse, exists := s.state.Processed(update.RequestId); exists {
+		s.logger.InfoContext(ctx, "Duplicate request, returning cached response")
+		s.metrics.DedupHit()
 		return cachedResponse, nil
 	}
 
-	// Stage 3: Execution (no replication since we're operating with f=0)
-	outcome := s.auctionState.PlaceBid(req.ClientId, req.Amount, time.Now())
-	
-	response := &pb.BidResponse{
-		Outcome: outcome,
-		Message: outcomeMessage(outcome, req.Amount),
-	}
-	
-	s.processedRequests[req.RequestId] = response
-	
-	log.Printf("Processing bid as PRIMARY: %s bid %d, outcome: %s", req.ClientId, req.Amount, outcome)
+	// Stage 3: Execution
+	stages.Next("execution")
+	response := s.state.Execute(update)
+
+	stages.Next("response")
+	s.logger.InfoContext(ctx, "Processed request as primary", "type", update.Type.String(), "client_id", update.ClientId, "outcome", update.Outcome.String())
 
 	return response, nil
 }
 
-func outcomeMessage(outcome pb.Outcome, amount int32) string {
-	switch outcome {
-	case pb.Outcome_SUCCESS:
-		return fmt.Sprintf("bid of %d accepted", amount)
-	case pb.Outcome_FAIL:
-		return "bid rejected - too low or auction closed"
-	case pb.Outcome_EXCEPTION:
-		return "invalid bid amount"
-	default:
-		return "unknown outcome"
+// SetFaults replaces the fault-injection rules on the calls this server
+// receives. The backup makes no replication calls, so it has no rules for
+// them.
+func (s *BackupServer) SetFaults(ctx context.Context, req *pb.SetFaultsRequest) (*pb.FaultsResponse, error) {
+	if !s.state.IsAdmin(req.RequestedBy, rbac.SetFaults) {
+		return nil, status.Errorf(codes.PermissionDenied, "%q may not set faults", req.RequestedBy)
+	}
+	if req.Replication {
+		return nil, status.Error(codes.FailedPrecondition, "the backup makes no replication calls")
+	}
+	if s.faults == nil {
+		return nil, status.Error(codes.FailedPrecondition, "fault injection is not enabled")
+	}
+
+	if err := s.faults.Set(req.Rules); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.logger.InfoContext(ctx, "Fault injection rules set", "by", req.RequestedBy, "rules", faults.FormatRules(req.Rules))
+	return &pb.FaultsResponse{Rules: s.faults.Rules()}, nil
+}
+
+// SetRateLimits replaces the rate limits on the AuctionService calls this
+// server receives. Only admins may change them, and only on a server with
+// a rate limiter. Limits are not replicated.
+func (s *BackupServer) SetRateLimits(ctx context.Context, req *pb.SetRateLimitsRequest) (*pb.RateLimits, error) {
+	if !s.state.IsAdmin(req.RequestedBy, rbac.SetRateLimits) {
+		return nil, status.Errorf(codes.PermissionDenied, "%q may not set rate limits", req.RequestedBy)
+	}
+	if s.rateLimits == nil {
+		return nil, status.Error(codes.FailedPrecondition, "rate limiting is not enabled")
+	}
+
+	if err := s.rateLimits.Set(req.Limits); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.logger.InfoContext(ctx, "Rate limits set", "by", req.RequestedBy, "limits", ratelimit.FormatLimits(req.Limits))
+	return s.rateLimits.Limits(), nil
+}
+
+// TransferLeadership is only served by the primary: the backup has
+// nothing to hand over, and once it has taken over it has no peer to hand
+// over to.
+func (s *BackupServer) TransferLeadership(ctx context.Context, req *pb.TransferLeadershipRequest) (*pb.TransferLeadershipResponse, error) {
+	if !s.state.IsAdmin(req.RequestedBy, rbac.TransferLeadership) {
+		return nil, status.Errorf(codes.PermissionDenied, "%q may not transfer leadership", req.RequestedBy)
+	}
+	if !s.IsPrimary() {
+		return nil, auction.NotPrimaryError(s.primaryAddr)
+	}
+	return nil, status.Error(codes.FailedPrecondition, "there is no backup to hand over to")
+}
+
+// runScheduler opens and closes the auction on schedule while this backup
+// is serving as primary. Transitions the old primary already replicated
+// are recorded in the auction state, so they are never fired twice.
+func (s *BackupServer) runScheduler() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isPrimary {
+		s.fireDueTransition()
+	}
+}
+
+// fireDueTransition records a transition that has come due. Must be
+// called with s.mutex held.
+func (s *BackupServer) fireDueTransition() {
+	update, due := s.state.DueTransition()
+	if !due {
+		return
+	}
+
+	response := s.state.Execute(update)
+	s.logger.InfoContext(logging.WithRequestID(context.Background(), update.RequestId), "Scheduler: "+response.Message, "transition", update.Type.String())
+}
+
+// deliverSettlement delivers the recorded settlement, whether this backup
+// settled the auction or the old primary replicated it, while serving as
+// primary. Sinks are called outside the lock.
+func (s *BackupServer) deliverSettlement() {
+	s.mutex.Lock()
+	event, settled := s.state.Auction().Settled()
+	isPrimary := s.isPrimary
+	s.mutex.Unlock()
+
+	if !isPrimary {
+		return
+	}
+	if settled {
+		s.outbox.Add(event)
 	}
+	s.outbox.Flush()
 }
@@ -1,235 +1,419 @@
-package main
+package client
 
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/logging"
 	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"github.com/joachimblom-hanssen/Distributed_5/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type AuctionClient struct {
-	client         pb.AuctionServiceClient
-	conn           *grpc.ClientConn
-	currentServer  string
-	primaryAddr    string
-	backupAddr     string
+	client        pb.AuctionServiceClient
+	admin         pb.AdminServiceClient
+	conn          *grpc.ClientConn
+	currentServer string
+	primaryAddr   string
+	backupAddr    string
+	currency      string // empty means the auction's own currency
+	dialOptions   []grpc.DialOption
+	recorder      *Recorder // nil unless recording
+	name          string    // the client's name in recorded operations
 }
 
-func NewAuctionClient(primaryAddress, backupAddress string) (*AuctionClient, error) {
+// NewAuctionClient connects to the primary, or to the backup if the primary
+// is down. opts are added to the options used to dial either server.
+func NewAuctionClient(primaryAddress, backupAddress string, opts ...grpc.DialOption) (*AuctionClient, error) {
 	client := &AuctionClient{
 		primaryAddr: primaryAddress,
 		backupAddr:  backupAddress,
+		dialOptions: opts,
 	}
-	
+
 	// Try to connect to primary first
 	if err := client.connectToServer(primaryAddress); err != nil {
-		log.Printf("Failed to connect to primary, trying backup: %v", err)
+		slog.Warn("Failed to connect to primary, trying backup", "err", err)
 		// If primary fails, try backup
 		if err := client.connectToServer(backupAddress); err != nil {
-			return nil, fmt.Errorf("failed to connect to both primary and backup: %v", err)
+			return nil, fmt.Errorf("failed to connect to both primary and backup: %w", err)
 		}
 	}
-	
+
 	return client, nil
 }
 
 func (c *AuctionClient) connectToServer(address string) error {
-	conn, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(3*time.Second))
+	opts := append([]grpc.DialOption{grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(3 * time.Second)}, c.dialOptions...)
+	conn, err := grpc.Dial(address, opts...)
 	if err != nil {
 		return err
 	}
-	
+
 	// Close old connection if exists
 	if c.conn != nil {
 		c.conn.Close()
 	}
-	
+
 	c.conn = conn
 	c.client = pb.NewAuctionServiceClient(conn)
+	c.admin = pb.NewAdminServiceClient(conn)
 	c.currentServer = address
-	log.Printf("Connected to server at %s", address)
-	
+	slog.Info("Connected to server", "address", address)
+
 	return nil
 }
 
+// SetCurrency sets the currency bid amounts are given in. Empty means the
+// auction's own currency.
+func (c *AuctionClient) SetCurrency(currency string) {
+	c.currency = currency
+}
+
+// Record adds every auction call from now on, writes and reads, to
+// recorder under name, so the history can be checked for linearizability.
+// Admin calls that do not touch the auction, such as SetFaults, are not
+// recorded.
+func (c *AuctionClient) Record(recorder *Recorder, name string) {
+	c.recorder = recorder
+	c.name = name
+}
+
+// recordWrite records a call that returned a BidResponse.
+func (c *AuctionClient) recordWrite(op Operation, response *pb.BidResponse, err error) {
+	if c.recorder == nil {
+		return
+	}
+	op.Client, op.Return = c.name, time.Now()
+	if err != nil {
+		op.Err = err.Error()
+	} else {
+		op.Outcome, op.Reason = response.Outcome, response.Reason
+	}
+	c.recorder.add(op)
+}
+
 func (c *AuctionClient) Close() {
 	if c.conn != nil {
 		c.conn.Close()
 	}
 }
 
-func (c *AuctionClient) PlaceBid(clientID string, amount int32) (*pb.BidResponse, error) {
+// PlaceBid bids amount, in minor units of the client's currency, per unit
+func (c *AuctionClient) PlaceBid(clientID string, amount int64, quantity int32) (*pb.BidResponse, error) {
 	requestID := fmt.Sprintf("%s-%d", clientID, time.Now().UnixNano())
-	
+
 	request := &pb.BidRequest{
 		Amount:    amount,
 		ClientId:  clientID,
 		RequestId: requestID,
+		Quantity:  quantity,
+		Currency:  c.currency,
 	}
-	
-	// Try with retry logic
-	return c.executeWithFailover(func(client pb.AuctionServiceClient) (*pb.BidResponse, error) {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+
+	call := time.Now()
+	response, err := c.write("PlaceBid", requestID, func(ctx context.Context, client pb.AuctionServiceClient) (*pb.BidResponse, error) {
 		return client.Bid(ctx, request)
-	})
+	}, attribute.String("auction.bidder", clientID), attribute.Int64("auction.amount", amount))
+	c.recordWrite(Operation{Kind: OpBid, Bidder: clientID, Amount: amount, Quantity: quantity, Call: call}, response, err)
+	return response, err
+}
+
+// BuyNow asks to take the whole lot at the auction's buy-now price
+func (c *AuctionClient) BuyNow(clientID string) (*pb.BidResponse, error) {
+	request := &pb.BuyNowRequest{
+		ClientId:  clientID,
+		RequestId: fmt.Sprintf("%s-%d", clientID, time.Now().UnixNano()),
+	}
+
+	call := time.Now()
+	response, err := c.write("BuyNow", request.RequestId, func(ctx context.Context, client pb.AuctionServiceClient) (*pb.BidResponse, error) {
+		return client.BuyNow(ctx, request)
+	}, attribute.String("auction.bidder", clientID))
+	c.recordWrite(Operation{Kind: OpBuyNow, Bidder: clientID, Call: call}, response, err)
+	return response, err
+}
+
+// RetractBid withdraws the latest bid of clientID. requestedBy is the
+// caller; it only differs from clientID when an admin retracts for someone.
+func (c *AuctionClient) RetractBid(clientID, requestedBy string) (*pb.BidResponse, error) {
+	request := &pb.RetractRequest{
+		ClientId:    clientID,
+		RequestId:   fmt.Sprintf("%s-%d", requestedBy, time.Now().UnixNano()),
+		RequestedBy: requestedBy,
+	}
+
+	call := time.Now()
+	response, err := c.write("RetractBid", request.RequestId, func(ctx context.Context, client pb.AuctionServiceClient) (*pb.BidResponse, error) {
+		return client.RetractBid(ctx, request)
+	}, attribute.String("auction.bidder", clientID))
+	c.recordWrite(Operation{Kind: OpRetract, Bidder: clientID, Call: call}, response, err)
+	return response, err
+}
+
+// Register signs clientID up to bid, with the servers' default credit limit
+func (c *AuctionClient) Register(clientID string) (*pb.BidResponse, error) {
+	request := &pb.RegisterRequest{
+		ClientId:  clientID,
+		RequestId: fmt.Sprintf("%s-%d", clientID, time.Now().UnixNano()),
+	}
+
+	call := time.Now()
+	response, err := c.write("Register", request.RequestId, func(ctx context.Context, client pb.AuctionServiceClient) (*pb.BidResponse, error) {
+		return client.Register(ctx, request)
+	}, attribute.String("auction.bidder", clientID))
+	c.recordWrite(Operation{Kind: OpRegister, Bidder: clientID, Call: call}, response, err)
+	return response, err
+}
+
+// ApproveBidder lets a registered bidder start bidding with a credit limit
+// in minor units, or the one they registered with if it is 0; approvedBy
+// must be an admin
+func (c *AuctionClient) ApproveBidder(clientID, approvedBy string, creditLimit int64) (*pb.BidResponse, error) {
+	request := &pb.ApproveRequest{
+		ClientId:    clientID,
+		RequestId:   fmt.Sprintf("%s-%d", approvedBy, time.Now().UnixNano()),
+		ApprovedBy:  approvedBy,
+		CreditLimit: creditLimit,
+	}
+
+	call := time.Now()
+	response, err := c.write("ApproveBidder", request.RequestId, func(ctx context.Context, _ pb.AuctionServiceClient) (*pb.BidResponse, error) {
+		return c.admin.ApproveBidder(ctx, request)
+	}, attribute.String("auction.bidder", clientID))
+	c.recordWrite(Operation{Kind: OpApprove, Bidder: clientID, Amount: creditLimit, Call: call}, response, err)
+	return response, err
+}
+
+// CancelAuction calls the auction off; cancelledBy must be an admin
+func (c *AuctionClient) CancelAuction(cancelledBy string) (*pb.BidResponse, error) {
+	request := &pb.CancelRequest{
+		RequestId:   fmt.Sprintf("%s-%d", cancelledBy, time.Now().UnixNano()),
+		CancelledBy: cancelledBy,
+	}
+
+	call := time.Now()
+	response, err := c.write("CancelAuction", request.RequestId, func(ctx context.Context, _ pb.AuctionServiceClient) (*pb.BidResponse, error) {
+		return c.admin.CancelAuction(ctx, request)
+	}, attribute.String("auction.cancelled_by", cancelledBy))
+	c.recordWrite(Operation{Kind: OpCancel, Call: call}, response, err)
+	return response, err
 }
 
 func (c *AuctionClient) GetResult() (*pb.ResultResponse, error) {
-	return c.executeResultWithFailover(func(client pb.AuctionServiceClient) (*pb.ResultResponse, error) {
+	call := time.Now()
+	response, err := c.executeResultWithFailover(func(client pb.AuctionServiceClient) (*pb.ResultResponse, error) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		return client.Result(ctx, &pb.ResultRequest{})
 	})
+
+	if c.recorder != nil {
+		op := Operation{Client: c.name, Kind: OpResult, Call: call, Return: time.Now()}
+		if err != nil {
+			op.Err = err.Error()
+		} else {
+			op.Status, op.HighestBid, op.Winner = response.Status, response.HighestBid, response.Winner
+		}
+		c.recorder.add(op)
+	}
+	return response, err
 }
 
-// executeWithFailover tries the operation and falls back to backup if primary fails
-func (c *AuctionClient) executeWithFailover(operation func(pb.AuctionServiceClient) (*pb.BidResponse, error)) (*pb.BidResponse, error) {
-	response, err := operation(c.client)
-	
+// SetFaults replaces the fault-injection rules of the server the client is
+// connected to, or of its calls to the backup if replication is set.
+// requestedBy must be an admin.
+func (c *AuctionClient) SetFaults(requestedBy string, rules []*pb.FaultRule, replication bool) (*pb.FaultsResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return c.admin.SetFaults(ctx, &pb.SetFaultsRequest{
+		RequestedBy: requestedBy,
+		Rules:       rules,
+		Replication: replication,
+	})
+}
+
+// SetRateLimits replaces the rate limits of the server the client is
+// connected to. requestedBy must be an admin.
+func (c *AuctionClient) SetRateLimits(requestedBy string, limits *pb.RateLimits) (*pb.RateLimits, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return c.admin.SetRateLimits(ctx, &pb.SetRateLimitsRequest{
+		RequestedBy: requestedBy,
+		Limits:      limits,
+	})
+}
+
+// TransferLeadership asks the server the client is connected to, which
+// must be the primary, to hand over to the backup, and switches the
+// client to the new primary. requestedBy must be an admin.
+func (c *AuctionClient) TransferLeadership(requestedBy string) (*pb.TransferLeadershipResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	response, err := c.admin.TransferLeadership(ctx, &pb.TransferLeadershipRequest{RequestedBy: requestedBy})
+	if err != nil {
+		return nil, err
+	}
+	if response.Leader != c.currentServer {
+		if err := c.connectToServer(response.Leader); err != nil {
+			return nil, fmt.Errorf("leadership moved to %s, but connecting to it failed: %w", response.Leader, err)
+		}
+	}
+	return response, nil
+}
+
+// GetHistory returns every bid with the primary-assigned sequence and timestamp
+func (c *AuctionClient) GetHistory() (*pb.HistoryResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	call := time.Now()
+	response, err := c.client.History(ctx, &pb.HistoryRequest{})
+
+	if c.recorder != nil {
+		op := Operation{Client: c.name, Kind: OpHistory, Call: call, Return: time.Now()}
+		if err != nil {
+			op.Err = err.Error()
+		} else {
+			for _, record := range response.Bids {
+				op.Bids = append(op.Bids, BidRecord{
+					Bidder:    record.Bidder,
+					Amount:    record.Amount,
+					Quantity:  record.Quantity,
+					Retracted: record.Retracted,
+					BuyNow:    record.BuyNow,
+				})
+			}
+		}
+		c.recorder.add(op)
+	}
+
 	if err != nil {
-		log.Printf("Request failed on %s: %v", c.currentServer, err)
-		
+		slog.Warn("Request failed", "server", c.currentServer, "err", err)
+		return nil, err
+	}
+	return response, nil
+}
+
+// write sends a write with executeWithFailover. One span, named name,
+// covers every attempt, so retries and failover show up in the same
+// trace; each attempt gets its own timeout.
+func (c *AuctionClient) write(name, requestID string, send func(context.Context, pb.AuctionServiceClient) (*pb.BidResponse, error), attributes ...attribute.KeyValue) (*pb.BidResponse, error) {
+	attributes = append(attributes, attribute.String("auction.request_id", requestID))
+	ctx, span := tracing.Start(logging.WithRequestID(context.Background(), requestID), name, attributes...)
+	defer span.End()
+
+	response, err := c.executeWithFailover(ctx, func(client pb.AuctionServiceClient) (*pb.BidResponse, error) {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		return send(ctx, client)
+	})
+	if err != nil {
+		tracing.Fail(span, err)
+	} else {
+		span.SetAttributes(attribute.String("auction.outcome", response.Outcome.String()))
+	}
+	return response, err
+}
+
+// maxAttempts bounds how often a write is tried across both servers
+const maxAttempts = 3
+
+// executeWithFailover tries the operation and falls back to backup if primary fails.
+// A server that is up but cannot take the write right now (it is not the
+// primary, or replication failed) says so with retry info and possibly a
+// leader hint; the request is then retried with the same request ID after
+// the delay it asked for, on the leader if one was named. Errors wrap the
+// server's status, so status.Code and the auction package's helpers still
+// see its code and details.
+func (c *AuctionClient) executeWithFailover(ctx context.Context, operation func(pb.AuctionServiceClient) (*pb.BidResponse, error)) (*pb.BidResponse, error) {
+	response, err := operation(c.client)
+
+	for attempt := 1; err != nil && attempt < maxAttempts; attempt++ {
+		if refused(err) {
+			return nil, err
+		}
+		slog.WarnContext(ctx, "Request failed", "server", c.currentServer, "attempt", attempt, "err", err)
+
 		// Determine which server to try next
 		nextServer := c.backupAddr
 		if c.currentServer == c.backupAddr {
 			nextServer = c.primaryAddr
 		}
-		
-		log.Printf("Attempting failover to %s", nextServer)
-		
+		if delay, retryable := auction.RetryAfter(err); retryable {
+			nextServer = c.currentServer
+			if leader := auction.LeaderHint(err); leader != "" {
+				nextServer = leader
+			}
+			time.Sleep(delay)
+		}
+
 		// Try to reconnect to other server
-		if err := c.connectToServer(nextServer); err != nil {
-			return nil, fmt.Errorf("failover failed: %v", err)
+		if nextServer != c.currentServer {
+			slog.InfoContext(ctx, "Attempting failover", "server", nextServer)
+			if err := c.connectToServer(nextServer); err != nil {
+				return nil, fmt.Errorf("failover failed: %w", err)
+			}
 		}
-		
-		// Retry operation on new server
+
+		// Retry operation
 		response, err = operation(c.client)
-		if err != nil {
-			return nil, fmt.Errorf("operation failed on failover server: %v", err)
+		if err == nil {
+			slog.InfoContext(ctx, "Retry successful", "server", c.currentServer)
 		}
-		
-		log.Println("Failover successful")
 	}
-	
+
+	if err != nil {
+		return nil, fmt.Errorf("operation failed after %d attempts: %w", maxAttempts, err)
+	}
+
 	return response, nil
 }
 
 func (c *AuctionClient) executeResultWithFailover(operation func(pb.AuctionServiceClient) (*pb.ResultResponse, error)) (*pb.ResultResponse, error) {
 	response, err := operation(c.client)
-	
-	if err != nil {
-		log.Printf("Request failed on %s: %v", c.currentServer, err)
-		
+
+	if err != nil && !refused(err) {
+		slog.Warn("Request failed", "server", c.currentServer, "err", err)
+
 		// Determine which server to try next
 		nextServer := c.backupAddr
 		if c.currentServer == c.backupAddr {
 			nextServer = c.primaryAddr
 		}
-		
-		log.Printf("Attempting failover to %s", nextServer)
-		
+
+		slog.Info("Attempting failover", "server", nextServer)
+
 		// Try to reconnect to other server
 		if err := c.connectToServer(nextServer); err != nil {
-			return nil, fmt.Errorf("failover failed: %v", err)
+			return nil, fmt.Errorf("failover failed: %w", err)
 		}
-		
+
 		// Retry operation on new server
 		response, err = operation(c.client)
 		if err != nil {
-			return nil, fmt.Errorf("operation failed on failover server: %v", err)
+			return nil, fmt.Errorf("operation failed on failover server: %w", err)
 		}
-		
-		log.Println("Failover successful")
-	}
-	
-	return response, nil
-}
-
-func runTestScenarios(client *AuctionClient) {
-	fmt.Println("\n=== Auction System Test ===\n")
-
-	fmt.Println("--- Scenario 1: Normal Bidding ---")
-	placeBidAndLog(client, "Alice", 100)
-	time.Sleep(500 * time.Millisecond)
-	
-	placeBidAndLog(client, "Bob", 150)
-	time.Sleep(500 * time.Millisecond)
-	
-	placeBidAndLog(client, "Charlie", 200)
-	time.Sleep(500 * time.Millisecond)
-
-	getResultAndLog(client)
-
-	fmt.Println("\n--- Scenario 2: Invalid Bids ---")
-	placeBidAndLog(client, "David", 150)
-	placeBidAndLog(client, "Eve", -50)
-	placeBidAndLog(client, "Frank", 0)
-
-	fmt.Println("\n--- Scenario 3: Same Bidder Multiple Bids ---")
-	placeBidAndLog(client, "Alice", 250)
-	time.Sleep(500 * time.Millisecond)
-	
-	placeBidAndLog(client, "Alice", 240)
-	placeBidAndLog(client, "Alice", 300)
-
-	fmt.Println("\n--- Final Result ---")
-	getResultAndLog(client)
-
-	fmt.Println("\n=== Testing Primary Crash Resilience ===")
-	fmt.Println("Now you can crash the primary (Ctrl+C in primary terminal)")
-	fmt.Println("The client will automatically failover to backup")
-	time.Sleep(2 * time.Second)
-	
-	fmt.Println("\n--- After Primary Crash ---")
-	placeBidAndLog(client, "Grace", 350)
-	placeBidAndLog(client, "Henry", 400)
-	
-	getResultAndLog(client)
-}
-
-func placeBidAndLog(client *AuctionClient, bidder string, amount int32) {
-	response, err := client.PlaceBid(bidder, amount)
-	if err != nil {
-		log.Printf("Error placing bid: %v", err)
-		return
-	}
-
-	outcomeStr := outcomeToString(response.Outcome)
-	fmt.Printf("%s bid %d: %s - %s\n", bidder, amount, outcomeStr, response.Message)
-}
 
-func getResultAndLog(client *AuctionClient) {
-	result, err := client.GetResult()
-	if err != nil {
-		log.Printf("Error getting result: %v", err)
-		return
+		slog.Info("Failover successful", "server", c.currentServer)
 	}
 
-	statusStr := "ONGOING"
-	if result.Status == pb.AuctionStatus_CLOSED {
-		statusStr = "CLOSED"
-	}
-
-	fmt.Printf("Auction Status: %s\n", statusStr)
-	fmt.Printf("Highest Bid: %d\n", result.HighestBid)
-	if result.Winner != "" {
-		fmt.Printf("Current Leader: %s\n", result.Winner)
-	}
+	return response, err
 }
 
-func outcomeToString(outcome pb.Outcome) string {
-	switch outcome {
-	case pb.Outcome_SUCCESS:
-		return "SUCCESS"
-	case pb.Outcome_FAIL:
-		return "FAIL"
-	case pb.Outcome_EXCEPTION:
-		return "EXCEPTION"
-	default:
-		return "UNKNOWN"
-	}
+// refused reports whether the server turned the caller away, which no
+// retry or other server changes
+func refused(err error) bool {
+	code := status.Code(err)
+	return code == codes.Unauthenticated || code == codes.PermissionDenied
 }
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+)
+
+// Operation kinds a Recorder keeps.
+const (
+	OpBid      = "bid"
+	OpBuyNow   = "buy-now"
+	OpRetract  = "retract"
+	OpRegister = "register"
+	OpApprove  = "approve"
+	OpCancel   = "cancel"
+	OpResult   = "result"
+	OpHistory  = "history"
+)
+
+// Operation is one call as the client saw it: what it asked, what it was
+// told and when the call started and returned.
+type Operation struct {
+	Client string `json:"client"` // the recording client
+	Kind   string `json:"kind"`
+
+	// Input. Bidder is the bidder a call is about: the one bidding, buying,
+	// retracting, registering or being approved.
+	Bidder   string `json:"bidder,omitempty"`
+	Amount   int64  `json:"amount,omitempty"`
+	Quantity int32  `json:"quantity,omitempty"`
+
+	// Output of the writes
+	Outcome pb.Outcome      `json:"outcome,omitempty"`
+	Reason  pb.RejectReason `json:"reason,omitempty"`
+
+	// Result output
+	Status     pb.AuctionStatus `json:"status,omitempty"`
+	HighestBid int64            `json:"highest_bid,omitempty"`
+	Winner     string           `json:"winner,omitempty"`
+
+	// History output
+	Bids []BidRecord `json:"bids,omitempty"`
+
+	// Err is set if the call failed. A failed bid may or may not have
+	// taken effect.
+	Err string `json:"err,omitempty"`
+
+	Call   time.Time `json:"call"`
+	Return time.Time `json:"return"`
+}
+
+// BidRecord is one entry of a History read. Sequence and timestamp are
+// left out: they are the primary's, not something a client can check.
+type BidRecord struct {
+	Bidder    string `json:"bidder"`
+	Amount    int64  `json:"amount"`
+	Quantity  int32  `json:"quantity"`
+	Retracted bool   `json:"retracted,omitempty"`
+	BuyNow    bool   `json:"buy_now,omitempty"`
+}
+
+// Recorder collects the operations of any number of clients. It is safe
+// for concurrent use.
+type Recorder struct {
+	mutex      sync.Mutex
+	operations []Operation
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) add(op Operation) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.operations = append(r.operations, op)
+}
+
+// Operations returns everything recorded so far, in the order the calls
+// started.
+func (r *Recorder) Operations() []Operation {
+	r.mutex.Lock()
+	operations := append([]Operation(nil), r.operations...)
+	r.mutex.Unlock()
+
+	sort.SliceStable(operations, func(i, j int) bool {
+		return operations[i].Call.Before(operations[j].Call)
+	})
+	return operations
+}
+
+// WriteOperations writes operations as a JSON array, to be checked later
+// with ReadOperations.
+func WriteOperations(w io.Writer, operations []Operation) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(operations)
+}
+
+// ReadOperations reads operations written by WriteOperations.
+func ReadOperations(r io.Reader) ([]Operation, error) {
+	var operations []Operation
+	if err := json.NewDecoder(r).Decode(&operations); err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
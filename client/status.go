@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// NodeStatus is what one server reports about itself.
+type NodeStatus struct {
+	Address string
+	Status  *pb.ClusterStatus
+	// Serving is the health status of AuctionService on the server: it is
+	// only SERVING on the primary.
+	Serving healthpb.HealthCheckResponse_ServingStatus
+}
+
+// GetNodeStatus asks the server at address for its cluster status and
+// whether it serves AuctionService. opts are added to the options used
+// to dial it.
+func GetNodeStatus(address string, opts ...grpc.DialOption) (*NodeStatus, error) {
+	conn, err := grpc.Dial(address, append([]grpc.DialOption{grpc.WithInsecure()}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	status, err := pb.NewAdminServiceClient(conn).GetClusterStatus(ctx, &pb.ClusterStatusRequest{})
+	if err != nil {
+		return nil, err
+	}
+	health, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: pb.AuctionService_ServiceDesc.ServiceName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &NodeStatus{Address: address, Status: status, Serving: health.Status}, nil
+}
+
+// GetClusterStatus returns the cluster status of the server the client is
+// connected to.
+func (c *AuctionClient) GetClusterStatus() (*pb.ClusterStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return c.admin.GetClusterStatus(ctx, &pb.ClusterStatusRequest{})
+}
@@ -0,0 +1,154 @@
+// Package clock lets servers read the time and run periodic work through an
+// interface, so a simulation can replace the wall clock with a fake one that
+// only moves when told to.
+package clock
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and runs callbacks after or every interval.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls fn once, d from now, unless stop is called first.
+	AfterFunc(d time.Duration, fn func()) (stop func())
+	// Every calls fn every d until stop is called.
+	Every(d time.Duration, fn func()) (stop func())
+}
+
+// Real is the wall clock. Callbacks run on their own goroutines.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, fn func()) func() {
+	timer := time.AfterFunc(d, fn)
+	return func() { timer.Stop() }
+}
+
+func (realClock) Every(d time.Duration, fn func()) func() {
+	ticker := time.NewTicker(d)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
+
+// Fake is a clock that only moves on Advance. Callbacks run synchronously
+// inside Advance, in order of their due time and then of registration, so
+// a run driven by a Fake is deterministic.
+type Fake struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers timerHeap
+	seq    uint64
+}
+
+// NewFake returns a fake clock reading start.
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+func (f *Fake) AfterFunc(d time.Duration, fn func()) func() {
+	return f.schedule(d, 0, fn)
+}
+
+func (f *Fake) Every(d time.Duration, fn func()) func() {
+	return f.schedule(d, d, fn)
+}
+
+func (f *Fake) schedule(d, period time.Duration, fn func()) func() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.seq++
+	t := &fakeTimer{when: f.now.Add(d), period: period, seq: f.seq, fn: fn}
+	heap.Push(&f.timers, t)
+	return func() {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		t.stopped = true
+	}
+}
+
+// Advance moves the clock forward by d, running every callback that falls
+// due on the way at its due time.
+func (f *Fake) Advance(d time.Duration) {
+	f.mutex.Lock()
+	end := f.now.Add(d)
+	for len(f.timers) > 0 && !f.timers[0].when.After(end) {
+		t := heap.Pop(&f.timers).(*fakeTimer)
+		if t.stopped {
+			continue
+		}
+		f.now = t.when
+		if t.period > 0 {
+			f.seq++
+			t.when, t.seq = t.when.Add(t.period), f.seq
+			heap.Push(&f.timers, t)
+		}
+
+		f.mutex.Unlock()
+		t.fn()
+		f.mutex.Lock()
+	}
+	f.now = end
+	f.mutex.Unlock()
+}
+
+type fakeTimer struct {
+	when    time.Time
+	period  time.Duration
+	seq     uint64
+	fn      func()
+	stopped bool
+}
+
+type timerHeap []*fakeTimer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if !h[i].when.Equal(h[j].when) {
+		return h[i].when.Before(h[j].when)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h timerHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *timerHeap) Push(x interface{}) { *h = append(*h, x.(*fakeTimer)) }
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return t
+}
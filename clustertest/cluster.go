@@ -0,0 +1,401 @@
+// Package clustertest runs a primary, a backup and any number of clients
+// in one process, connected over in-memory bufconn listeners, so failover,
+// deduplication and replication can be exercised by go test.
+//
+// Nodes can be killed, restarted, paused and partitioned from each other:
+//
+//	c := clustertest.New(t, clustertest.Options{})
+//	bidder, _ := c.NewClient()
+//	bidder.PlaceBid("alice", 100, 1)
+//	c.Primary.Kill()
+//	c.WaitForPromotion(10 * time.Second)
+//	bidder.PlaceBid("bob", 150, 1) // fails over to the backup
+package clustertest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/backup"
+	"github.com/joachimblom-hanssen/Distributed_5/client"
+	"github.com/joachimblom-hanssen/Distributed_5/logging"
+	"github.com/joachimblom-hanssen/Distributed_5/primary"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Node addresses. They only mean something to the cluster's dialer.
+const (
+	PrimaryAddr = "primary"
+	BackupAddr  = "backup"
+)
+
+const bufSize = 1 << 20
+
+// Options configures the nodes. Both nodes get the same configuration,
+// as they would from the same command-line flags.
+type Options struct {
+	Config              auction.Config
+	StartTime           time.Time // zero means when the cluster starts
+	Admins              []string
+	RequireRegistration bool
+	RequireApproval     bool
+	CreditLimit         int64
+	Sinks               []auction.SettlementSink
+}
+
+// Cluster is an in-process primary and backup.
+type Cluster struct {
+	Primary *Node
+	Backup  *Node
+
+	opts       Options
+	mutex      sync.Mutex
+	nodes      map[string]*Node
+	partitions map[[2]string]bool
+	clients    int
+}
+
+// New starts a backup and then a primary replicating to it. The cluster is
+// shut down when the test ends.
+func New(t testing.TB, opts Options) *Cluster {
+	t.Helper()
+
+	c, err := Start(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// Start is New outside a test, e.g. for a benchmark command. The caller
+// must Close the cluster.
+func Start(opts Options) (*Cluster, error) {
+	if opts.StartTime.IsZero() {
+		opts.StartTime = time.Now()
+	}
+
+	c := &Cluster{
+		opts:       opts,
+		nodes:      make(map[string]*Node),
+		partitions: make(map[[2]string]bool),
+	}
+	c.Backup = c.addNode(BackupAddr)
+	c.Primary = c.addNode(PrimaryAddr)
+
+	if err := c.Backup.Restart(); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("start backup: %v", err)
+	}
+	if err := c.Primary.Restart(); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("start primary: %v", err)
+	}
+	return c, nil
+}
+
+func (c *Cluster) addNode(name string) *Node {
+	n := &Node{Name: name, cluster: c}
+	c.nodes[name] = n
+	return n
+}
+
+// NewClient returns a client of the cluster with failover between primary
+// and backup. Each client is a separate endpoint that can be partitioned
+// by its Name.
+func (c *Cluster) NewClient() (*Client, error) {
+	c.mutex.Lock()
+	c.clients++
+	name := fmt.Sprintf("client-%d", c.clients)
+	c.mutex.Unlock()
+
+	auctionClient, err := client.NewAuctionClient(PrimaryAddr, BackupAddr, c.DialOptions(name)...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{AuctionClient: auctionClient, Name: name}, nil
+}
+
+// Client is an AuctionClient with the name the cluster knows it by.
+type Client struct {
+	*client.AuctionClient
+	Name string
+}
+
+// Conn opens a raw connection to the node for an endpoint called from, for
+// calls the AuctionClient does not make, such as replication or repeating
+// a request ID.
+func (c *Cluster) Conn(from, to string) (*grpc.ClientConn, error) {
+	opts := append([]grpc.DialOption{grpc.WithInsecure()}, c.DialOptions(from)...)
+	return grpc.Dial(to, opts...)
+}
+
+// DialOptions connects an endpoint called from to cluster nodes. Calls are
+// refused while the target is down or partitioned from from, and held
+// while from is a paused node.
+func (c *Cluster) DialOptions(from string) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, to string) (net.Conn, error) {
+			return c.dial(ctx, from, to)
+		}),
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			if n, ok := c.node(from); ok {
+				if err := n.waitIfPaused(ctx); err != nil {
+					return err
+				}
+			}
+			if c.Partitioned(from, cc.Target()) {
+				return status.Errorf(codes.Unavailable, "%s is partitioned from %s", from, cc.Target())
+			}
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}),
+	}
+}
+
+func (c *Cluster) dial(ctx context.Context, from, to string) (net.Conn, error) {
+	n, ok := c.node(to)
+	if !ok {
+		return nil, fmt.Errorf("unknown node %q", to)
+	}
+	if c.Partitioned(from, to) {
+		return nil, fmt.Errorf("%s is partitioned from %s", from, to)
+	}
+
+	n.mutex.Lock()
+	listener := n.listener
+	n.mutex.Unlock()
+	if listener == nil {
+		return nil, fmt.Errorf("connection to %s refused: node is down", to)
+	}
+	return listener.DialContext(ctx)
+}
+
+func (c *Cluster) node(name string) (*Node, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	n, ok := c.nodes[name]
+	return n, ok
+}
+
+// Partition cuts the link between endpoints a and b in both directions.
+// Endpoints are node addresses or client names.
+func (c *Cluster) Partition(a, b string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.partitions[link(a, b)] = true
+}
+
+// Heal restores the link between a and b.
+func (c *Cluster) Heal(a, b string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.partitions, link(a, b))
+}
+
+// HealAll restores every link.
+func (c *Cluster) HealAll() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.partitions = make(map[[2]string]bool)
+}
+
+// Partitioned reports whether a and b are cut off from each other.
+func (c *Cluster) Partitioned(a, b string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.partitions[link(a, b)]
+}
+
+func link(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// WaitForPromotion waits until the backup has taken over as primary.
+func (c *Cluster) WaitForPromotion(timeout time.Duration) error {
+	return Eventually(timeout, func() bool {
+		server := c.Backup.BackupServer()
+		return server != nil && server.IsPrimary()
+	})
+}
+
+// Close stops every node.
+func (c *Cluster) Close() {
+	c.Primary.Kill()
+	c.Backup.Kill()
+}
+
+// Eventually polls cond until it holds or timeout passes.
+func Eventually(timeout time.Duration, cond func() bool) error {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("condition not met within %v", timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+// Node is a primary or backup server that can be killed, restarted and
+// paused.
+type Node struct {
+	Name    string
+	cluster *Cluster
+
+	mutex    sync.Mutex
+	listener *bufconn.Listener
+	server   *grpc.Server
+	primary  *primary.PrimaryServer
+	backup   *backup.BackupServer
+	resumed  chan struct{} // non-nil while paused
+}
+
+// Kill stops the node as if its process crashed. Its state is lost.
+func (n *Node) Kill() {
+	n.mutex.Lock()
+	server, primaryServer, backupServer := n.server, n.primary, n.backup
+	n.listener, n.server, n.primary, n.backup = nil, nil, nil, nil
+	if n.resumed != nil {
+		close(n.resumed)
+		n.resumed = nil
+	}
+	n.mutex.Unlock()
+
+	if server != nil {
+		server.Stop()
+	}
+	if primaryServer != nil {
+		primaryServer.Close()
+	}
+	if backupServer != nil {
+		backupServer.Close()
+	}
+}
+
+// Restart kills the node if it is running and starts it again as a fresh
+// process with empty state.
+func (n *Node) Restart() error {
+	n.Kill()
+
+	c := n.cluster
+	opts := c.opts
+	registry := auction.NewRegistry(opts.RequireRegistration, opts.RequireApproval, opts.CreditLimit)
+	server := grpc.NewServer(append(logging.ServerOptions(), grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := n.waitIfPaused(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}))...)
+
+	var primaryServer *primary.PrimaryServer
+	var backupServer *backup.BackupServer
+	switch n.Name {
+	case PrimaryAddr:
+		var err error
+		primaryServer, err = primary.NewPrimaryServer(primary.Options{
+			BackupAddress: BackupAddr,
+			StartTime:     opts.StartTime,
+			Config:        opts.Config,
+			Registry:      registry,
+			Admins:        opts.Admins,
+			Sinks:         opts.Sinks,
+			DialOptions:   c.DialOptions(n.Name),
+		})
+		if err != nil {
+			return err
+		}
+		pb.RegisterAuctionServiceServer(server, primaryServer)
+		pb.RegisterAdminServiceServer(server, primaryServer)
+	default:
+		backupServer = backup.NewBackupServer(backup.Options{
+			StartTime:      opts.StartTime,
+			Config:         opts.Config,
+			Registry:       registry,
+			Admins:         opts.Admins,
+			Sinks:          opts.Sinks,
+			PrimaryAddress: PrimaryAddr,
+		})
+		pb.RegisterReplicationServiceServer(server, backupServer)
+		pb.RegisterAuctionServiceServer(server, backupServer)
+		pb.RegisterAdminServiceServer(server, backupServer)
+	}
+
+	listener := bufconn.Listen(bufSize)
+	go server.Serve(listener)
+
+	n.mutex.Lock()
+	n.listener, n.server, n.primary, n.backup = listener, server, primaryServer, backupServer
+	n.mutex.Unlock()
+	return nil
+}
+
+// Pause holds every call to and from the node until Resume, as if its
+// process were stopped. Calls give up when their deadline passes.
+func (n *Node) Pause() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.resumed == nil {
+		n.resumed = make(chan struct{})
+	}
+}
+
+// Resume lets held calls through again.
+func (n *Node) Resume() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.resumed != nil {
+		close(n.resumed)
+		n.resumed = nil
+	}
+}
+
+func (n *Node) waitIfPaused(ctx context.Context) error {
+	n.mutex.Lock()
+	resumed := n.resumed
+	n.mutex.Unlock()
+	if resumed == nil {
+		return nil
+	}
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// IsUp reports whether the node is running.
+func (n *Node) IsUp() bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.server != nil
+}
+
+// PrimaryServer is the running primary, or nil if the node is down or is
+// the backup.
+func (n *Node) PrimaryServer() *primary.PrimaryServer {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.primary
+}
+
+// BackupServer is the running backup, or nil if the node is down or is
+// the primary.
+func (n *Node) BackupServer() *backup.BackupServer {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.backup
+}
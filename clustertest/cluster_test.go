@@ -0,0 +1,491 @@
+package clustertest_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/clustertest"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// promotionTimeout covers the 5 seconds of missed heartbeats after which
+// the backup takes over, and the second it checks on.
+const promotionTimeout = 10 * time.Second
+
+// connect opens an AuctionService connection from the endpoint called
+// from to the node to, for calls that need a chosen request ID or node.
+func connect(t *testing.T, c *clustertest.Cluster, from, to string) (pb.AuctionServiceClient, pb.AdminServiceClient) {
+	t.Helper()
+	conn, err := c.Conn(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewAuctionServiceClient(conn), pb.NewAdminServiceClient(conn)
+}
+
+func bid(service pb.AuctionServiceClient, requestID, clientID string, amount int64) (*pb.BidResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return service.Bid(ctx, &pb.BidRequest{RequestId: requestID, ClientId: clientID, Amount: amount})
+}
+
+func mustBid(t *testing.T, service pb.AuctionServiceClient, requestID, clientID string, amount int64) *pb.BidResponse {
+	t.Helper()
+	response, err := bid(service, requestID, clientID, amount)
+	if err != nil {
+		t.Fatalf("bid %s: %v", requestID, err)
+	}
+	if response.Outcome != pb.Outcome_SUCCESS {
+		t.Fatalf("bid %s: %v %v", requestID, response.Outcome, response.Reason)
+	}
+	return response
+}
+
+// requireSameState fails unless the backup has applied every update the
+// primary executed and its history is the primary's. The backup does not
+// serve Result until it has taken over, so the sequences stand in for it.
+func requireSameState(t *testing.T, c *clustertest.Cluster) {
+	t.Helper()
+	primary, primaryAdmin := connect(t, c, "checker", clustertest.PrimaryAddr)
+	backup, backupAdmin := connect(t, c, "checker", clustertest.BackupAddr)
+	ctx := context.Background()
+
+	primaryHistory, err := primary.History(ctx, &pb.HistoryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupHistory, err := backup.History(ctx, &pb.HistoryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(primaryHistory, backupHistory) {
+		t.Fatalf("backup history differs from the primary's:\nprimary %v\nbackup  %v", primaryHistory, backupHistory)
+	}
+
+	primaryStatus, err := primaryAdmin.GetClusterStatus(ctx, &pb.ClusterStatusRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupStatus, err := backupAdmin.GetClusterStatus(ctx, &pb.ClusterStatusRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primaryStatus.LastAppliedSequence != backupStatus.LastAppliedSequence {
+		t.Fatalf("backup applied updates up to %d, the primary executed up to %d", backupStatus.LastAppliedSequence, primaryStatus.LastAppliedSequence)
+	}
+}
+
+func TestFailoverAfterPrimaryDies(t *testing.T) {
+	t.Parallel()
+	c := clustertest.New(t, clustertest.Options{})
+	bidder, err := c.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bidder.Close()
+
+	if response, err := bidder.PlaceBid("alice", 100, 1); err != nil || response.Outcome != pb.Outcome_SUCCESS {
+		t.Fatalf("bid before the crash: %v %v", response, err)
+	}
+
+	c.Primary.Kill()
+	if err := c.WaitForPromotion(promotionTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	if response, err := bidder.PlaceBid("bob", 150, 1); err != nil || response.Outcome != pb.Outcome_SUCCESS {
+		t.Fatalf("bid after the crash: %v %v", response, err)
+	}
+	result, err := bidder.GetResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Winner != "bob" || result.HighestBid != 150 {
+		t.Fatalf("got winner %q at %d, want bob at 150", result.Winner, result.HighestBid)
+	}
+	history, err := bidder.GetHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Bids) != 2 {
+		t.Fatalf("got %d bids in the history, want the one from before the crash and the one after", len(history.Bids))
+	}
+}
+
+func TestRepeatedRequestIDReturnsCachedResponse(t *testing.T) {
+	t.Parallel()
+	c := clustertest.New(t, clustertest.Options{})
+	primary, _ := connect(t, c, "bidder", clustertest.PrimaryAddr)
+
+	first := mustBid(t, primary, "alice-1", "alice", 100)
+	again := mustBid(t, primary, "alice-1", "alice", 200)
+	if !proto.Equal(first, again) {
+		t.Fatalf("repeated request got %v, want the cached %v", again, first)
+	}
+
+	// The backup answers the retry the same way once it has taken over
+	c.Primary.Kill()
+	if err := c.WaitForPromotion(promotionTimeout); err != nil {
+		t.Fatal(err)
+	}
+	backup, _ := connect(t, c, "bidder", clustertest.BackupAddr)
+	afterFailover := mustBid(t, backup, "alice-1", "alice", 300)
+	if afterFailover.Sequence != first.Sequence || afterFailover.HighestBid != 100 {
+		t.Fatalf("retry on the backup got %v, want sequence %d and highest bid 100", afterFailover, first.Sequence)
+	}
+}
+
+func TestBackupMatchesPrimaryAfterReplication(t *testing.T) {
+	t.Parallel()
+	c := clustertest.New(t, clustertest.Options{Admins: []string{"root"}})
+	primary, _ := connect(t, c, "bidder", clustertest.PrimaryAddr)
+
+	mustBid(t, primary, "alice-1", "alice", 100)
+	mustBid(t, primary, "bob-1", "bob", 150)
+	mustBid(t, primary, "alice-2", "alice", 200)
+	if response, err := primary.RetractBid(context.Background(), &pb.RetractRequest{RequestId: "alice-3", ClientId: "alice"}); err != nil || response.Outcome != pb.Outcome_SUCCESS {
+		t.Fatalf("retract: %v %v", response, err)
+	}
+	if response, err := bid(primary, "carol-1", "carol", 10); err != nil || response.Outcome == pb.Outcome_SUCCESS {
+		t.Fatalf("a bid below the highest should be rejected: %v %v", response, err)
+	}
+
+	requireSameState(t, c)
+}
+
+// A write that could not be replicated must reach the backup before any
+// later one, or the backup applies the later one without it and loses it
+// on failover.
+func TestFailedReplicationKeepsUpdatesInOrder(t *testing.T) {
+	t.Parallel()
+	c := clustertest.New(t, clustertest.Options{})
+	primary, _ := connect(t, c, "bidder", clustertest.PrimaryAddr)
+
+	c.Partition(clustertest.PrimaryAddr, clustertest.BackupAddr)
+	if _, err := bid(primary, "alice-1", "alice", 100); status.Code(err) != codes.Unavailable {
+		t.Fatalf("bid while the backup is unreachable: got %v, want Unavailable", err)
+	}
+	c.Heal(clustertest.PrimaryAddr, clustertest.BackupAddr)
+
+	mustBid(t, primary, "bob-1", "bob", 150)
+	if response := mustBid(t, primary, "alice-1", "alice", 100); response.HighestBid != 100 {
+		t.Fatalf("retry got %v, want the response decided before bob's bid", response)
+	}
+	requireSameState(t, c)
+
+	// Nothing acknowledged is lost when the backup takes over
+	c.Primary.Kill()
+	if err := c.WaitForPromotion(promotionTimeout); err != nil {
+		t.Fatal(err)
+	}
+	backup, _ := connect(t, c, "bidder", clustertest.BackupAddr)
+	history, err := backup.History(context.Background(), &pb.HistoryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Bids) != 2 {
+		t.Fatalf("got %d bids on the backup after failover, want 2", len(history.Bids))
+	}
+}
+
+// A write the backup has not acknowledged must not be visible to readers,
+// who would otherwise see it lost if the primary then failed.
+func TestResultHidesUnreplicatedBid(t *testing.T) {
+	t.Parallel()
+	c := clustertest.New(t, clustertest.Options{})
+	primary, _ := connect(t, c, "bidder", clustertest.PrimaryAddr)
+	ctx := context.Background()
+
+	mustBid(t, primary, "alice-1", "alice", 100)
+	c.Partition(clustertest.PrimaryAddr, clustertest.BackupAddr)
+	if _, err := bid(primary, "bob-1", "bob", 150); status.Code(err) != codes.Unavailable {
+		t.Fatalf("bid while the backup is unreachable: got %v, want Unavailable", err)
+	}
+	result, err := primary.Result(ctx, &pb.ResultRequest{})
+	if err == nil {
+		t.Fatalf("result while bob's bid is unreplicated: got %v, want an error", result)
+	}
+
+	// Once the backup has the bid, readers see it
+	c.Heal(clustertest.PrimaryAddr, clustertest.BackupAddr)
+	result, err = primary.Result(ctx, &pb.ResultRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.HighestBid != 150 {
+		t.Fatalf("got highest bid %d after the partition healed, want 150", result.HighestBid)
+	}
+	requireSameState(t, c)
+}
+
+func TestPausedBackupCatchesUp(t *testing.T) {
+	t.Parallel()
+	c := clustertest.New(t, clustertest.Options{})
+	primary, _ := connect(t, c, "bidder", clustertest.PrimaryAddr)
+
+	mustBid(t, primary, "alice-1", "alice", 100)
+	c.Backup.Pause()
+	if _, err := bid(primary, "bob-1", "bob", 150); err == nil {
+		t.Fatal("bid while the backup is paused should fail")
+	}
+	c.Backup.Resume()
+
+	mustBid(t, primary, "carol-1", "carol", 200)
+	requireSameState(t, c)
+}
+
+func TestRestartedBackupIsBroughtUpToDate(t *testing.T) {
+	t.Parallel()
+	c := clustertest.New(t, clustertest.Options{})
+	primary, _ := connect(t, c, "bidder", clustertest.PrimaryAddr)
+
+	mustBid(t, primary, "alice-1", "alice", 100)
+	mustBid(t, primary, "bob-1", "bob", 150)
+	if err := c.Backup.Restart(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The backup refuses updates it cannot apply in order until the
+	// primary has resent everything from the start
+	err := clustertest.Eventually(5*time.Second, func() bool {
+		_, err := bid(primary, "carol-1", "carol", 200)
+		return err == nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireSameState(t, c)
+}
+
+// The backup's state can lag the primary's until it takes over, so it
+// does not answer Result before then.
+func TestBackupRefusesResultUntilPromoted(t *testing.T) {
+	t.Parallel()
+	c := clustertest.New(t, clustertest.Options{})
+	primary, _ := connect(t, c, "bidder", clustertest.PrimaryAddr)
+	backup, _ := connect(t, c, "bidder", clustertest.BackupAddr)
+
+	mustBid(t, primary, "alice-1", "alice", 100)
+	if result, err := backup.Result(context.Background(), &pb.ResultRequest{}); status.Code(err) != codes.Unavailable || auction.LeaderHint(err) != clustertest.PrimaryAddr {
+		t.Fatalf("result from the backup: got %v %v, want a redirect to the primary", result, err)
+	}
+
+	c.Primary.Kill()
+	if err := c.WaitForPromotion(promotionTimeout); err != nil {
+		t.Fatal(err)
+	}
+	result, err := backup.Result(context.Background(), &pb.ResultRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.HighestBid != 100 {
+		t.Fatalf("got highest bid %d from the promoted backup, want 100", result.HighestBid)
+	}
+}
+
+// A primary cut off from the backup cannot replicate, so it accepts no
+// writes once the backup has taken over, and steps down when the
+// partition heals.
+func TestPartitionedPrimaryStepsDown(t *testing.T) {
+	t.Parallel()
+	c := clustertest.New(t, clustertest.Options{})
+	primary, primaryAdmin := connect(t, c, "bidder", clustertest.PrimaryAddr)
+	backup, _ := connect(t, c, "bidder", clustertest.BackupAddr)
+
+	mustBid(t, primary, "alice-1", "alice", 100)
+	c.Partition(clustertest.PrimaryAddr, clustertest.BackupAddr)
+	if err := c.WaitForPromotion(promotionTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bid(primary, "bob-1", "bob", 150); err == nil {
+		t.Fatal("the old primary accepted a write it could not replicate")
+	}
+	mustBid(t, backup, "carol-1", "carol", 200)
+
+	c.Heal(clustertest.PrimaryAddr, clustertest.BackupAddr)
+	err := clustertest.Eventually(5*time.Second, func() bool {
+		status, err := primaryAdmin.GetClusterStatus(context.Background(), &pb.ClusterStatusRequest{})
+		return err == nil && status.Role == pb.Role_ROLE_NONE && status.Epoch == 2
+	})
+	if err != nil {
+		t.Fatalf("old primary did not step down: %v", err)
+	}
+
+	_, err = bid(primary, "bob-1", "bob", 150)
+	if status.Code(err) != codes.Unavailable || auction.LeaderHint(err) != clustertest.BackupAddr {
+		t.Fatalf("write to the old primary: got %v, want a redirect to the backup", err)
+	}
+	history, err := backup.History(context.Background(), &pb.HistoryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Bids) != 2 {
+		t.Fatalf("got %d bids on the new primary, want alice's and carol's", len(history.Bids))
+	}
+}
+
+// A primary that restarts empty has lost what the backup applied, so the
+// backup takes over rather than follow it.
+func TestRestartedPrimaryDoesNotServe(t *testing.T) {
+	t.Parallel()
+	c := clustertest.New(t, clustertest.Options{})
+	bidder, err := c.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bidder.Close()
+
+	if response, err := bidder.PlaceBid("alice", 100, 1); err != nil || response.Outcome != pb.Outcome_SUCCESS {
+		t.Fatalf("bid before the restart: %v %v", response, err)
+	}
+	if err := c.Primary.Restart(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Its empty auction is never shown to readers
+	primary, primaryAdmin := connect(t, c, "checker", clustertest.PrimaryAddr)
+	if result, err := primary.Result(context.Background(), &pb.ResultRequest{}); status.Code(err) != codes.Unavailable || auction.LeaderHint(err) != clustertest.BackupAddr {
+		t.Fatalf("result from the restarted primary: got %v %v, want a redirect to the backup", result, err)
+	}
+
+	if err := c.WaitForPromotion(promotionTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	err = clustertest.Eventually(5*time.Second, func() bool {
+		status, err := primaryAdmin.GetClusterStatus(context.Background(), &pb.ClusterStatusRequest{})
+		return err == nil && status.Role == pb.Role_ROLE_NONE
+	})
+	if err != nil {
+		t.Fatalf("restarted primary did not step down: %v", err)
+	}
+
+	result, err := bidder.GetResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Winner != "alice" || result.HighestBid != 100 {
+		t.Fatalf("got winner %q at %d after the restart, want alice at 100", result.Winner, result.HighestBid)
+	}
+}
+
+// flakySink fails its first failures publishes and records the events it
+// accepts.
+type flakySink struct {
+	mutex    sync.Mutex
+	failures int
+	events   []auction.AuctionSettled
+}
+
+func (s *flakySink) Publish(event auction.AuctionSettled) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink unavailable")
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *flakySink) Events() []auction.AuctionSettled {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]auction.AuctionSettled(nil), s.events...)
+}
+
+func TestSettlementIsRetriedUntilDelivered(t *testing.T) {
+	t.Parallel()
+	sink := &flakySink{failures: 2}
+	// The auction ends two seconds after the cluster starts
+	c := clustertest.New(t, clustertest.Options{
+		StartTime: time.Now().Add(2*time.Second - auction.AuctionDuration),
+		Sinks:     []auction.SettlementSink{sink},
+	})
+	primary, _ := connect(t, c, "alice", clustertest.PrimaryAddr)
+	mustBid(t, primary, "alice-1", "alice", 100)
+
+	err := clustertest.Eventually(10*time.Second, func() bool {
+		return len(sink.Events()) > 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := sink.Events()
+	if len(events) != 1 || events[0].Winner != "alice" || events[0].Price != 100 {
+		t.Fatalf("settlement events %+v, want one won by alice at 100", events)
+	}
+	requireSameState(t, c)
+}
+
+func TestCreditLimitIsSetByConfigOrAdmin(t *testing.T) {
+	t.Parallel()
+	c := clustertest.New(t, clustertest.Options{
+		Admins:              []string{"auctioneer"},
+		RequireRegistration: true,
+		RequireApproval:     true,
+		CreditLimit:         100,
+	})
+	service, admin := connect(t, c, "client", clustertest.PrimaryAddr)
+	ctx := context.Background()
+
+	for _, bidder := range []string{"alice", "bob"} {
+		if _, err := service.Register(ctx, &pb.RegisterRequest{RequestId: bidder + "-register", ClientId: bidder}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	approve := func(bidder string, creditLimit int64) {
+		response, err := admin.ApproveBidder(ctx, &pb.ApproveRequest{RequestId: bidder + "-approve", ClientId: bidder, ApprovedBy: "auctioneer", CreditLimit: creditLimit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Outcome != pb.Outcome_SUCCESS {
+			t.Fatalf("approve %s: %v %v", bidder, response.Outcome, response.Reason)
+		}
+	}
+	approve("alice", 0)
+	approve("bob", 500)
+
+	// alice keeps the configured limit, bob has the one the admin set
+	response, err := bid(service, "alice-1", "alice", 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Reason != pb.RejectReason_CREDIT_LIMIT_EXCEEDED {
+		t.Fatalf("bid over the configured limit: %v %v, want CREDIT_LIMIT_EXCEEDED", response.Outcome, response.Reason)
+	}
+	mustBid(t, service, "bob-1", "bob", 400)
+	requireSameState(t, c)
+}
+
+func TestOverflowingBidIsRejected(t *testing.T) {
+	t.Parallel()
+	c := clustertest.New(t, clustertest.Options{
+		Config:              auction.Config{Quantity: 10},
+		RequireRegistration: true,
+		CreditLimit:         1000,
+	})
+	service, _ := connect(t, c, "client", clustertest.PrimaryAddr)
+	ctx := context.Background()
+	if _, err := service.Register(ctx, &pb.RegisterRequest{RequestId: "alice-register", ClientId: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2^62 per unit for 4 units wraps around to 0 in int64, under any limit
+	response, err := service.Bid(ctx, &pb.BidRequest{RequestId: "alice-1", ClientId: "alice", Amount: 1 << 62, Quantity: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Outcome == pb.Outcome_SUCCESS || response.Reason != pb.RejectReason_INVALID_AMOUNT {
+		t.Fatalf("overflowing bid: %v %v, want INVALID_AMOUNT", response.Outcome, response.Reason)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/auth"
+	"github.com/joachimblom-hanssen/Distributed_5/client"
+	"github.com/joachimblom-hanssen/Distributed_5/faults"
+	"github.com/joachimblom-hanssen/Distributed_5/logging"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"github.com/joachimblom-hanssen/Distributed_5/ratelimit"
+	"github.com/joachimblom-hanssen/Distributed_5/tlsconfig"
+	"github.com/joachimblom-hanssen/Distributed_5/tracing"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	primaryAddr := flag.String("primary", "localhost:5001", "primary server address")
+	backupAddr := flag.String("backup", "localhost:5002", "backup server address")
+	currency := flag.String("currency", "", "currency of bid amounts (default: the auction's currency)")
+	registerBidders := flag.Bool("register", false, "register each bidder before bidding")
+	approver := flag.String("approve-as", "", "admin ID used to approve registered bidders")
+	creditLimit := flag.Int64("credit-limit", 0, "credit limit the -approve-as admin gives each bidder (default: the servers' -credit-limit)")
+	canceller := flag.String("cancel-as", "", "admin ID used to cancel the auction after the bids are placed")
+	faultSpec := flag.String("faults", "", "fault-injection rules for calls this client makes, e.g. Bid:drop-reply@0.2")
+	setFaults := flag.String("set-faults", "", "fault-injection rules to set on the server before bidding (needs -faults-as)")
+	setReplicationFaults := flag.String("set-replication-faults", "", "fault-injection rules to set on the primary's calls to the backup (needs -faults-as)")
+	faultsAs := flag.String("faults-as", "", "admin ID used to set fault-injection rules")
+	traceExporter := flag.String("trace", "", "export OpenTelemetry traces: otlp, otlp:<host:port> or file:<path> (default: off)")
+	showStatus := flag.Bool("status", false, "print the cluster status reported by the primary and the backup, and exit")
+	setRateLimits := flag.String("set-rate-limits", "", "rate limits to set on the server, e.g. client=5:10,concurrent=32, or none (needs -rate-limits-as)")
+	rateLimitsAs := flag.String("rate-limits-as", "", "admin ID used to set rate limits; exits afterwards")
+	transferAs := flag.String("transfer-leadership-as", "", "admin ID used to hand the primary role over to the backup; exits afterwards")
+	nodeID := flag.String("node-id", "client", "name of this client in its logs")
+	logFormat := flag.String("log-format", "json", "log format: json or text")
+	logLevel := flag.String("log-level", "info", "lowest level logged: debug, info, warn or error")
+	tlsCA := flag.String("tls-ca", "", "PEM file of the CA that signed the servers' certificates; turns on TLS (default: off)")
+	tlsCert := flag.String("tls-cert", "", "PEM file of a client certificate to present, signed by the cluster CA")
+	tlsKey := flag.String("tls-key", "", "PEM file of the key of -tls-cert")
+	tokenFile := flag.String("token-file", "", "file holding the token to authenticate with (needs -tls-ca)")
+	bid := flag.Int64("bid", 0, "place one bid of this amount as the bidder the token authenticates, and exit (needs -token-file)")
+	flag.Parse()
+
+	handler, err := logging.FromFlags(os.Stderr, *logFormat, *logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logging.NewNode(*nodeID, "client", 0).Logger(slog.New(handler)))
+
+	shutdownTracing, err := tracing.Setup("auction-client", *traceExporter)
+	if err != nil {
+		fatal("Invalid trace exporter", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	tlsConfig, err := tlsconfig.FromFlags(tlsconfig.Files{Cert: *tlsCert, Key: *tlsKey, CA: *tlsCA}, false)
+	if err != nil {
+		fatal("Invalid TLS configuration", err)
+	}
+
+	injector, err := faults.FromFlags(*faultSpec, false)
+	if err != nil {
+		fatal("Invalid fault injection rules", err)
+	}
+
+	dialOptions := append(faults.DialOptions(injector), tracing.DialOptions()...)
+	dialOptions = append(dialOptions, tlsconfig.DialOptions(tlsConfig)...)
+	if *tokenFile != "" {
+		token, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			fatal("Failed to read token", err)
+		}
+		dialOptions = append(dialOptions, auth.DialOptions(strings.TrimSpace(string(token)))...)
+	}
+	if *showStatus {
+		printClusterStatus([]string{*primaryAddr, *backupAddr}, dialOptions)
+		return
+	}
+
+	client, err := client.NewAuctionClient(*primaryAddr, *backupAddr, dialOptions...)
+	if err != nil {
+		fatal("Failed to create client", err)
+	}
+	defer client.Close()
+	client.SetCurrency(*currency)
+
+	if *rateLimitsAs != "" {
+		setServerRateLimits(client, *rateLimitsAs, *setRateLimits)
+		return
+	}
+	if *transferAs != "" {
+		transferLeadership(client, *transferAs)
+		return
+	}
+	if *bid != 0 {
+		placeBid(client, "", *bid) // the server takes the bidder from the token
+		return
+	}
+
+	if *faultsAs != "" {
+		setServerFaults(client, *faultsAs, *setFaults, false)
+		setServerFaults(client, *faultsAs, *setReplicationFaults, true)
+	}
+
+	if *registerBidders {
+		for _, bidder := range []string{"Alice", "Bob", "Charlie", "David", "Eve"} {
+			register(client, bidder, *creditLimit, *approver)
+		}
+	}
+
+	placeBid(client, "Alice", 100)
+	time.Sleep(500 * time.Millisecond)
+
+	placeBid(client, "Bob", 150)
+	time.Sleep(500 * time.Millisecond)
+
+	placeBid(client, "Charlie", 200)
+	time.Sleep(500 * time.Millisecond)
+
+	fmt.Println("\nKill primary now (Ctrl+C in primary terminal), then press Enter")
+	fmt.Scanln()
+
+	placeBid(client, "David", 250)
+	placeBid(client, "Eve", 300)
+
+	if *canceller != "" {
+		cancelAuction(client, *canceller)
+	}
+
+	getResult(client)
+	printHistory(client)
+}
+
+func register(client *client.AuctionClient, bidder string, creditLimit int64, approver string) {
+	response, err := client.Register(bidder)
+	if err != nil {
+		slog.Error("Request failed", "err", err)
+		return
+	}
+	fmt.Printf("%s: %s\n", bidder, response.Message)
+
+	if approver == "" {
+		return
+	}
+	response, err = client.ApproveBidder(bidder, approver, creditLimit)
+	if err != nil {
+		slog.Error("Request failed", "err", err)
+		return
+	}
+	fmt.Printf("%s: %s\n", bidder, response.Message)
+}
+
+func setServerFaults(client *client.AuctionClient, admin, spec string, replication bool) {
+	if spec == "" {
+		return
+	}
+	rules, err := faults.ParseRules(spec)
+	if err != nil {
+		fatal("Invalid fault injection rules", err)
+	}
+	response, err := client.SetFaults(admin, rules, replication)
+	if err != nil {
+		slog.Error("Request failed", "err", err)
+		return
+	}
+	fmt.Printf("Fault injection rules set: %s\n", faults.FormatRules(response.Rules))
+}
+
+func setServerRateLimits(client *client.AuctionClient, admin, spec string) {
+	limits, err := ratelimit.ParseLimits(spec)
+	if err != nil {
+		fatal("Invalid rate limits", err)
+	}
+	response, err := client.SetRateLimits(admin, limits)
+	if err != nil {
+		fatal("Failed to set rate limits", err)
+	}
+	fmt.Printf("Rate limits set: %s\n", ratelimit.FormatLimits(response))
+}
+
+func transferLeadership(client *client.AuctionClient, admin string) {
+	response, err := client.TransferLeadership(admin)
+	if err != nil {
+		fatal("Failed to transfer leadership", err)
+	}
+	fmt.Printf("Leadership transferred to %s, epoch %d\n", response.Leader, response.Epoch)
+}
+
+func placeBid(client *client.AuctionClient, bidder string, amount int64) {
+	response, err := client.PlaceBid(bidder, amount, 1)
+	if err != nil {
+		slog.Error("Request failed", "err", err)
+		return
+	}
+	if bidder == "" {
+		bidder = "You"
+	}
+	if response.Outcome != pb.Outcome_SUCCESS {
+		fmt.Printf("%s bid %d: %s (%s)\n", bidder, amount, response.Outcome, response.Reason)
+		return
+	}
+	fmt.Printf("%s bid %d: %s\n", bidder, amount, response.Outcome)
+}
+
+func cancelAuction(client *client.AuctionClient, canceller string) {
+	response, err := client.CancelAuction(canceller)
+	if err != nil {
+		slog.Error("Request failed", "err", err)
+		return
+	}
+	fmt.Printf("%s: %s\n", canceller, response.Message)
+}
+
+func getResult(client *client.AuctionClient) {
+	result, err := client.GetResult()
+	if err != nil {
+		slog.Error("Request failed", "err", err)
+		return
+	}
+	if result.Status != pb.AuctionStatus_ONGOING && result.Status != pb.AuctionStatus_CLOSED {
+		fmt.Printf("Auction %s\n", result.Status)
+		return
+	}
+	fmt.Printf("Winner: %s with %s\n", result.Winner, auction.FormatAmount(result.HighestBid, result.Currency))
+	for _, allocation := range result.Allocations {
+		fmt.Printf("  %s wins %d unit(s) at %s\n", allocation.Bidder, allocation.Quantity, auction.FormatAmount(allocation.Price, result.Currency))
+	}
+}
+
+func printHistory(client *client.AuctionClient) {
+	history, err := client.GetHistory()
+	if err != nil {
+		slog.Error("Request failed", "err", err)
+		return
+	}
+	fmt.Println("\nBid history:")
+	for _, bid := range history.Bids {
+		status := ""
+		if bid.Retracted {
+			status = " (retracted)"
+		} else if bid.BuyNow {
+			status = " (buy-now)"
+		}
+		fmt.Printf("  #%d %s %s bid %d x %d%s\n", bid.Sequence,
+			time.Unix(0, bid.Timestamp).Format(time.RFC3339Nano), bid.Bidder, bid.Amount, bid.Quantity, status)
+	}
+}
+
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
+
+// printClusterStatus prints each server's view of the cluster.
+func printClusterStatus(addresses []string, dialOptions []grpc.DialOption) {
+	for _, address := range addresses {
+		node, err := client.GetNodeStatus(address, dialOptions...)
+		if err != nil {
+			fmt.Printf("%s: unreachable (%v)\n", address, err)
+			continue
+		}
+		status := node.Status
+		fmt.Printf("%s: %s %s, epoch %d, AuctionService %s, last applied sequence %d\n",
+			address, status.NodeId, status.Role, status.Epoch, node.Serving, status.LastAppliedSequence)
+		if status.LastHeartbeat != 0 {
+			fmt.Printf("  last heartbeat %s ago\n", time.Since(time.Unix(0, status.LastHeartbeat)).Round(time.Millisecond))
+		}
+		for _, peer := range status.Peers {
+			fmt.Printf("  peer %s %s\n", peer.Address, peer.Role)
+		}
+	}
+}
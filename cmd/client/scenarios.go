@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/client"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+)
+
+func runTestScenarios(client *client.AuctionClient) {
+	fmt.Println("\n=== Auction System Test ===")
+	fmt.Println()
+
+	fmt.Println("--- Scenario 1: Normal Bidding ---")
+	placeBidAndLog(client, "Alice", 100)
+	time.Sleep(500 * time.Millisecond)
+
+	placeBidAndLog(client, "Bob", 150)
+	time.Sleep(500 * time.Millisecond)
+
+	placeBidAndLog(client, "Charlie", 200)
+	time.Sleep(500 * time.Millisecond)
+
+	getResultAndLog(client)
+
+	fmt.Println("\n--- Scenario 2: Invalid Bids ---")
+	placeBidAndLog(client, "David", 150)
+	placeBidAndLog(client, "Eve", -50)
+	placeBidAndLog(client, "Frank", 0)
+
+	fmt.Println("\n--- Scenario 3: Same Bidder Multiple Bids ---")
+	placeBidAndLog(client, "Alice", 250)
+	time.Sleep(500 * time.Millisecond)
+
+	placeBidAndLog(client, "Alice", 240)
+	placeBidAndLog(client, "Alice", 300)
+
+	fmt.Println("\n--- Final Result ---")
+	getResultAndLog(client)
+
+	fmt.Println("\n=== Testing Primary Crash Resilience ===")
+	fmt.Println("Now you can crash the primary (Ctrl+C in primary terminal)")
+	fmt.Println("The client will automatically failover to backup")
+	time.Sleep(2 * time.Second)
+
+	fmt.Println("\n--- After Primary Crash ---")
+	placeBidAndLog(client, "Grace", 350)
+	placeBidAndLog(client, "Henry", 400)
+
+	getResultAndLog(client)
+}
+
+func placeBidAndLog(client *client.AuctionClient, bidder string, amount int64) {
+	response, err := client.PlaceBid(bidder, amount, 1)
+	if err != nil {
+		slog.Error("Error placing bid", "err", err)
+		return
+	}
+
+	outcomeStr := outcomeToString(response.Outcome)
+	fmt.Printf("%s bid %d: %s - %s\n", bidder, amount, outcomeStr, response.Message)
+}
+
+func getResultAndLog(client *client.AuctionClient) {
+	result, err := client.GetResult()
+	if err != nil {
+		slog.Error("Error getting result", "err", err)
+		return
+	}
+
+	fmt.Printf("Auction Status: %s\n", result.Status)
+	if result.Status == pb.AuctionStatus_SCHEDULED {
+		fmt.Printf("Opens At: %s\n", time.Unix(0, result.StartTime).Format(time.RFC3339))
+	}
+	fmt.Printf("Highest Bid: %s\n", auction.FormatAmount(result.HighestBid, result.Currency))
+	if result.Winner != "" {
+		fmt.Printf("Current Leader: %s\n", result.Winner)
+	}
+	if result.BoughtNow {
+		fmt.Println("Sold via buy-now")
+	} else if result.BuyNowPrice > 0 {
+		fmt.Printf("Buy-now Price: %s\n", auction.FormatAmount(result.BuyNowPrice, result.Currency))
+	}
+	if result.Quantity > 1 {
+		for _, allocation := range result.Allocations {
+			fmt.Printf("  %s: %d unit(s) at %s (bid %s)\n", allocation.Bidder, allocation.Quantity,
+				auction.FormatAmount(allocation.Price, result.Currency), auction.FormatAmount(allocation.BidPrice, result.Currency))
+		}
+	}
+}
+
+func outcomeToString(outcome pb.Outcome) string {
+	switch outcome {
+	case pb.Outcome_SUCCESS:
+		return "SUCCESS"
+	case pb.Outcome_FAIL:
+		return "FAIL"
+	case pb.Outcome_EXCEPTION:
+		return "EXCEPTION"
+	default:
+		return "UNKNOWN"
+	}
+}
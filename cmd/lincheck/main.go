@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/client"
+	"github.com/joachimblom-hanssen/Distributed_5/lincheck"
+)
+
+func main() {
+	primaryAddr := flag.String("primary", "localhost:5001", "primary server address")
+	backupAddr := flag.String("backup", "localhost:5002", "backup server address")
+	clients := flag.Int("clients", 4, "number of concurrent clients")
+	operations := flag.Int("ops", 50, "operations per client")
+	quantity := flag.Int("quantity", 1, "number of units in the lot; must match the servers")
+	tiePolicy := flag.String("tie-policy", "reject-equal", "equal bids: reject-equal or earliest-wins; must match the servers")
+	buyNow := flag.Int64("buy-now", 0, "buy-now price per unit (0: no buy-now); must match the servers")
+	buyNowThreshold := flag.Int64("buy-now-threshold", 0, "highest bid that withdraws buy-now (0: the first bid); must match the servers")
+	save := flag.String("save", "", "write the recorded history to this file")
+	load := flag.String("load", "", "check a history saved with -save instead of running a workload")
+	verbose := flag.Bool("v", false, "print client logs")
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	config := auction.Config{
+		Quantity:        int32(*quantity),
+		BuyNowPrice:     *buyNow,
+		BuyNowThreshold: *buyNowThreshold,
+	}
+	var err error
+	if config.TiePolicy, err = auction.ParseTiePolicy(*tiePolicy); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid auction configuration: %v\n", err)
+		os.Exit(2)
+	}
+
+	var recorded []client.Operation
+	if *load != "" {
+		recorded, err = readHistory(*load)
+	} else {
+		recorded, err = runWorkload(*primaryAddr, *backupAddr, *clients, *operations)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if *save != "" {
+		if err := writeHistory(*save, recorded); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	model := lincheck.AuctionModel(config)
+	result := lincheck.Check(model, lincheck.FromClient(recorded))
+	if result.Ok {
+		fmt.Printf("%d operations: linearizable\n", len(recorded))
+		return
+	}
+	fmt.Printf("%d operations: NOT linearizable; minimal violating sub-history:\n\n", len(recorded))
+	lincheck.Render(os.Stdout, model, result.Violation)
+	os.Exit(1)
+}
+
+func runWorkload(primaryAddr, backupAddr string, clients, operations int) ([]client.Operation, error) {
+	var auctionClients []*client.AuctionClient
+	for i := 0; i < clients; i++ {
+		c, err := client.NewAuctionClient(primaryAddr, backupAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client: %v", err)
+		}
+		defer c.Close()
+		auctionClients = append(auctionClients, c)
+	}
+
+	return lincheck.RunWorkload(auctionClients, lincheck.WorkloadOptions{
+		Operations: operations,
+		Seed:       time.Now().UnixNano(),
+	}), nil
+}
+
+func readHistory(path string) ([]client.Operation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return client.ReadOperations(f)
+}
+
+func writeHistory(path string, recorded []client.Operation) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := client.WriteOperations(f, recorded); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
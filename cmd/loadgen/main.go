@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/client"
+	"github.com/joachimblom-hanssen/Distributed_5/clustertest"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+)
+
+// target is one auction: a primary and backup pair.
+type target interface {
+	newClient() (*client.AuctionClient, error)
+	killPrimary() error
+}
+
+// remote is an auction served by a deployed primary and backup.
+type remote struct {
+	primary, backup string
+	killCommand     string
+}
+
+func (r *remote) newClient() (*client.AuctionClient, error) {
+	return client.NewAuctionClient(r.primary, r.backup)
+}
+
+func (r *remote) killPrimary() error {
+	if r.killCommand == "" {
+		return fmt.Errorf("-kill-after needs -kill-cmd against a deployed cluster")
+	}
+	return exec.Command("sh", "-c", r.killCommand).Run()
+}
+
+// inProcess is an auction served by a clustertest cluster.
+type inProcess struct {
+	cluster *clustertest.Cluster
+}
+
+func (p *inProcess) newClient() (*client.AuctionClient, error) {
+	c, err := p.cluster.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.AuctionClient, nil
+}
+
+func (p *inProcess) killPrimary() error {
+	p.cluster.Primary.Kill()
+	return nil
+}
+
+func main() {
+	clusters := flag.String("clusters", "localhost:5001/localhost:5002", "comma-separated primary/backup address pairs, one auction each")
+	inProcessClusters := flag.Int("in-process", 0, "run this many in-process clusters, one auction each, instead of using -clusters")
+	bidders := flag.Int("bidders", 20, "number of concurrent simulated bidders")
+	duration := flag.Duration("duration", 10*time.Second, "how long to generate load")
+	resultRatio := flag.Float64("result-ratio", 0.2, "share of calls that are Result rather than Bid")
+	killAfter := flag.Duration("kill-after", 0, "kill the primary of -kill-auction this long into the run (0 never kills it)")
+	killAuction := flag.Int("kill-auction", 0, "index of the auction whose primary -kill-after kills, counting from 0 in -clusters order")
+	killCommand := flag.String("kill-cmd", "", "shell command that kills the deployed primary of -kill-auction, for -kill-after")
+	verbose := flag.Bool("v", false, "print client and server logs")
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	var targets []target
+	if *inProcessClusters > 0 {
+		for i := 0; i < *inProcessClusters; i++ {
+			cluster, err := clustertest.Start(clustertest.Options{})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to start cluster: %v\n", err)
+				os.Exit(1)
+			}
+			defer cluster.Close()
+			targets = append(targets, &inProcess{cluster: cluster})
+		}
+	} else {
+		for _, pair := range strings.Split(*clusters, ",") {
+			primaryAddr, backupAddr, ok := strings.Cut(strings.TrimSpace(pair), "/")
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Invalid cluster %q: want primary/backup\n", pair)
+				os.Exit(2)
+			}
+			targets = append(targets, &remote{primary: primaryAddr, backup: backupAddr})
+		}
+	}
+	if *killAuction < 0 || *killAuction >= len(targets) {
+		fmt.Fprintf(os.Stderr, "Invalid -kill-auction %d: want an index below %d, the number of auctions\n", *killAuction, len(targets))
+		os.Exit(2)
+	}
+	if r, ok := targets[*killAuction].(*remote); ok {
+		r.killCommand = *killCommand
+	}
+
+	results := &stats{}
+	start := time.Now()
+	deadline := start.Add(*duration)
+
+	if *killAfter > 0 {
+		timer := time.AfterFunc(*killAfter, func() {
+			if err := targets[*killAuction].killPrimary(); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to kill primary: %v\n", err)
+				return
+			}
+			results.killed(time.Now())
+		})
+		defer timer.Stop()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < *bidders; i++ {
+		wg.Add(1)
+		go func(name string, r *rand.Rand) {
+			defer wg.Done()
+			bid(name, r, targets, deadline, *resultRatio, results)
+		}(fmt.Sprintf("bidder-%d", i+1), rand.New(rand.NewSource(start.UnixNano()+int64(i))))
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	fmt.Printf("%d bidders, %d auctions, %v\n\n", *bidders, len(targets), elapsed.Round(time.Millisecond))
+	results.report(os.Stdout, start, elapsed, *killAuction)
+}
+
+// bid is one simulated bidder: until deadline it picks an auction at
+// random and either reads its result or outbids the highest bid it last
+// heard of there.
+func bid(name string, r *rand.Rand, targets []target, deadline time.Time, resultRatio float64, results *stats) {
+	clients := make([]*client.AuctionClient, len(targets))
+	highest := make([]int64, len(targets))
+	defer func() {
+		for _, c := range clients {
+			if c != nil {
+				c.Close()
+			}
+		}
+	}()
+
+	for time.Now().Before(deadline) {
+		a := r.Intn(len(targets))
+		if clients[a] == nil {
+			started := time.Now()
+			c, err := targets[a].newClient()
+			if err != nil {
+				results.add(call{kind: "Bid", auction: a, start: started, end: time.Now(), err: err})
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			clients[a] = c
+		}
+
+		started := time.Now()
+		if r.Float64() < resultRatio {
+			result, err := clients[a].GetResult()
+			results.add(call{kind: "Result", auction: a, start: started, end: time.Now(), err: err})
+			if err == nil {
+				highest[a] = result.HighestBid
+			}
+			continue
+		}
+
+		response, err := clients[a].PlaceBid(name, highest[a]+1+r.Int63n(10), 1)
+		c := call{kind: "Bid", auction: a, start: started, end: time.Now(), err: err}
+		if err == nil {
+			c.success = response.Outcome == pb.Outcome_SUCCESS
+			highest[a] = response.HighestBid
+		}
+		results.add(c)
+	}
+}
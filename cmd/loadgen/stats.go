@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// call is one Bid or Result as the load generator saw it.
+type call struct {
+	kind    string
+	auction int
+	start   time.Time
+	end     time.Time
+	err     error
+	success bool // a bid that was accepted
+}
+
+// stats collects every call of a run.
+type stats struct {
+	mutex    sync.Mutex
+	calls    []call
+	killedAt time.Time // when the primary was killed, if it was
+}
+
+func (s *stats) add(c call) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.calls = append(s.calls, c)
+}
+
+func (s *stats) killed(at time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.killedAt = at
+}
+
+// report prints throughput and latency percentiles per kind of call, for
+// the calls that succeeded and, separately, those that failed, and, if the
+// primary of killedAuction was killed, how long that auction took no bids.
+func (s *stats) report(w io.Writer, start time.Time, elapsed time.Duration, killedAuction int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	killedAt := s.killedAt
+
+	fmt.Fprintf(w, "%-7s %-7s %8s %8s %10s %10s %10s %10s %10s\n",
+		"call", "outcome", "calls", "calls/s", "p50", "p99", "p999", "max", "accepted")
+	for _, kind := range []string{"Bid", "Result"} {
+		var ok, failed []time.Duration
+		accepted := 0
+		for _, c := range s.calls {
+			if c.kind != kind {
+				continue
+			}
+			if c.err != nil {
+				failed = append(failed, c.end.Sub(c.start))
+				continue
+			}
+			ok = append(ok, c.end.Sub(c.start))
+			if c.success {
+				accepted++
+			}
+		}
+
+		acceptedColumn := "-"
+		if kind == "Bid" {
+			acceptedColumn = fmt.Sprint(accepted)
+		}
+		writeRow(w, kind, "ok", ok, elapsed, acceptedColumn)
+		writeRow(w, kind, "failed", failed, elapsed, "-")
+	}
+
+	if killedAt.IsZero() {
+		return
+	}
+	fmt.Fprintf(w, "\nprimary of auction %d killed %v into the run\n", killedAuction, killedAt.Sub(start).Round(time.Millisecond))
+
+	// A bid the old primary was already handling can still complete, so
+	// the auction is back once a bid sent after the kill succeeds.
+	var recovered time.Time
+	failed := 0
+	for _, c := range s.calls {
+		if c.kind != "Bid" || c.auction != killedAuction || c.end.Before(killedAt) {
+			continue
+		}
+		if c.err != nil {
+			failed++
+			continue
+		}
+		if c.start.After(killedAt) && (recovered.IsZero() || c.end.Before(recovered)) {
+			recovered = c.end
+		}
+	}
+	if recovered.IsZero() {
+		fmt.Fprintf(w, "no bid on auction %d succeeded after the kill (%d failed)\n", killedAuction, failed)
+		return
+	}
+	fmt.Fprintf(w, "bids unavailable for %v (%d bids failed after the kill)\n", recovered.Sub(killedAt).Round(time.Millisecond), failed)
+}
+
+// writeRow prints the count, rate and latency percentiles of one kind and
+// outcome of call.
+func writeRow(w io.Writer, kind, outcome string, latencies []time.Duration, elapsed time.Duration, accepted string) {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	fmt.Fprintf(w, "%-7s %-7s %8d %8.1f %10s %10s %10s %10s %10s\n",
+		kind, outcome, len(latencies), float64(len(latencies))/elapsed.Seconds(),
+		percentile(latencies, 0.50), percentile(latencies, 0.99), percentile(latencies, 0.999),
+		percentile(latencies, 1), accepted)
+}
+
+// percentile returns the q-th quantile of sorted latencies by the nearest
+// rank, or "-" if there are none.
+func percentile(sorted []time.Duration, q float64) string {
+	if len(sorted) == 0 {
+		return "-"
+	}
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank].Round(time.Microsecond).String()
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/auth"
+	"github.com/joachimblom-hanssen/Distributed_5/clock"
+	"github.com/joachimblom-hanssen/Distributed_5/faults"
+	"github.com/joachimblom-hanssen/Distributed_5/logging"
+	"github.com/joachimblom-hanssen/Distributed_5/metrics"
+	"github.com/joachimblom-hanssen/Distributed_5/primary"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"github.com/joachimblom-hanssen/Distributed_5/ratelimit"
+	"github.com/joachimblom-hanssen/Distributed_5/rbac"
+	"github.com/joachimblom-hanssen/Distributed_5/tlsconfig"
+	"github.com/joachimblom-hanssen/Distributed_5/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func main() {
+	port := flag.Int("port", 5001, "primary server port")
+	backupAddr := flag.String("backup", "localhost:5002", "backup server address")
+	quantity := flag.Int("quantity", 1, "number of units in the lot")
+	pricing := flag.String("pricing", "uniform", "settlement rule: uniform or pay-as-bid")
+	currency := flag.String("currency", auction.DefaultCurrency, "ISO 4217 currency code; amounts are in its minor units")
+	buyNow := flag.Int64("buy-now", 0, "buy-now price per unit (0 disables buy-now)")
+	buyNowThreshold := flag.Int64("buy-now-threshold", 0, "highest bid that withdraws buy-now (0 withdraws it after the first bid)")
+	retractFinalPeriod := flag.Duration("retract-final-period", 0, "refuse bid retractions this close to the end of the auction")
+	retractLatestOnly := flag.Bool("retract-latest-only", false, "only allow retracting the most recent bid in the auction")
+	retractAdminOnly := flag.Bool("retract-admin-only", false, "only allow admins to retract bids")
+	tiePolicy := flag.String("tie-policy", "reject-equal", "equal bids: reject-equal or earliest-wins")
+	requireRegistration := flag.Bool("require-registration", false, "only registered bidders may bid")
+	requireApproval := flag.Bool("require-approval", false, "registered bidders must be approved by an admin before bidding")
+	creditLimit := flag.Int64("credit-limit", 0, "credit limit of a registered bidder, in minor units, until an admin sets one at approval (0: no limit)")
+	admins := flag.String("admins", "", "comma-separated client IDs allowed to approve bidders, retract other bidders' bids and cancel the auction")
+	startAt := flag.String("start-at", "", "RFC 3339 time the auction opens; required, and must match on primary and backup")
+	settlementSinks := flag.String("settlement-sinks", "", "comma-separated sinks for the settlement event: stdout, file:<path> or a webhook URL")
+	faultSpec := flag.String("faults", "", "fault-injection rules for calls this server receives, e.g. Bid:delay=200ms@0.5")
+	replicationFaultSpec := flag.String("replication-faults", "", "fault-injection rules for calls to the backup, e.g. ReplicateUpdate:drop-reply@0.3")
+	faultInjection := flag.Bool("fault-injection", false, "allow admins to set fault-injection rules with AdminService.SetFaults")
+	metricsAddr := flag.String("metrics-addr", "", "serve Prometheus metrics at /metrics on this address, e.g. :9101 (default: off)")
+	traceExporter := flag.String("trace", "", "export OpenTelemetry traces: otlp, otlp:<host:port> or file:<path> (default: off)")
+	nodeID := flag.String("node-id", "primary", "name of this node in its logs")
+	logFormat := flag.String("log-format", "json", "log format: json or text")
+	logLevel := flag.String("log-level", "info", "lowest level logged: debug, info, warn or error")
+	tlsCert := flag.String("tls-cert", "", "PEM file of this node's certificate; TLS needs it, -tls-key and -tls-ca unless -insecure is given")
+	tlsKey := flag.String("tls-key", "", "PEM file of the key of -tls-cert")
+	tlsCA := flag.String("tls-ca", "", "PEM file of the cluster CA, which signs the certificates of every node")
+	insecure := flag.Bool("insecure", false, "serve without TLS, letting anyone who can reach the port replicate to this node; for local testing only")
+	authKey := flag.String("auth-key", "", "PEM file of the public key client tokens are verified against; turns on authentication (default: off)")
+	policyFile := flag.String("policy", "", "JSON file giving authenticated callers roles, in place of -admins (needs -auth-key)")
+	rateLimitSpec := flag.String("rate-limits", "", "rate limits on AuctionService calls, e.g. client=5:10,connection=50,concurrent=32; admins can change them with AdminService.SetRateLimits (default: none)")
+	drainTimeout := flag.Duration("drain-timeout", 10*time.Second, "on SIGTERM or SIGINT, how long to let calls in flight finish before stopping")
+	handOverOnStop := flag.Bool("hand-over-on-stop", true, "on SIGTERM or SIGINT, hand the primary role over to the backup before stopping")
+	flag.Parse()
+
+	handler, err := logging.FromFlags(os.Stderr, *logFormat, *logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(2)
+	}
+	node := logging.NewNode(*nodeID, metrics.RolePrimary, 1)
+	slog.SetDefault(node.Logger(slog.New(handler)))
+
+	shutdownTracing, err := tracing.Setup("auction-primary", *traceExporter)
+	if err != nil {
+		fatal("Invalid trace exporter", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	config, err := auctionConfig(*quantity, *pricing, *currency)
+	if err != nil {
+		fatal("Invalid auction configuration", err)
+	}
+	config.BuyNowPrice = *buyNow
+	config.BuyNowThreshold = *buyNowThreshold
+	if config.TiePolicy, err = auction.ParseTiePolicy(*tiePolicy); err != nil {
+		fatal("Invalid auction configuration", err)
+	}
+	config.Retraction = auction.RetractionPolicy{
+		FinalPeriod: *retractFinalPeriod,
+		LatestOnly:  *retractLatestOnly,
+		AdminOnly:   *retractAdminOnly,
+	}
+	startTime, err := auction.ParseStartTime(*startAt)
+	if err != nil {
+		fatal("Invalid auction configuration", err)
+	}
+	sinks, err := auction.ParseSettlementSinks(*settlementSinks)
+	if err != nil {
+		fatal("Invalid settlement sinks", err)
+	}
+
+	tlsConfig, err := tlsconfig.ServerFromFlags(tlsconfig.Files{Cert: *tlsCert, Key: *tlsKey, CA: *tlsCA}, *insecure)
+	if err != nil {
+		fatal("Invalid TLS configuration", err)
+	}
+	if tlsConfig == nil {
+		slog.Warn("Serving without TLS; anyone who can reach the port can replicate to this node")
+	}
+	stopWatching := tlsConfig.Watch(clock.Real, tlsconfig.ReloadInterval)
+	defer stopWatching()
+
+	verifier, err := auth.FromFlags(*authKey)
+	if err != nil {
+		fatal("Invalid authentication key", err)
+	}
+
+	policy, err := rbac.FromFlags(*policyFile)
+	if err != nil {
+		fatal("Invalid authorization policy", err)
+	}
+	if policy != nil && (verifier == nil || *admins != "") {
+		fatal("Invalid authorization policy", fmt.Errorf("-policy needs -auth-key and replaces -admins"))
+	}
+
+	injector, err := faults.FromFlags(*faultSpec, *faultInjection)
+	if err != nil {
+		fatal("Invalid fault injection rules", err)
+	}
+	replicationInjector, err := faults.FromFlags(*replicationFaultSpec, *faultInjection)
+	if err != nil {
+		fatal("Invalid fault injection rules", err)
+	}
+
+	limiter, err := ratelimit.FromFlags(*rateLimitSpec)
+	if err != nil {
+		fatal("Invalid rate limits", err)
+	}
+
+	var serverMetrics *metrics.Metrics
+	if *metricsAddr != "" {
+		serverMetrics = metrics.New(metrics.RolePrimary)
+		go func() {
+			fatal("Failed to serve metrics", metrics.ListenAndServe(*metricsAddr, serverMetrics))
+		}()
+		slog.Info("Serving metrics at /metrics", "address", *metricsAddr)
+	}
+
+	healthServer := health.NewServer()
+	registry := auction.NewRegistry(*requireRegistration, *requireApproval, *creditLimit)
+	primaryServer, err := primary.NewPrimaryServer(primary.Options{
+		BackupAddress: *backupAddr,
+		StartTime:     startTime,
+		Config:        config,
+		Registry:      registry,
+		Admins:        splitList(*admins),
+		Sinks:         sinks,
+		DialOptions:   append(tracing.DialOptions(), tlsconfig.DialOptions(tlsConfig)...),
+
+		Faults:            injector,
+		ReplicationFaults: replicationInjector,
+		RateLimits:        limiter,
+		Metrics:           serverMetrics,
+		Node:              node,
+		Logger:            slog.New(handler),
+		Policy:            policy,
+		Health:            healthServer,
+	})
+	if err != nil {
+		fatal("Failed to create primary server", err)
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
+	if err != nil {
+		fatal("Failed to listen", err)
+	}
+
+	serverOptions := append(tlsconfig.ServerOptions(tlsConfig), auth.ServerOptions(verifier)...)
+	serverOptions = append(serverOptions, rbac.ServerOptions(policy)...)
+	serverOptions = append(serverOptions, ratelimit.ServerOptions(limiter)...)
+	serverOptions = append(serverOptions, logging.ServerOptions()...)
+	serverOptions = append(serverOptions, faults.ServerOptions(injector)...)
+	serverOptions = append(serverOptions, tracing.ServerOptions()...)
+	grpcServer := grpc.NewServer(serverOptions...)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	pb.RegisterAuctionServiceServer(grpcServer, primaryServer)
+	pb.RegisterAdminServiceServer(grpcServer, primaryServer)
+
+	slog.Info("Primary server listening", "port", *port, "backup", *backupAddr)
+
+	stopping := make(chan os.Signal, 1)
+	signal.Notify(stopping, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			fatal("Failed to serve", err)
+		}
+	}()
+
+	// Hand over first, so writes arriving while calls in flight drain are
+	// redirected to the backup instead of refused
+	<-stopping
+	slog.Info("Shutting down")
+	if *handOverOnStop {
+		ctx, cancel := context.WithTimeout(context.Background(), *drainTimeout)
+		if _, _, err := primaryServer.HandOver(ctx); err != nil {
+			slog.Error("Failed to hand over to the backup", "err", err)
+		}
+		cancel()
+	}
+	drain(grpcServer, *drainTimeout)
+	primaryServer.Close()
+}
+
+// drain stops server once the calls in flight have finished, cutting them
+// off after timeout
+func drain(server *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		slog.Warn("Calls still in flight after the drain timeout, stopping anyway", "timeout", timeout)
+		server.Stop()
+	}
+}
+
+func auctionConfig(quantity int, pricing string, currency string) (auction.Config, error) {
+	config := auction.DefaultConfig()
+	if quantity <= 0 {
+		return config, fmt.Errorf("quantity must be positive, got %d", quantity)
+	}
+	config.Quantity = int32(quantity)
+
+	rule, err := auction.ParsePricing(pricing)
+	if err != nil {
+		return config, err
+	}
+	config.Pricing = rule
+
+	code, err := auction.ParseCurrency(currency)
+	if err != nil {
+		return config, err
+	}
+	config.Currency = code
+
+	return config, nil
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/sim"
+)
+
+func main() {
+	seed := flag.Int64("seed", 0, "replay this seed and print its trace (0 picks seeds from the clock)")
+	runs := flag.Int("runs", 100, "number of seeds to simulate")
+	steps := flag.Int("steps", 200, "steps per run")
+	crash := flag.Float64("crash", 0.01, "chance per step that the primary crashes (negative never crashes it)")
+	drop := flag.Float64("drop", sim.DefaultFaults.Drop, "chance a replication call is dropped")
+	loseAck := flag.Float64("lose-ack", sim.DefaultFaults.LoseAck, "chance a replicated update is applied but not acknowledged")
+	delay := flag.Float64("delay", sim.DefaultFaults.Delay, "chance a replicated update arrives after the primary timed out")
+	verbose := flag.Bool("v", false, "print server logs")
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	opts := sim.Options{
+		Steps:       *steps,
+		Faults:      &sim.Faults{Drop: *drop, LoseAck: *loseAck, Delay: *delay},
+		CrashChance: *crash,
+	}
+
+	if *seed != 0 {
+		report := sim.Run(*seed, opts)
+		fmt.Println(strings.Join(report.Trace, "\n"))
+		if report.Err != nil {
+			fmt.Printf("seed %d failed: %v\n", report.Seed, report.Err)
+			os.Exit(1)
+		}
+		fmt.Printf("seed %d passed %d steps\n", report.Seed, report.Steps)
+		return
+	}
+
+	first := time.Now().UnixNano()
+	failed := 0
+	for i := 0; i < *runs; i++ {
+		report := sim.Run(first+int64(i), opts)
+		if report.Err != nil {
+			failed++
+			fmt.Printf("seed %d failed: %v\n", report.Seed, report.Err)
+			fmt.Printf("  replay with: go run ./cmd/simulate -seed %d\n", report.Seed)
+		}
+	}
+	fmt.Printf("%d of %d runs failed (%s)\n", failed, *runs, opts.Faults)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
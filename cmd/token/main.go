@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auth"
+)
+
+// token issues a token for a client ID, signed with the private key whose
+// public key the servers verify tokens against (-auth-key).
+func main() {
+	key := flag.String("key", "", "PEM file of the private key tokens are signed with")
+	subject := flag.String("subject", "", "client ID the token authenticates")
+	ttl := flag.Duration("ttl", 24*time.Hour, "how long the token is valid")
+	flag.Parse()
+
+	if *key == "" || *subject == "" {
+		fmt.Fprintln(os.Stderr, "usage: token -key signing.pem -subject <client ID> [-ttl 24h]")
+		os.Exit(2)
+	}
+
+	signer, err := auth.LoadSigner(*key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load signing key: %v\n", err)
+		os.Exit(1)
+	}
+	token, err := signer.Token(*subject, *ttl)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to sign token: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(token)
+}
@@ -0,0 +1,344 @@
+// Package faults injects failures into gRPC calls, so scenarios such as
+// "the backup applied the update but the acknowledgement was lost" can be
+// reproduced against the real primary and backup binaries.
+//
+// An Injector holds rules that pick calls by method and probability and
+// then drop, delay, duplicate or fail them, or lose their reply. The same
+// injector works on the receiving side (UnaryServerInterceptor) and the
+// calling side (UnaryClientInterceptor), and its rules can be replaced
+// while the server runs, from a flag or through AdminService.SetFaults.
+// AdminService and health checks are never faulted, so an admin can always
+// clear the rules and health checks see the node as it is.
+package faults
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// MaxDropWait is how long a dropped call waits when the caller set no
+// deadline, instead of hanging forever.
+const MaxDropWait = 30 * time.Second
+
+// exemptServices are never faulted.
+var exemptServices = map[string]bool{
+	pb.AdminService_ServiceDesc.ServiceName: true,
+	healthpb.Health_ServiceDesc.ServiceName: true,
+}
+
+// Injector decides which calls misbehave. It is safe for concurrent use.
+type Injector struct {
+	mutex       sync.Mutex
+	rules       []*pb.FaultRule
+	rand        *rand.Rand
+	maxDropWait time.Duration
+}
+
+// NewInjector returns an injector with rules, drawing from a random
+// source seeded with seed.
+func NewInjector(seed int64, rules []*pb.FaultRule) (*Injector, error) {
+	in := &Injector{rand: rand.New(rand.NewSource(seed)), maxDropWait: MaxDropWait}
+	if err := in.Set(rules); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// FromFlags returns an injector for the rules in spec, seeded from the
+// clock, or nil if spec is empty and enabled is false, which leaves fault
+// injection off.
+func FromFlags(spec string, enabled bool) (*Injector, error) {
+	rules, err := ParseRules(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 && !enabled {
+		return nil, nil
+	}
+	return NewInjector(time.Now().UnixNano(), rules)
+}
+
+// ServerOptions installs in, if non-nil, on a gRPC server.
+func ServerOptions(in *Injector) []grpc.ServerOption {
+	if in == nil {
+		return nil
+	}
+	return []grpc.ServerOption{grpc.ChainUnaryInterceptor(in.UnaryServerInterceptor())}
+}
+
+// DialOptions installs in, if non-nil, on a gRPC client connection.
+func DialOptions(in *Injector) []grpc.DialOption {
+	if in == nil {
+		return nil
+	}
+	return []grpc.DialOption{grpc.WithChainUnaryInterceptor(in.UnaryClientInterceptor())}
+}
+
+// Set replaces every rule. Each rule is checked as ParseRules checks it,
+// and if one is invalid the rules are left as they were.
+func (in *Injector) Set(rules []*pb.FaultRule) error {
+	for i, rule := range rules {
+		if err := validateRule(rule); err != nil {
+			return fmt.Errorf("fault rule %d: %v", i+1, err)
+		}
+	}
+
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+	in.rules = cloneRules(rules)
+	return nil
+}
+
+// validateRule checks a rule the way parseRule checks one it parses.
+func validateRule(rule *pb.FaultRule) error {
+	if rule.GetMethod() == "" {
+		return fmt.Errorf("want a method")
+	}
+	if p := rule.GetProbability(); !(p >= 0 && p <= 1) {
+		return fmt.Errorf("probability must be between 0 and 1")
+	}
+	switch rule.GetAction() {
+	case pb.FaultAction_FAULT_DROP, pb.FaultAction_FAULT_DUPLICATE, pb.FaultAction_FAULT_DROP_REPLY:
+	case pb.FaultAction_FAULT_DELAY:
+		if rule.GetDelayMs() < 0 {
+			return fmt.Errorf("delay must not be negative")
+		}
+	case pb.FaultAction_FAULT_FAIL:
+		if _, ok := parseCode(rule.GetCode()); rule.GetCode() != "" && !ok {
+			return fmt.Errorf("unknown gRPC code %q", rule.GetCode())
+		}
+	default:
+		return fmt.Errorf("unknown action %v", rule.GetAction())
+	}
+	return nil
+}
+
+// Rules returns a copy of the current rules.
+func (in *Injector) Rules() []*pb.FaultRule {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+	return cloneRules(in.rules)
+}
+
+func cloneRules(rules []*pb.FaultRule) []*pb.FaultRule {
+	cloned := make([]*pb.FaultRule, 0, len(rules))
+	for _, rule := range rules {
+		cloned = append(cloned, proto.Clone(rule).(*pb.FaultRule))
+	}
+	return cloned
+}
+
+// pick returns the first rule for method that fires this time, or nil.
+// fullMethod is the gRPC method, e.g. "/auction.AuctionService/Bid".
+func (in *Injector) pick(fullMethod string) *pb.FaultRule {
+	slash := strings.LastIndex(fullMethod, "/")
+	if exemptServices[strings.TrimPrefix(fullMethod[:max(slash, 0)], "/")] {
+		return nil
+	}
+	method := fullMethod[slash+1:]
+
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+	for _, rule := range in.rules {
+		if rule.Method != "*" && rule.Method != method {
+			continue
+		}
+		if in.rand.Float64() < rule.Probability {
+			return rule
+		}
+	}
+	return nil
+}
+
+// inject runs call, the handler or the invoker, subject to the rule that
+// fires for method, if any.
+func (in *Injector) inject(ctx context.Context, method string, call func() error) error {
+	rule := in.pick(method)
+	if rule == nil {
+		return call()
+	}
+
+	switch rule.Action {
+	case pb.FaultAction_FAULT_DROP:
+		return in.dropped(ctx)
+	case pb.FaultAction_FAULT_DELAY:
+		select {
+		case <-time.After(time.Duration(rule.DelayMs) * time.Millisecond):
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return call()
+	case pb.FaultAction_FAULT_DUPLICATE:
+		call()
+		return call()
+	case pb.FaultAction_FAULT_FAIL:
+		return status.Errorf(failureCode(rule), "fault injected: %s failed", method)
+	case pb.FaultAction_FAULT_DROP_REPLY:
+		call()
+		return in.dropped(ctx)
+	}
+	return call()
+}
+
+// dropped waits for a request or reply that was lost, as the caller
+// would: until its deadline, or for MaxDropWait if it set none.
+func (in *Injector) dropped(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, in.maxDropWait)
+		defer cancel()
+	}
+	<-ctx.Done()
+	return status.FromContextError(ctx.Err()).Err()
+}
+
+// failureCode is the code a FAULT_FAIL rule fails with.
+func failureCode(rule *pb.FaultRule) codes.Code {
+	if code, ok := parseCode(rule.Code); ok {
+		return code
+	}
+	return codes.Unavailable
+}
+
+// parseCode parses a gRPC code name such as "UNAVAILABLE".
+func parseCode(name string) (codes.Code, bool) {
+	var code codes.Code
+	if name == "" || code.UnmarshalJSON([]byte(strconv.Quote(name))) != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// UnaryServerInterceptor applies the rules to calls the server receives.
+// A dropped request or reply leaves the caller waiting for its deadline,
+// as a lost packet would, or for MaxDropWait without one.
+func (in *Injector) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var resp interface{}
+		err := in.inject(ctx, info.FullMethod, func() error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+}
+
+// UnaryClientInterceptor applies the rules to calls the client makes.
+func (in *Injector) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return in.inject(ctx, method, func() error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+	}
+}
+
+var actionNames = map[string]pb.FaultAction{
+	"drop":       pb.FaultAction_FAULT_DROP,
+	"delay":      pb.FaultAction_FAULT_DELAY,
+	"duplicate":  pb.FaultAction_FAULT_DUPLICATE,
+	"fail":       pb.FaultAction_FAULT_FAIL,
+	"drop-reply": pb.FaultAction_FAULT_DROP_REPLY,
+}
+
+// ParseRules parses a comma-separated list of rules, each
+// method:action[=argument][@probability]. The argument is the delay for
+// delay and the gRPC code for fail; the probability defaults to 1:
+//
+//	ReplicateUpdate:drop-reply@0.3,Heartbeat:drop,Bid:delay=200ms@0.5,Bid:fail=ABORTED@0.1
+func ParseRules(spec string) ([]*pb.FaultRule, error) {
+	var rules []*pb.FaultRule
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		rule, err := parseRule(item)
+		if err != nil {
+			return nil, fmt.Errorf("fault rule %q: %v", item, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseRule(item string) (*pb.FaultRule, error) {
+	rule := &pb.FaultRule{Probability: 1}
+
+	if at := strings.LastIndex(item, "@"); at >= 0 {
+		p, err := strconv.ParseFloat(item[at+1:], 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, fmt.Errorf("probability must be between 0 and 1")
+		}
+		rule.Probability = p
+		item = item[:at]
+	}
+
+	method, action, ok := strings.Cut(item, ":")
+	if !ok || method == "" {
+		return nil, fmt.Errorf("want method:action")
+	}
+	rule.Method = method
+
+	action, argument, _ := strings.Cut(action, "=")
+	if rule.Action, ok = actionNames[action]; !ok {
+		return nil, fmt.Errorf("unknown action %q (want drop, delay, duplicate, fail or drop-reply)", action)
+	}
+
+	switch rule.Action {
+	case pb.FaultAction_FAULT_DELAY:
+		delay, err := time.ParseDuration(argument)
+		if err != nil {
+			return nil, fmt.Errorf("delay needs a duration, e.g. delay=200ms")
+		}
+		rule.DelayMs = delay.Milliseconds()
+	case pb.FaultAction_FAULT_FAIL:
+		rule.Code = strings.ToUpper(argument)
+		if _, ok := parseCode(rule.Code); rule.Code != "" && !ok {
+			return nil, fmt.Errorf("unknown gRPC code %q", argument)
+		}
+	default:
+		if argument != "" {
+			return nil, fmt.Errorf("%s takes no argument", action)
+		}
+	}
+	return rule, nil
+}
+
+// FormatRules is the inverse of ParseRules.
+func FormatRules(rules []*pb.FaultRule) string {
+	items := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		item := rule.Method + ":"
+		for name, action := range actionNames {
+			if action == rule.Action {
+				item += name
+			}
+		}
+		switch rule.Action {
+		case pb.FaultAction_FAULT_DELAY:
+			item += "=" + (time.Duration(rule.DelayMs) * time.Millisecond).String()
+		case pb.FaultAction_FAULT_FAIL:
+			if rule.Code != "" {
+				item += "=" + rule.Code
+			}
+		}
+		if rule.Probability != 1 {
+			item += "@" + strconv.FormatFloat(rule.Probability, 'g', -1, 64)
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,245 @@
+package faults
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseAndFormatRules(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []*pb.FaultRule
+		// formatted is how FormatRules writes the rules back, if not spec
+		formatted string
+	}{
+		{spec: "", want: nil},
+		{spec: "Heartbeat:drop", want: []*pb.FaultRule{{Method: "Heartbeat", Action: pb.FaultAction_FAULT_DROP, Probability: 1}}},
+		{
+			spec: "ReplicateUpdate:drop-reply@0.3,Heartbeat:duplicate",
+			want: []*pb.FaultRule{
+				{Method: "ReplicateUpdate", Action: pb.FaultAction_FAULT_DROP_REPLY, Probability: 0.3},
+				{Method: "Heartbeat", Action: pb.FaultAction_FAULT_DUPLICATE, Probability: 1},
+			},
+		},
+		{spec: "Bid:delay=200ms@0.5", want: []*pb.FaultRule{{Method: "Bid", Action: pb.FaultAction_FAULT_DELAY, DelayMs: 200, Probability: 0.5}}},
+		{spec: "Bid:fail=ABORTED@0.1", want: []*pb.FaultRule{{Method: "Bid", Action: pb.FaultAction_FAULT_FAIL, Code: "ABORTED", Probability: 0.1}}},
+		{spec: "*:fail", want: []*pb.FaultRule{{Method: "*", Action: pb.FaultAction_FAULT_FAIL, Probability: 1}}},
+		{
+			spec:      " Bid:fail=aborted , Result:delay=1.5s@1 ",
+			formatted: "Bid:fail=ABORTED,Result:delay=1.5s",
+			want: []*pb.FaultRule{
+				{Method: "Bid", Action: pb.FaultAction_FAULT_FAIL, Code: "ABORTED", Probability: 1},
+				{Method: "Result", Action: pb.FaultAction_FAULT_DELAY, DelayMs: 1500, Probability: 1},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			rules, err := ParseRules(test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalRules(rules, test.want) {
+				t.Fatalf("ParseRules = %v, want %v", rules, test.want)
+			}
+
+			formatted := test.formatted
+			if formatted == "" {
+				formatted = test.spec
+			}
+			if got := FormatRules(rules); got != formatted {
+				t.Errorf("FormatRules = %q, want %q", got, formatted)
+			}
+			reparsed, err := ParseRules(FormatRules(rules))
+			if err != nil || !equalRules(reparsed, rules) {
+				t.Errorf("rules do not survive a round trip: %v, %v", reparsed, err)
+			}
+		})
+	}
+}
+
+func equalRules(a, b []*pb.FaultRule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	for _, spec := range []string{
+		"Bid",
+		":drop",
+		"Bid:explode",
+		"Bid:drop=1s",
+		"Bid:delay",
+		"Bid:delay=soon",
+		"Bid:fail=NOT_A_CODE",
+		"Bid:drop@1.5",
+		"Bid:drop@-0.1",
+		"Bid:drop@often",
+		"Bid:drop,Result",
+	} {
+		if rules, err := ParseRules(spec); err == nil {
+			t.Errorf("ParseRules(%q) = %v, want an error", spec, rules)
+		}
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	tests := []struct {
+		name string
+		rule *pb.FaultRule
+		ok   bool
+	}{
+		{"drop", &pb.FaultRule{Method: "Bid", Action: pb.FaultAction_FAULT_DROP, Probability: 1}, true},
+		{"delay", &pb.FaultRule{Method: "Bid", Action: pb.FaultAction_FAULT_DELAY, DelayMs: 100, Probability: 0.5}, true},
+		{"fail with a code", &pb.FaultRule{Method: "Bid", Action: pb.FaultAction_FAULT_FAIL, Code: "ABORTED", Probability: 1}, true},
+		{"fail without a code", &pb.FaultRule{Method: "Bid", Action: pb.FaultAction_FAULT_FAIL, Probability: 1}, true},
+		{"never fires", &pb.FaultRule{Method: "Bid", Action: pb.FaultAction_FAULT_DROP, Probability: 0}, true},
+		{"no method", &pb.FaultRule{Action: pb.FaultAction_FAULT_DROP, Probability: 1}, false},
+		{"probability above 1", &pb.FaultRule{Method: "Bid", Action: pb.FaultAction_FAULT_DROP, Probability: 2}, false},
+		{"negative probability", &pb.FaultRule{Method: "Bid", Action: pb.FaultAction_FAULT_DROP, Probability: -1}, false},
+		{"negative delay", &pb.FaultRule{Method: "Bid", Action: pb.FaultAction_FAULT_DELAY, DelayMs: -1, Probability: 1}, false},
+		{"unknown code", &pb.FaultRule{Method: "Bid", Action: pb.FaultAction_FAULT_FAIL, Code: "NOPE", Probability: 1}, false},
+		{"unknown action", &pb.FaultRule{Method: "Bid", Action: pb.FaultAction(99), Probability: 1}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validateRule(test.rule); (err == nil) != test.ok {
+				t.Errorf("validateRule = %v, want ok %v", err, test.ok)
+			}
+		})
+	}
+}
+
+func TestSetKeepsRulesIfOneIsInvalid(t *testing.T) {
+	rule := &pb.FaultRule{Method: "Bid", Action: pb.FaultAction_FAULT_DROP, Probability: 1}
+	in, err := NewInjector(1, []*pb.FaultRule{rule})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = in.Set([]*pb.FaultRule{{Method: "Result", Action: pb.FaultAction_FAULT_DROP, Probability: 1}, {Action: pb.FaultAction_FAULT_DROP}})
+	if err == nil {
+		t.Fatal("Set accepted a rule without a method")
+	}
+	if rules := in.Rules(); !equalRules(rules, []*pb.FaultRule{rule}) {
+		t.Errorf("rules = %v, want the old ones", rules)
+	}
+}
+
+func TestPick(t *testing.T) {
+	in, err := NewInjector(1, []*pb.FaultRule{
+		{Method: "Bid", Action: pb.FaultAction_FAULT_DELAY, DelayMs: 10, Probability: 1},
+		{Method: "*", Action: pb.FaultAction_FAULT_DROP, Probability: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		want   pb.FaultAction
+		exempt bool
+	}{
+		{method: "/auction.AuctionService/Bid", want: pb.FaultAction_FAULT_DELAY},
+		{method: "/auction.AuctionService/Result", want: pb.FaultAction_FAULT_DROP},
+		{method: "/auction.ReplicationService/Heartbeat", want: pb.FaultAction_FAULT_DROP},
+		{method: "/auction.AdminService/SetFaults", exempt: true},
+		{method: "/auction.AdminService/GetClusterStatus", exempt: true},
+		{method: "/grpc.health.v1.Health/Check", exempt: true},
+		{method: "/grpc.health.v1.Health/Watch", exempt: true},
+	}
+	for _, test := range tests {
+		rule := in.pick(test.method)
+		switch {
+		case test.exempt && rule != nil:
+			t.Errorf("%s is faulted with %v", test.method, rule)
+		case !test.exempt && (rule == nil || rule.Action != test.want):
+			t.Errorf("%s: rule %v, want %v", test.method, rule, test.want)
+		}
+	}
+}
+
+func TestInject(t *testing.T) {
+	tests := []struct {
+		rule  string
+		calls int
+		code  codes.Code
+	}{
+		{"Bid:delay=1ms", 1, codes.OK},
+		{"Bid:duplicate", 2, codes.OK},
+		{"Bid:fail", 0, codes.Unavailable},
+		{"Bid:fail=ABORTED", 0, codes.Aborted},
+		{"Bid:drop", 0, codes.DeadlineExceeded},
+		{"Bid:drop-reply", 1, codes.DeadlineExceeded},
+		{"Result:drop", 1, codes.OK},
+	}
+	for _, test := range tests {
+		t.Run(test.rule, func(t *testing.T) {
+			rules, err := ParseRules(test.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			in, err := NewInjector(1, rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			calls := 0
+			err = in.inject(ctx, "/auction.AuctionService/Bid", func() error {
+				calls++
+				return nil
+			})
+			if status.Code(err) != test.code || calls != test.calls {
+				t.Errorf("inject = %v after %d calls, want %v after %d", err, calls, test.code, test.calls)
+			}
+		})
+	}
+}
+
+// TestDropWithoutDeadline checks that a dropped call whose caller set no
+// deadline gives up after MaxDropWait rather than hanging.
+func TestDropWithoutDeadline(t *testing.T) {
+	in, err := NewInjector(1, []*pb.FaultRule{{Method: "*", Action: pb.FaultAction_FAULT_DROP, Probability: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.maxDropWait != MaxDropWait {
+		t.Fatalf("maxDropWait = %v, want %v", in.maxDropWait, MaxDropWait)
+	}
+	in.maxDropWait = 50 * time.Millisecond
+
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		done <- in.inject(context.Background(), "/auction.AuctionService/Bid", func() error {
+			t.Error("a dropped call reached the handler")
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.DeadlineExceeded {
+			t.Errorf("inject = %v, want DeadlineExceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed < in.maxDropWait {
+			t.Errorf("gave up after %v, before %v", elapsed, in.maxDropWait)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a dropped call without a deadline hangs")
+	}
+}
@@ -0,0 +1,409 @@
+package lincheck
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/client"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+)
+
+// AuctionInput is a recorded call. Bidder is the bidder it is about, as
+// in client.Operation.
+type AuctionInput struct {
+	Kind     string // one of the client.Op kinds
+	Bidder   string
+	Amount   int64
+	Quantity int32
+}
+
+// AuctionOutput is what the caller was told. Unknown is set for a write
+// that failed, which may or may not have taken effect.
+type AuctionOutput struct {
+	Outcome    pb.Outcome
+	Reason     pb.RejectReason
+	Status     pb.AuctionStatus
+	HighestBid int64
+	Winner     string
+	Bids       []client.BidRecord
+	Unknown    bool
+}
+
+// auctionState is the model's state: every accepted bid as History
+// reports it, the bids standing among them, and how the auction ended, if
+// it ended early. States are never changed in place.
+type auctionState struct {
+	history   []client.BidRecord
+	standing  []auction.Bid
+	buyer     string
+	cancelled bool
+}
+
+func (s auctionState) ended() bool {
+	return s.buyer != "" || s.cancelled
+}
+
+// latestBidOf is the index in history of the bidder's latest bid that can
+// still be retracted, or -1.
+func (s auctionState) latestBidOf(bidder string) int {
+	for i := len(s.history) - 1; i >= 0; i-- {
+		record := s.history[i]
+		if record.Bidder == bidder && !record.Retracted && !record.BuyNow {
+			return i
+		}
+	}
+	return -1
+}
+
+// auctionSpec is what the model needs of the auction's configuration.
+type auctionSpec struct {
+	rules           auction.AuctionRules
+	quantity        int32
+	buyNowPrice     int64
+	buyNowThreshold int64
+}
+
+func (a auctionSpec) buyNowAvailable(s auctionState) bool {
+	if a.buyNowPrice <= 0 || s.ended() {
+		return false
+	}
+	if a.buyNowThreshold > 0 {
+		highest, _ := leader(a.rules, s.standing)
+		return highest < a.buyNowThreshold
+	}
+	// The first bid withdraws the offer for good, retracted or not
+	return len(s.history) == 0
+}
+
+func (a auctionSpec) accept(s auctionState, bid auction.Bid) auctionState {
+	s.history = append(s.history[:len(s.history):len(s.history)], client.BidRecord{
+		Bidder:   bid.Bidder,
+		Amount:   bid.Amount,
+		Quantity: bid.Quantity,
+	})
+	s.standing = a.rules.ApplyBid(bid, s.standing)
+	return s
+}
+
+func (a auctionSpec) buy(s auctionState, bidder string) auctionState {
+	s.history = append(s.history[:len(s.history):len(s.history)], client.BidRecord{
+		Bidder:   bidder,
+		Amount:   a.buyNowPrice,
+		Quantity: a.quantity,
+		BuyNow:   true,
+	})
+	s.buyer = bidder
+	return s
+}
+
+// retract marks history[i] retracted and replays the rest through the
+// rules, as the auction does.
+func (a auctionSpec) retract(s auctionState, i int) auctionState {
+	s.history = append([]client.BidRecord(nil), s.history...)
+	s.history[i].Retracted = true
+	s.standing = nil
+	for _, record := range s.history {
+		if !record.Retracted && !record.BuyNow {
+			s.standing = a.rules.ApplyBid(auction.Bid{Bidder: record.Bidder, Amount: record.Amount, Quantity: record.Quantity}, s.standing)
+		}
+	}
+	return s
+}
+
+// AuctionModel is the sequential specification of an auction.Auction
+// under config: a bid is accepted exactly when the auction rules accept it
+// against the bids standing before it, buy-now, retraction and
+// cancellation change the auction as the servers do, Result reports the
+// leader and History every accepted bid. Register and ApproveBidder
+// change only the registry, which is not modelled, so they have no effect.
+//
+// The model judges writes by the state alone. Rejections that also depend
+// on time or on other state (the auction has closed, the amount is
+// invalid, the bidder is not registered, the retraction policy forbids
+// it) are taken to have had no effect without being checked.
+func AuctionModel(config auction.Config) Model {
+	spec := auctionSpec{
+		rules:           config.Rules,
+		quantity:        config.Quantity,
+		buyNowPrice:     config.BuyNowPrice,
+		buyNowThreshold: config.BuyNowThreshold,
+	}
+	if spec.rules == nil {
+		spec.rules = auction.NewEnglishRules(config)
+	}
+	if spec.quantity <= 0 {
+		spec.quantity = 1
+	}
+
+	return Model{
+		Init: func() interface{} {
+			return auctionState{}
+		},
+		Step: func(state, input, output interface{}) (bool, interface{}) {
+			s := state.(auctionState)
+			ok, next := spec.step(s, input.(AuctionInput), output.(AuctionOutput))
+			return ok, next
+		},
+		Equal: func(a, b interface{}) bool {
+			x, y := a.(auctionState), b.(auctionState)
+			return x.buyer == y.buyer && x.cancelled == y.cancelled && sameBids(x.history, y.history)
+		},
+		ReadOnly: func(input, output interface{}) bool {
+			in := input.(AuctionInput)
+			out := output.(AuctionOutput)
+			switch in.Kind {
+			case client.OpResult, client.OpHistory, client.OpRegister, client.OpApprove:
+				return true
+			}
+			return !out.Unknown && out.Outcome != pb.Outcome_SUCCESS
+		},
+		DescribeOperation: describeOperation,
+		DescribeState: func(state interface{}) string {
+			s := state.(auctionState)
+			if len(s.history) == 0 && !s.cancelled {
+				return "no bids"
+			}
+			bids := make([]string, 0, len(s.history)+1)
+			for _, record := range s.history {
+				switch {
+				case record.BuyNow:
+					bids = append(bids, fmt.Sprintf("%s buys now at %d", record.Bidder, record.Amount))
+				case record.Retracted:
+					bids = append(bids, fmt.Sprintf("%s %d (retracted)", record.Bidder, record.Amount))
+				default:
+					bids = append(bids, fmt.Sprintf("%s %d", record.Bidder, record.Amount))
+				}
+			}
+			if s.cancelled {
+				bids = append(bids, "cancelled")
+			}
+			return strings.Join(bids, ", ")
+		},
+	}
+}
+
+func (a auctionSpec) step(s auctionState, in AuctionInput, out AuctionOutput) (bool, auctionState) {
+	// Only a cancelled auction turns writes away as cancelled
+	if !out.Unknown && out.Reason == pb.RejectReason_AUCTION_CANCELLED {
+		return s.cancelled, s
+	}
+
+	switch in.Kind {
+	case client.OpResult:
+		switch {
+		case s.cancelled || out.Status == pb.AuctionStatus_CANCELLED:
+			return s.cancelled && out.Status == pb.AuctionStatus_CANCELLED, s
+		case s.buyer != "":
+			return out.HighestBid == a.buyNowPrice && out.Winner == s.buyer, s
+		}
+		highest, winner := leader(a.rules, s.standing)
+		return out.HighestBid == highest && out.Winner == winner, s
+
+	case client.OpHistory:
+		return sameBids(out.Bids, s.history), s
+
+	case client.OpRegister, client.OpApprove:
+		return true, s
+
+	case client.OpBid:
+		quantity := in.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		bid := auction.Bid{Bidder: in.Bidder, Amount: in.Amount, Quantity: quantity}
+
+		switch {
+		case s.ended():
+			return out.Unknown || out.Outcome != pb.Outcome_SUCCESS, s
+		case a.buyNowAvailable(s) && bid.Amount >= a.buyNowPrice:
+			// The bid is taken as a purchase if it is for the whole lot
+			// and refused otherwise
+			if quantity != a.quantity {
+				return out.Unknown || out.Outcome != pb.Outcome_SUCCESS, s
+			}
+			if out.Unknown || out.Outcome == pb.Outcome_SUCCESS {
+				return true, a.buy(s, bid.Bidder)
+			}
+			return true, s
+		case !out.Unknown && out.Reason == pb.RejectReason_BUY_NOW_UNAVAILABLE:
+			return false, s
+		}
+
+		outcome, reason := a.rules.ValidateBid(bid, s.standing)
+		switch {
+		case out.Unknown:
+			if outcome == pb.Outcome_SUCCESS {
+				return true, a.accept(s, bid)
+			}
+			return true, s
+		case out.Outcome == pb.Outcome_SUCCESS:
+			if outcome != pb.Outcome_SUCCESS {
+				return false, s
+			}
+			return true, a.accept(s, bid)
+		case rulesReason(out.Reason):
+			return outcome == out.Outcome && reason == out.Reason, s
+		default:
+			return true, s
+		}
+
+	case client.OpBuyNow:
+		available := a.buyNowAvailable(s)
+		switch {
+		case out.Unknown:
+			if available {
+				return true, a.buy(s, in.Bidder)
+			}
+			return true, s
+		case out.Outcome == pb.Outcome_SUCCESS:
+			return available, a.buy(s, in.Bidder)
+		case out.Reason == pb.RejectReason_BUY_NOW_UNAVAILABLE:
+			return !s.ended() && !available, s
+		default:
+			return true, s
+		}
+
+	case client.OpRetract:
+		latest := s.latestBidOf(in.Bidder)
+		switch {
+		case out.Unknown:
+			if !s.ended() && latest >= 0 {
+				return true, a.retract(s, latest)
+			}
+			return true, s
+		case out.Outcome == pb.Outcome_SUCCESS:
+			if s.ended() || latest < 0 {
+				return false, s
+			}
+			return true, a.retract(s, latest)
+		case out.Reason == pb.RejectReason_NO_BID_TO_RETRACT:
+			return !s.ended() && latest < 0, s
+		default:
+			return true, s
+		}
+
+	case client.OpCancel:
+		if out.Unknown || out.Outcome == pb.Outcome_SUCCESS {
+			if s.ended() {
+				return out.Unknown, s
+			}
+			s.cancelled = true
+			return true, s
+		}
+		return true, s
+	}
+
+	// An operation the model does not know cannot be placed anywhere
+	return false, s
+}
+
+func sameBids(x, y []client.BidRecord) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func describeOperation(input, output interface{}) string {
+	in := input.(AuctionInput)
+	out := output.(AuctionOutput)
+
+	var call string
+	switch in.Kind {
+	case client.OpResult:
+		if out.Status == pb.AuctionStatus_CANCELLED {
+			return "result -> cancelled"
+		}
+		return fmt.Sprintf("result -> %d by %q", out.HighestBid, out.Winner)
+	case client.OpHistory:
+		return fmt.Sprintf("history -> %d bids", len(out.Bids))
+	case client.OpBid:
+		call = fmt.Sprintf("bid %s %d", in.Bidder, in.Amount)
+		if in.Quantity > 1 {
+			call += fmt.Sprintf(" x %d", in.Quantity)
+		}
+	case client.OpCancel:
+		call = "cancel"
+	default:
+		call = fmt.Sprintf("%s %s", in.Kind, in.Bidder)
+	}
+
+	switch {
+	case out.Unknown:
+		return call + " -> ?"
+	case out.Outcome == pb.Outcome_SUCCESS:
+		return call + " -> ok"
+	default:
+		return fmt.Sprintf("%s -> %s", call, out.Reason)
+	}
+}
+
+// leader is the highest bid and bidder Result reports for standing.
+func leader(rules auction.AuctionRules, standing []auction.Bid) (int64, string) {
+	allocations := rules.Allocations(standing)
+	if len(allocations) == 0 {
+		return 0, ""
+	}
+	return allocations[0].BidPrice, allocations[0].Bidder
+}
+
+// rulesReason reports whether reason is a rejection auction rules make.
+func rulesReason(reason pb.RejectReason) bool {
+	return reason == pb.RejectReason_BELOW_HIGHEST || reason == pb.RejectReason_BELOW_OWN_PREVIOUS
+}
+
+// FromClient turns operations recorded by client.Recorder into a history
+// for AuctionModel. Failed Result and History calls observed nothing and
+// are dropped; failed writes become pending. Times are nanoseconds since
+// the first call.
+func FromClient(recorded []client.Operation) []Operation {
+	if len(recorded) == 0 {
+		return nil
+	}
+	start := recorded[0].Call
+	for _, op := range recorded {
+		if op.Call.Before(start) {
+			start = op.Call
+		}
+	}
+
+	clients := make(map[string]int)
+	history := make([]Operation, 0, len(recorded))
+	for _, op := range recorded {
+		if (op.Kind == client.OpResult || op.Kind == client.OpHistory) && op.Err != "" {
+			continue
+		}
+		id, ok := clients[op.Client]
+		if !ok {
+			id = len(clients)
+			clients[op.Client] = id
+		}
+
+		entry := Operation{
+			ClientID: id,
+			Input:    AuctionInput{Kind: op.Kind, Bidder: op.Bidder, Amount: op.Amount, Quantity: op.Quantity},
+			Output: AuctionOutput{
+				Outcome:    op.Outcome,
+				Reason:     op.Reason,
+				Status:     op.Status,
+				HighestBid: op.HighestBid,
+				Winner:     op.Winner,
+				Bids:       op.Bids,
+				Unknown:    op.Err != "",
+			},
+			Call:   int64(op.Call.Sub(start)),
+			Return: int64(op.Return.Sub(start)),
+		}
+		if op.Err != "" {
+			entry.Return = Pending
+		}
+		history = append(history, entry)
+	}
+	return history
+}
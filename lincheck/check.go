@@ -0,0 +1,306 @@
+// Package lincheck checks that a recorded history of concurrent operations
+// is linearizable: that every operation can be given a single point in
+// time, between its call and its return, at which it took effect on a
+// sequential model and produced the output the client saw.
+//
+// The search follows Porcupine (Athalye, after Wing & Gong and Lowe):
+// operations are linearized depth first in call order, backtracking when
+// the model rejects an output, and memoizing the pairs of linearized set
+// and model state already explored. A history that fails is reduced to a
+// minimal violating sub-history for Render.
+package lincheck
+
+import (
+	"math"
+	"sort"
+)
+
+// Model is a sequential specification. States must be treated as
+// immutable: Step returns a new state rather than changing the old one.
+type Model struct {
+	Init func() interface{}
+	// Step applies input to state and reports whether the model could
+	// have produced output, with the state afterwards.
+	Step  func(state, input, output interface{}) (bool, interface{})
+	Equal func(a, b interface{}) bool
+	// ReadOnly reports whether an operation leaves the state as it was,
+	// whatever it is applied to. Only such operations, and writes no other
+	// operation could have seen, are dropped when a violation is shrunk,
+	// so the sub-history fails for the same reason as the whole. Nil
+	// treats every operation as a write.
+	ReadOnly func(input, output interface{}) bool
+	// DescribeOperation and DescribeState are used by Render.
+	DescribeOperation func(input, output interface{}) string
+	DescribeState     func(state interface{}) string
+}
+
+// Pending is the Return time of an operation that never returned, or
+// whose outcome the client never learned. It can be linearized at any
+// point after its call, including not at all within the history.
+const Pending = math.MaxInt64
+
+// Operation is one call in a history. Call and Return are in any unit, as
+// long as they are comparable across clients.
+type Operation struct {
+	ClientID int
+	Input    interface{}
+	Output   interface{}
+	Call     int64
+	Return   int64
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Ok bool
+	// Violation is a minimal sub-history that is not linearizable: dropping
+	// any read, or any write none of the others could have seen, makes it
+	// linearizable. Empty if Ok.
+	Violation []Operation
+}
+
+// Check reports whether history is linearizable with respect to model,
+// and if not, which operations show it.
+func Check(model Model, history []Operation) Result {
+	if linearizable(model, history) {
+		return Result{Ok: true}
+	}
+	return Result{Violation: minimize(model, history)}
+}
+
+// minimize shrinks a non-linearizable history by dropping operations as
+// long as it stays non-linearizable. Dropping a read only removes
+// constraints, and so does dropping a write that every other operation
+// returned before, so whatever is left fails for a reason that was in the
+// whole history. Dropping any other write could make a read that saw it
+// look wrong, so those are kept.
+func minimize(model Model, history []Operation) []Operation {
+	ops := append([]Operation(nil), history...)
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].Call < ops[j].Call })
+
+	for shrunk := true; shrunk; {
+		shrunk = false
+		for i := len(ops) - 1; i >= 0; i-- {
+			if !droppable(model, ops, i) {
+				continue
+			}
+			candidate := append(append([]Operation(nil), ops[:i]...), ops[i+1:]...)
+			if !linearizable(model, candidate) {
+				ops, shrunk = candidate, true
+			}
+		}
+	}
+	return ops
+}
+
+func droppable(model Model, ops []Operation, i int) bool {
+	if model.ReadOnly != nil && model.ReadOnly(ops[i].Input, ops[i].Output) {
+		return true
+	}
+	for j, op := range ops {
+		if j != i && op.Return >= ops[i].Call {
+			return false
+		}
+	}
+	return true
+}
+
+// entry is a call or return event in the linked list the search walks.
+// A call's match is its return; a return has no match.
+type entry struct {
+	id         int
+	time       int64
+	input      interface{}
+	output     interface{}
+	match      *entry
+	prev, next *entry
+}
+
+// makeEntries builds the event list: every call and return ordered by
+// time, calls first when a call and a return happen at the same instant,
+// so such operations count as concurrent.
+func makeEntries(history []Operation) *entry {
+	type event struct {
+		e      *entry
+		isCall bool
+	}
+	events := make([]event, 0, 2*len(history))
+	for i, op := range history {
+		ret := &entry{id: i, time: op.Return, output: op.Output}
+		call := &entry{id: i, time: op.Call, input: op.Input, output: op.Output, match: ret}
+		events = append(events, event{call, true}, event{ret, false})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].e.time != events[j].e.time {
+			return events[i].e.time < events[j].e.time
+		}
+		return events[i].isCall && !events[j].isCall
+	})
+
+	head := &entry{id: -1}
+	last := head
+	for _, ev := range events {
+		ev.e.prev = last
+		last.next = ev.e
+		last = ev.e
+	}
+	return head
+}
+
+// lift takes a linearized call and its return out of the list.
+func lift(e *entry) {
+	e.prev.next = e.next
+	if e.next != nil {
+		e.next.prev = e.prev
+	}
+	m := e.match
+	m.prev.next = m.next
+	if m.next != nil {
+		m.next.prev = m.prev
+	}
+}
+
+// unlift puts them back when the search backtracks.
+func unlift(e *entry) {
+	m := e.match
+	m.prev.next = m
+	if m.next != nil {
+		m.next.prev = m
+	}
+	e.prev.next = e
+	if e.next != nil {
+		e.next.prev = e
+	}
+}
+
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (b bitset) clone() bitset {
+	return append(bitset(nil), b...)
+}
+
+func (b bitset) set(i int) bitset {
+	b[i/64] |= 1 << uint(i%64)
+	return b
+}
+
+func (b bitset) clear(i int) bitset {
+	b[i/64] &^= 1 << uint(i%64)
+	return b
+}
+
+func (b bitset) equal(other bitset) bool {
+	for i := range b {
+		if b[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (b bitset) hash() uint64 {
+	var h uint64 = 14695981039346656037
+	for _, word := range b {
+		h ^= word
+		h *= 1099511628211
+	}
+	return h
+}
+
+type cacheEntry struct {
+	linearized bitset
+	state      interface{}
+}
+
+type frame struct {
+	e     *entry
+	state interface{}
+}
+
+func linearizable(model Model, history []Operation) bool {
+	ok, _ := search(model, history)
+	return ok
+}
+
+// search looks for a linearization of history. If there is none, it
+// returns the longest partial one it found, as indexes into history in
+// linearization order.
+func search(model Model, history []Operation) (bool, []int) {
+	if len(history) == 0 {
+		return true, nil
+	}
+
+	head := makeEntries(history)
+	state := model.Init()
+	linearized := newBitset(len(history))
+	cache := make(map[uint64][]cacheEntry)
+	var calls []frame
+	var longest []int
+
+	// Operations that never returned can always be placed after everything
+	// else, or left out, so the search is done once only they are left.
+	remaining := 0
+	for _, op := range history {
+		if op.Return != Pending {
+			remaining++
+		}
+	}
+
+	seen := func(lin bitset, s interface{}) bool {
+		for _, c := range cache[lin.hash()] {
+			if c.linearized.equal(lin) && model.Equal(c.state, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	e := head.next
+	for remaining > 0 {
+		if e.match != nil {
+			ok, next := model.Step(state, e.input, e.output)
+			if ok {
+				lin := linearized.clone().set(e.id)
+				if !seen(lin, next) {
+					h := lin.hash()
+					cache[h] = append(cache[h], cacheEntry{lin, next})
+					calls = append(calls, frame{e, state})
+					if len(calls) > len(longest) {
+						longest = longest[:0]
+						for _, f := range calls {
+							longest = append(longest, f.e.id)
+						}
+					}
+					state = next
+					linearized.set(e.id)
+					lift(e)
+					if e.match.time != Pending {
+						remaining--
+					}
+					e = head.next
+					continue
+				}
+			}
+			e = e.next
+			continue
+		}
+
+		// A return whose call could not be linearized: backtrack.
+		if len(calls) == 0 {
+			return false, longest
+		}
+		top := calls[len(calls)-1]
+		calls = calls[:len(calls)-1]
+		state = top.state
+		linearized.clear(top.e.id)
+		unlift(top.e)
+		if top.e.match.time != Pending {
+			remaining++
+		}
+		e = top.e.next
+	}
+	return true, nil
+}
@@ -0,0 +1,383 @@
+package lincheck_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/client"
+	"github.com/joachimblom-hanssen/Distributed_5/lincheck"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+)
+
+var (
+	ok              = lincheck.AuctionOutput{Outcome: pb.Outcome_SUCCESS}
+	unknown         = lincheck.AuctionOutput{Unknown: true}
+	below           = lincheck.AuctionOutput{Outcome: pb.Outcome_FAIL, Reason: pb.RejectReason_BELOW_HIGHEST}
+	cancelled       = lincheck.AuctionOutput{Outcome: pb.Outcome_FAIL, Reason: pb.RejectReason_AUCTION_CANCELLED}
+	noBid           = lincheck.AuctionOutput{Outcome: pb.Outcome_FAIL, Reason: pb.RejectReason_NO_BID_TO_RETRACT}
+	noBuyNow        = lincheck.AuctionOutput{Outcome: pb.Outcome_FAIL, Reason: pb.RejectReason_BUY_NOW_UNAVAILABLE}
+	noResult        = result(0, "")
+	cancelledResult = lincheck.AuctionOutput{Status: pb.AuctionStatus_CANCELLED}
+)
+
+// op is a call by client id from call to ret; ret is lincheck.Pending for
+// one that never returned.
+func op(id int, kind, bidder string, amount int64, call, ret int64, out lincheck.AuctionOutput) lincheck.Operation {
+	return lincheck.Operation{
+		ClientID: id,
+		Input:    lincheck.AuctionInput{Kind: kind, Bidder: bidder, Amount: amount},
+		Output:   out,
+		Call:     call,
+		Return:   ret,
+	}
+}
+
+func bid(id int, bidder string, amount int64, call, ret int64, out lincheck.AuctionOutput) lincheck.Operation {
+	return op(id, client.OpBid, bidder, amount, call, ret, out)
+}
+
+// partial is a bid for quantity units.
+func partial(id int, bidder string, amount int64, quantity int32, call, ret int64, out lincheck.AuctionOutput) lincheck.Operation {
+	operation := bid(id, bidder, amount, call, ret, out)
+	input := operation.Input.(lincheck.AuctionInput)
+	input.Quantity = quantity
+	operation.Input = input
+	return operation
+}
+
+func read(id int, call, ret int64, out lincheck.AuctionOutput) lincheck.Operation {
+	return op(id, client.OpResult, "", 0, call, ret, out)
+}
+
+func result(highest int64, winner string) lincheck.AuctionOutput {
+	return lincheck.AuctionOutput{Status: pb.AuctionStatus_ONGOING, HighestBid: highest, Winner: winner}
+}
+
+func history(bids ...client.BidRecord) lincheck.AuctionOutput {
+	return lincheck.AuctionOutput{Bids: bids}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  auction.Config
+		history []lincheck.Operation
+		ok      bool
+	}{
+		{
+			name: "sequential bids and a read",
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 10, ok),
+				bid(1, "bob", 120, 20, 30, ok),
+				read(0, 40, 50, result(120, "bob")),
+			},
+			ok: true,
+		},
+		{
+			name: "stale read after an acknowledged bid",
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 10, ok),
+				read(1, 20, 30, noResult),
+			},
+		},
+		{
+			name: "lost acknowledged bid",
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 10, ok),
+				bid(1, "bob", 120, 20, 30, ok),
+				read(2, 40, 50, result(100, "alice")),
+			},
+		},
+		{
+			name: "acknowledged bid missing from the history",
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 10, ok),
+				op(1, client.OpHistory, "", 0, 20, 30, history()),
+			},
+		},
+		{
+			name: "a bid called earlier can take effect later",
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 30, ok),
+				bid(1, "bob", 90, 10, 20, below),
+				read(2, 5, 15, noResult),
+			},
+			ok: true,
+		},
+		{
+			name: "concurrent equal bids cannot both be accepted",
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 30, ok),
+				bid(1, "bob", 100, 10, 20, ok),
+			},
+		},
+		{
+			name: "concurrent reads may see either order",
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 10, ok),
+				bid(1, "bob", 120, 20, 60, ok),
+				read(2, 30, 40, result(120, "bob")),
+				read(3, 45, 55, result(120, "bob")),
+			},
+			ok: true,
+		},
+		{
+			name: "a read cannot go back in time",
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 10, ok),
+				bid(1, "bob", 120, 20, 60, ok),
+				read(2, 30, 40, result(120, "bob")),
+				read(3, 45, 55, result(100, "alice")),
+			},
+		},
+		{
+			name: "a failed bid may have taken effect",
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, lincheck.Pending, unknown),
+				read(1, 10, 20, result(100, "alice")),
+			},
+			ok: true,
+		},
+		{
+			name: "or not",
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, lincheck.Pending, unknown),
+				read(1, 10, 20, noResult),
+			},
+			ok: true,
+		},
+		{
+			name: "retraction brings the previous leader back",
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 10, ok),
+				bid(1, "bob", 120, 20, 30, ok),
+				op(1, client.OpRetract, "bob", 0, 40, 50, ok),
+				read(2, 60, 70, result(100, "alice")),
+				op(2, client.OpHistory, "", 0, 80, 90, history(
+					client.BidRecord{Bidder: "alice", Amount: 100, Quantity: 1},
+					client.BidRecord{Bidder: "bob", Amount: 120, Quantity: 1, Retracted: true},
+				)),
+			},
+			ok: true,
+		},
+		{
+			name: "retracting a bid that was never placed",
+			history: []lincheck.Operation{
+				op(0, client.OpRetract, "alice", 0, 0, 10, ok),
+			},
+		},
+		{
+			name: "nothing to retract",
+			history: []lincheck.Operation{
+				op(0, client.OpRetract, "alice", 0, 0, 10, noBid),
+				bid(0, "alice", 100, 20, 30, ok),
+			},
+			ok: true,
+		},
+		{
+			name:   "buy-now ends the auction",
+			config: auction.Config{BuyNowPrice: 500},
+			history: []lincheck.Operation{
+				op(0, client.OpBuyNow, "alice", 0, 0, 10, ok),
+				bid(1, "bob", 100, 20, 30, lincheck.AuctionOutput{Outcome: pb.Outcome_FAIL, Reason: pb.RejectReason_AUCTION_CLOSED}),
+				read(2, 40, 50, result(500, "alice")),
+			},
+			ok: true,
+		},
+		{
+			name:   "buy-now is withdrawn by the first bid",
+			config: auction.Config{BuyNowPrice: 500},
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 10, ok),
+				op(1, client.OpBuyNow, "bob", 0, 20, 30, ok),
+			},
+		},
+		{
+			name:   "buy-now refused before any bid",
+			config: auction.Config{BuyNowPrice: 500},
+			history: []lincheck.Operation{
+				op(0, client.OpBuyNow, "alice", 0, 0, 10, noBuyNow),
+			},
+		},
+		{
+			name:   "a bid at the buy-now price buys",
+			config: auction.Config{BuyNowPrice: 500},
+			history: []lincheck.Operation{
+				bid(0, "alice", 600, 0, 10, ok),
+				read(1, 20, 30, result(500, "alice")),
+			},
+			ok: true,
+		},
+		{
+			name:   "buy-now stays withdrawn after the first bid is retracted",
+			config: auction.Config{BuyNowPrice: 500},
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 10, ok),
+				op(0, client.OpRetract, "alice", 0, 20, 30, ok),
+				op(1, client.OpBuyNow, "bob", 0, 40, 50, noBuyNow),
+			},
+			ok: true,
+		},
+		{
+			name:   "buy-now offered again after a retraction",
+			config: auction.Config{BuyNowPrice: 500},
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 10, ok),
+				op(0, client.OpRetract, "alice", 0, 20, 30, ok),
+				op(1, client.OpBuyNow, "bob", 0, 40, 50, ok),
+			},
+		},
+		{
+			name:   "a bid for part of the lot at the buy-now price is refused",
+			config: auction.Config{Quantity: 3, BuyNowPrice: 500},
+			history: []lincheck.Operation{
+				partial(0, "alice", 600, 2, 0, 10, noBuyNow),
+				op(1, client.OpBuyNow, "bob", 0, 20, 30, ok),
+			},
+			ok: true,
+		},
+		{
+			name:   "a bid for part of the lot at the buy-now price buys",
+			config: auction.Config{Quantity: 3, BuyNowPrice: 500},
+			history: []lincheck.Operation{
+				partial(0, "alice", 600, 2, 0, 10, ok),
+			},
+		},
+		{
+			name:   "a bid for the whole lot at the buy-now price buys",
+			config: auction.Config{Quantity: 3, BuyNowPrice: 500},
+			history: []lincheck.Operation{
+				partial(0, "alice", 600, 3, 0, 10, ok),
+				read(1, 20, 30, result(500, "alice")),
+			},
+			ok: true,
+		},
+		{
+			name:   "a bid below the buy-now price refused as buy-now",
+			config: auction.Config{BuyNowPrice: 500},
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 10, noBuyNow),
+			},
+		},
+		{
+			name: "cancellation",
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 10, ok),
+				op(1, client.OpCancel, "", 0, 20, 30, ok),
+				bid(0, "bob", 120, 40, 50, cancelled),
+				read(2, 60, 70, cancelledResult),
+			},
+			ok: true,
+		},
+		{
+			name: "accepted after cancellation",
+			history: []lincheck.Operation{
+				op(1, client.OpCancel, "", 0, 0, 10, ok),
+				bid(0, "alice", 100, 20, 30, ok),
+			},
+		},
+		{
+			name: "cancelled before it was",
+			history: []lincheck.Operation{
+				bid(0, "alice", 100, 0, 10, cancelled),
+				op(1, client.OpCancel, "", 0, 20, 30, ok),
+			},
+		},
+		{
+			name: "registration has no effect on the auction",
+			history: []lincheck.Operation{
+				op(0, client.OpRegister, "alice", 0, 0, 10, ok),
+				op(1, client.OpApprove, "alice", 0, 20, 30, ok),
+				read(2, 40, 50, noResult),
+			},
+			ok: true,
+		},
+		{
+			name: "an unknown operation",
+			history: []lincheck.Operation{
+				op(0, "transfer", "alice", 100, 0, 10, ok),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := lincheck.Check(lincheck.AuctionModel(test.config), test.history)
+			if result.Ok != test.ok {
+				t.Fatalf("Ok = %v, want %v", result.Ok, test.ok)
+			}
+			if !result.Ok && len(result.Violation) == 0 {
+				t.Fatal("a failed check has no violation")
+			}
+		})
+	}
+}
+
+// TestCheckMinimizesViolation has a stale read among operations that have
+// nothing to do with it. Only the bid the read missed and the read itself
+// are left.
+func TestCheckMinimizesViolation(t *testing.T) {
+	acked := bid(0, "alice", 100, 0, 10, ok)
+	stale := read(1, 20, 30, noResult)
+	history := []lincheck.Operation{
+		acked,
+		read(1, 12, 14, result(100, "alice")),
+		bid(2, "bob", 90, 15, 18, below),
+		stale,
+		bid(2, "carol", 130, 40, 50, ok),
+		read(0, 60, 70, result(130, "carol")),
+	}
+
+	result := lincheck.Check(lincheck.AuctionModel(auction.Config{}), history)
+	if result.Ok {
+		t.Fatal("a stale read passed")
+	}
+	want := []lincheck.Operation{acked, stale}
+	if !reflect.DeepEqual(result.Violation, want) {
+		t.Errorf("violation = %+v, want %+v", result.Violation, want)
+	}
+
+	var rendered strings.Builder
+	lincheck.Render(&rendered, lincheck.AuctionModel(auction.Config{}), result.Violation)
+	for _, line := range []string{"bid alice 100 -> ok", `result -> 0 by ""`, "no operation left can follow it"} {
+		if !strings.Contains(rendered.String(), line) {
+			t.Errorf("rendered violation has no %q:\n%s", line, rendered.String())
+		}
+	}
+}
+
+func TestFromClient(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	recorded := []client.Operation{
+		{Client: "a", Kind: client.OpBid, Bidder: "alice", Amount: 100, Outcome: pb.Outcome_SUCCESS, Call: at(0), Return: at(10)},
+		{Client: "b", Kind: client.OpResult, Err: "unavailable", Call: at(5), Return: at(15)},
+		{Client: "b", Kind: client.OpBid, Bidder: "bob", Amount: 120, Err: "unavailable", Call: at(20), Return: at(30)},
+		{Client: "a", Kind: client.OpHistory, Err: "unavailable", Call: at(40), Return: at(50)},
+	}
+	history := lincheck.FromClient(recorded)
+
+	want := []lincheck.Operation{
+		{
+			ClientID: 0,
+			Input:    lincheck.AuctionInput{Kind: client.OpBid, Bidder: "alice", Amount: 100},
+			Output:   lincheck.AuctionOutput{Outcome: pb.Outcome_SUCCESS},
+			Call:     0,
+			Return:   int64(10 * time.Millisecond),
+		},
+		{
+			ClientID: 1,
+			Input:    lincheck.AuctionInput{Kind: client.OpBid, Bidder: "bob", Amount: 120},
+			Output:   lincheck.AuctionOutput{Unknown: true},
+			Call:     int64(20 * time.Millisecond),
+			Return:   lincheck.Pending,
+		},
+	}
+	if !reflect.DeepEqual(history, want) {
+		t.Errorf("FromClient = %+v, want %+v", history, want)
+	}
+}
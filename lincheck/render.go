@@ -0,0 +1,88 @@
+package lincheck
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timelineWidth is how many columns Render uses for the time axis.
+const timelineWidth = 50
+
+// Render draws a history for debugging: a timeline with one row per
+// operation, then the longest order in which the operations could be
+// linearized, with the model state after each, and the operations left
+// over, none of which the search could place next. Times are taken to be
+// nanoseconds, as FromClient records them.
+//
+// It is meant for the Violation of a failed Check, which is small enough
+// to read.
+func Render(w io.Writer, model Model, history []Operation) {
+	ops := append([]Operation(nil), history...)
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].Call < ops[j].Call })
+	if len(ops) == 0 {
+		fmt.Fprintln(w, "empty history")
+		return
+	}
+
+	start, end := ops[0].Call, ops[0].Call
+	for _, op := range ops {
+		if op.Call > end {
+			end = op.Call
+		}
+		if op.Return != Pending && op.Return > end {
+			end = op.Return
+		}
+	}
+	column := func(t int64) int {
+		if end == start {
+			return 0
+		}
+		return int((t - start) * (timelineWidth - 1) / (end - start))
+	}
+
+	fmt.Fprintf(w, "%d operations, %v from first call to last return:\n\n", len(ops), time.Duration(end-start))
+	for _, op := range ops {
+		from := column(op.Call)
+		bar := strings.Repeat(" ", from) + "["
+		if op.Return == Pending {
+			bar += strings.Repeat("=", timelineWidth-from) + ">"
+		} else {
+			bar += strings.Repeat("=", column(op.Return)-from) + "]"
+		}
+		fmt.Fprintf(w, "  c%-3d %-*s  %s\n", op.ClientID, timelineWidth+2, bar, model.DescribeOperation(op.Input, op.Output))
+	}
+	fmt.Fprintln(w)
+
+	ok, longest := search(model, ops)
+	if ok {
+		fmt.Fprintln(w, "the history is linearizable")
+		return
+	}
+
+	fmt.Fprintln(w, "longest linearization:")
+	state := model.Init()
+	fmt.Fprintf(w, "  %-40s  %s\n", "(start)", model.DescribeState(state))
+	done := make(map[int]bool)
+	for _, i := range longest {
+		_, state = model.Step(state, ops[i].Input, ops[i].Output)
+		done[i] = true
+		fmt.Fprintf(w, "  %-40s  %s\n", model.DescribeOperation(ops[i].Input, ops[i].Output), model.DescribeState(state))
+	}
+	fmt.Fprintln(w, "no operation left can follow it:")
+	for i, op := range ops {
+		if !done[i] {
+			fmt.Fprintf(w, "  c%-3d %s (%s)\n", op.ClientID, model.DescribeOperation(op.Input, op.Output), interval(op, start))
+		}
+	}
+}
+
+func interval(op Operation, start int64) string {
+	call := time.Duration(op.Call - start)
+	if op.Return == Pending {
+		return fmt.Sprintf("called at %v, never returned", call)
+	}
+	return fmt.Sprintf("called at %v, returned at %v", call, time.Duration(op.Return-start))
+}
@@ -0,0 +1,62 @@
+package lincheck
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/joachimblom-hanssen/Distributed_5/client"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+)
+
+// WorkloadOptions configures RunWorkload.
+type WorkloadOptions struct {
+	Operations  int     // per client, defaults to 50
+	ResultRatio float64 // share of calls that are Result, defaults to 0.3
+	Seed        int64
+}
+
+// RunWorkload has every client bid and read the result concurrently, each
+// as its own bidder, and returns what they saw for Check. Bids are a
+// little above the highest bid the client last heard of, so some are
+// outbid by the time they arrive. Faults such as killing or partitioning
+// a node are up to the caller, while this runs.
+func RunWorkload(clients []*client.AuctionClient, opts WorkloadOptions) []client.Operation {
+	if opts.Operations <= 0 {
+		opts.Operations = 50
+	}
+	if opts.ResultRatio <= 0 {
+		opts.ResultRatio = 0.3
+	}
+
+	recorder := client.NewRecorder()
+	var wg sync.WaitGroup
+	for i, c := range clients {
+		name := fmt.Sprintf("bidder-%d", i+1)
+		c.Record(recorder, name)
+
+		wg.Add(1)
+		go func(c *client.AuctionClient, name string, r *rand.Rand) {
+			defer wg.Done()
+			var highest int64
+			for n := 0; n < opts.Operations; n++ {
+				if r.Float64() < opts.ResultRatio {
+					if result, err := c.GetResult(); err == nil {
+						highest = result.HighestBid
+					}
+					continue
+				}
+				response, err := c.PlaceBid(name, highest+r.Int63n(25)-4, 1)
+				if err == nil && response.Outcome != pb.Outcome_EXCEPTION {
+					highest = response.HighestBid
+				}
+			}
+		}(c, name, rand.New(rand.NewSource(opts.Seed+int64(i))))
+	}
+	wg.Wait()
+
+	for _, c := range clients {
+		c.Record(nil, "")
+	}
+	return recorder.Operations()
+}
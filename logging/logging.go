@@ -0,0 +1,132 @@
+// Package logging sets up structured logging with log/slog for the
+// servers and the client, so the logs of every replica can be merged and
+// queried.
+//
+// Every line a node logs carries its node ID, role and epoch, and, while
+// it handles a request, the request ID and trace ID.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// FromFlags returns a handler writing to w in format, json or text, at
+// level and above: debug, info, warn or error.
+func FromFlags(w io.Writer, format, level string) (slog.Handler, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.NewJSONHandler(w, opts), nil
+	case "text":
+		return slog.NewTextHandler(w, opts), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q (want json or text)", format)
+}
+
+// Node is the identity of a server or client in its logs. Its role and
+// epoch change when the backup takes over; the epoch is 1 under the
+// original primary and left out of the logs while it is 0, as for clients.
+type Node struct {
+	id    string
+	mutex sync.Mutex
+	role  string
+	epoch uint64
+}
+
+// NewNode returns a node called id serving in role at epoch.
+func NewNode(id, role string, epoch uint64) *Node {
+	return &Node{id: id, role: role, epoch: epoch}
+}
+
+// ID returns the node's ID.
+func (n *Node) ID() string {
+	return n.id
+}
+
+// SetRole changes the node's role and epoch.
+func (n *Node) SetRole(role string, epoch uint64) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.role, n.epoch = role, epoch
+}
+
+// Logger returns a logger that writes to base, or to the default logger if
+// it is nil, and adds the node's fields to every line.
+func (n *Node) Logger(base *slog.Logger) *slog.Logger {
+	if base == nil {
+		base = slog.Default()
+	}
+	return slog.New(&nodeHandler{Handler: base.Handler(), node: n})
+}
+
+// nodeHandler adds the node's fields and those of the request being
+// handled to every record.
+type nodeHandler struct {
+	slog.Handler
+	node *Node
+}
+
+func (h *nodeHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.node.mutex.Lock()
+	record.AddAttrs(slog.String("node", h.node.id), slog.String("role", h.node.role))
+	if h.node.epoch > 0 {
+		record.AddAttrs(slog.Uint64("epoch", h.node.epoch))
+	}
+	h.node.mutex.Unlock()
+
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *nodeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &nodeHandler{Handler: h.Handler.WithAttrs(attrs), node: h.node}
+}
+
+func (h *nodeHandler) WithGroup(name string) slog.Handler {
+	return &nodeHandler{Handler: h.Handler.WithGroup(name), node: h.node}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying a request ID for the logs.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID ctx carries, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ServerOptions put the request ID of every request that has one into the
+// context of its handler.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if r, ok := req.(interface{ GetRequestId() string }); ok {
+			ctx = WithRequestID(ctx, r.GetRequestId())
+		}
+		return handler(ctx, req)
+	})}
+}
@@ -0,0 +1,187 @@
+// Package metrics exports what the primary and backup servers do as
+// Prometheus metrics, served over HTTP at /metrics.
+//
+// Every method is safe to call on a nil *Metrics and then records nothing,
+// so servers built without metrics, such as those in clustertest and sim,
+// need no checks.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Role names used for the role gauge. A primary that handed over to the
+// backup serves in neither role.
+const (
+	RolePrimary = "primary"
+	RoleBackup  = "backup"
+	RoleNone    = "none"
+)
+
+// Reasons the backup takes over, used for the promotions counter.
+const (
+	PromotionFailover = "failover" // the primary stopped sending heartbeats
+	PromotionHandover = "handover" // the primary handed over with TakeOver
+)
+
+// Metrics holds the collectors of one server.
+type Metrics struct {
+	registry           *prometheus.Registry
+	bids               *prometheus.CounterVec
+	replicationLatency *prometheus.HistogramVec
+	heartbeatFailures  prometheus.Counter
+	dedupHits          prometheus.Counter
+	promotions         *prometheus.CounterVec
+	epoch              prometheus.Gauge
+	role               *prometheus.GaugeVec
+	replicationLag     prometheus.Gauge
+}
+
+// New returns metrics for a server starting in role, registered with a
+// registry of their own alongside the Go runtime and process collectors.
+func New(role string) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		bids: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auction_bids_total",
+			Help: "Bids the server executed, by outcome and reject reason.",
+		}, []string{"outcome", "reason"}),
+		replicationLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "auction_replication_latency_seconds",
+			Help:    "Time from sending an update to the backup until it was acknowledged or failed, by result.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2},
+		}, []string{"result"}),
+		heartbeatFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "auction_heartbeat_failures_total",
+			Help: "Heartbeats the primary failed to deliver to the backup.",
+		}),
+		dedupHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "auction_dedup_hits_total",
+			Help: "Requests and updates answered from the cache of processed request IDs.",
+		}),
+		promotions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auction_promotions_total",
+			Help: "Times the backup took over as primary, by reason: failover after missed heartbeats or a planned handover.",
+		}, []string{"reason"}),
+		epoch: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "auction_epoch",
+			Help: "Leadership epoch: 1 under the original primary, one more for each promotion.",
+		}),
+		role: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "auction_role",
+			Help: "1 for the role the server currently serves in, 0 otherwise.",
+		}, []string{"role"}),
+		replicationLag: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "auction_replication_lag_updates",
+			Help: "Updates the primary executed that the backup has not acknowledged.",
+		}),
+	}
+	m.registry.MustRegister(
+		m.bids, m.replicationLatency, m.heartbeatFailures, m.dedupHits,
+		m.promotions, m.epoch, m.role, m.replicationLag,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	m.epoch.Set(1)
+	m.SetRole(role)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ListenAndServe serves m at /metrics on addr. It only returns on error.
+func ListenAndServe(addr string, m *Metrics) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	return http.ListenAndServe(addr, mux)
+}
+
+// Bid counts a bid the server executed.
+func (m *Metrics) Bid(outcome pb.Outcome, reason pb.RejectReason) {
+	if m == nil {
+		return
+	}
+	m.bids.WithLabelValues(outcome.String(), reason.String()).Inc()
+}
+
+// Replicated records how long sending one update to the backup took and
+// whether it was acknowledged.
+func (m *Metrics) Replicated(latency time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.replicationLatency.WithLabelValues(result).Observe(latency.Seconds())
+}
+
+// HeartbeatFailed counts a heartbeat that did not reach the backup.
+func (m *Metrics) HeartbeatFailed() {
+	if m == nil {
+		return
+	}
+	m.heartbeatFailures.Inc()
+}
+
+// DedupHit counts a request answered from the processed-request cache.
+func (m *Metrics) DedupHit() {
+	if m == nil {
+		return
+	}
+	m.dedupHits.Inc()
+}
+
+// Promoted records the backup taking over as primary in epoch, for reason
+// PromotionFailover or PromotionHandover.
+func (m *Metrics) Promoted(epoch uint64, reason string) {
+	if m == nil {
+		return
+	}
+	m.promotions.WithLabelValues(reason).Inc()
+	m.epoch.Set(float64(epoch))
+	m.SetRole(RolePrimary)
+}
+
+// SteppedDown records the primary handing over to the backup, which
+// serves as primary in epoch.
+func (m *Metrics) SteppedDown(epoch uint64) {
+	if m == nil {
+		return
+	}
+	m.epoch.Set(float64(epoch))
+	m.SetRole(RoleNone)
+}
+
+// SetRole sets the role gauge to role.
+func (m *Metrics) SetRole(role string) {
+	if m == nil {
+		return
+	}
+	for _, r := range []string{RolePrimary, RoleBackup, RoleNone} {
+		value := 0.0
+		if r == role {
+			value = 1
+		}
+		m.role.WithLabelValues(r).Set(value)
+	}
+}
+
+// SetReplicationLag sets the number of updates the backup has yet to
+// acknowledge.
+func (m *Metrics) SetReplicationLag(updates int) {
+	if m == nil {
+		return
+	}
+	m.replicationLag.Set(float64(updates))
+}
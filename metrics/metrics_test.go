@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"maps"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// values returns the value of every series of the metric name, keyed by
+// the value of its label.
+func values(t *testing.T, m *Metrics, name, label string) map[string]float64 {
+	t.Helper()
+	families, err := m.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			values[labelValue(metric, label)] = metric.GetGauge().GetValue() + metric.GetCounter().GetValue()
+		}
+	}
+	return values
+}
+
+func labelValue(metric *dto.Metric, name string) string {
+	for _, pair := range metric.GetLabel() {
+		if pair.GetName() == name {
+			return pair.GetValue()
+		}
+	}
+	return ""
+}
+
+func TestRole(t *testing.T) {
+	m := New(RolePrimary)
+	want := map[string]float64{RolePrimary: 1, RoleBackup: 0, RoleNone: 0}
+	if got := values(t, m, "auction_role", "role"); !maps.Equal(got, want) {
+		t.Errorf("role = %v, want %v", got, want)
+	}
+
+	m.SteppedDown(2)
+	want = map[string]float64{RolePrimary: 0, RoleBackup: 0, RoleNone: 1}
+	if got := values(t, m, "auction_role", "role"); !maps.Equal(got, want) {
+		t.Errorf("role after stepping down = %v, want %v", got, want)
+	}
+}
+
+func TestPromotions(t *testing.T) {
+	m := New(RoleBackup)
+	m.Promoted(2, PromotionFailover)
+	m.Promoted(3, PromotionHandover)
+	m.Promoted(4, PromotionHandover)
+
+	want := map[string]float64{PromotionFailover: 1, PromotionHandover: 2}
+	if got := values(t, m, "auction_promotions_total", "reason"); !maps.Equal(got, want) {
+		t.Errorf("promotions = %v, want %v", got, want)
+	}
+	if got := values(t, m, "auction_epoch", ""); got[""] != 4 {
+		t.Errorf("epoch = %v, want 4", got[""])
+	}
+	if got := values(t, m, "auction_role", "role"); got[RolePrimary] != 1 || got[RoleBackup] != 0 {
+		t.Errorf("role = %v, want primary", got)
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	m.Promoted(2, PromotionFailover)
+	m.SteppedDown(2)
+	m.SetRole(RoleNone)
+}
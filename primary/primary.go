@@ -1,138 +1,587 @@
-package main
+package primary
 
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/clock"
+	"github.com/joachimblom-hanssen/Distributed_5/faults"
+	"github.com/joachimblom-hanssen/Distributed_5/logging"
+	"github.com/joachimblom-hanssen/Distributed_5/metrics"
 	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"github.com/joachimblom-hanssen/Distributed_5/ratelimit"
+	"github.com/joachimblom-hanssen/Distributed_5/rbac"
+	"github.com/joachimblom-hanssen/Distributed_5/replica"
+	"github.com/joachimblom-hanssen/Distributed_5/tracing"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
 type PrimaryServer struct {
 	pb.UnimplementedAuctionServiceServer
-	auctionState      *auction.Auction
-	processedRequests map[string]*pb.BidResponse
+	pb.UnimplementedAdminServiceServer
+	state             *replica.State
+	backupAddr        string
+	backupConn        *grpc.ClientConn
 	backupClient      pb.ReplicationServiceClient
+	outbox            *auction.Outbox
+	clock             clock.Clock
 	mutex             sync.Mutex
+	faults            *faults.Injector
+	replicationFaults *faults.Injector
+	rateLimits        *ratelimit.Limiter
+	metrics           *metrics.Metrics
+	logger            *slog.Logger
+	node              *logging.Node
+	health            *health.Server
+	stops             []func()
+	closeOnce         sync.Once
+
+	// Set once the server handed the primary role over to the backup, or
+	// found the backup had taken over; the backup serves as primary in
+	// epoch from then on
+	steppedDown bool
+	epoch       uint64
+
+	// Every update executed, in sequence order, and how many of them the
+	// backup has acknowledged
+	log        []*pb.UpdateRequest
+	replicated uint64
+
+	// When the backup last acknowledged a heartbeat
+	lastHeartbeat  time.Time
+	heartbeatMutex sync.Mutex
 }
 
-func NewPrimaryServer(backupAddress string) (*PrimaryServer, error) {
-	conn, err := grpc.Dial(backupAddress, grpc.WithInsecure())
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to backup: %v", err)
+// Options configures a PrimaryServer.
+type Options struct {
+	BackupAddress string
+	StartTime     time.Time
+	Config        auction.Config
+	Registry      *auction.Registry // nil means no registration
+	Admins        []string
+	Sinks         []auction.SettlementSink
+	// DialOptions are added to the options used to dial the backup.
+	DialOptions []grpc.DialOption
+	// Replication, if set, carries updates and heartbeats to the backup
+	// instead of a connection to BackupAddress, e.g. a simulated network.
+	Replication pb.ReplicationServiceClient
+	// Clock defaults to the wall clock.
+	Clock clock.Clock
+	// Faults is the injector on the calls this server receives, and
+	// ReplicationFaults the one on its calls to the backup. SetFaults
+	// changes their rules; nil leaves fault injection off. ReplicationFaults
+	// only applies to the connection dialed to BackupAddress.
+	Faults            *faults.Injector
+	ReplicationFaults *faults.Injector
+	// RateLimits limits the AuctionService calls this server receives.
+	// SetRateLimits changes its limits; nil leaves rate limiting off.
+	RateLimits *ratelimit.Limiter
+	// Metrics records what the server does; nil records nothing.
+	Metrics *metrics.Metrics
+	// Node is the server's identity in its logs, with the role and epoch
+	// it serves in; nil means a node called "primary". Logger is where it logs;
+	// nil means the default logger.
+	Node   *logging.Node
+	Logger *slog.Logger
+	// Policy, if set, decides who may use admin permissions in place of
+	// Admins.
+	Policy *rbac.Policy
+	// Health, if set, is told which services the server is serving.
+	Health *health.Server
+}
+
+func NewPrimaryServer(opts Options) (*PrimaryServer, error) {
+	if opts.Clock == nil {
+		opts.Clock = clock.Real
+	}
+	if opts.Node == nil {
+		opts.Node = logging.NewNode(metrics.RolePrimary, metrics.RolePrimary, 1)
 	}
 
 	s := &PrimaryServer{
-		auctionState:      auction.NewAuction(time.Now()),
-		processedRequests: make(map[string]*pb.BidResponse),
-		backupClient:      pb.NewReplicationServiceClient(conn),
-	}
-	
-	// Start sending periodic heartbeats
-	go s.sendHeartbeats()
-	
+		state: replica.New(replica.Options{
+			StartTime: opts.StartTime,
+			Config:    opts.Config,
+			Registry:  opts.Registry,
+			Admins:    opts.Admins,
+			Policy:    opts.Policy,
+			Clock:     opts.Clock,
+			Metrics:   opts.Metrics,
+		}),
+		backupAddr:        opts.BackupAddress,
+		backupClient:      opts.Replication,
+		clock:             opts.Clock,
+		faults:            opts.Faults,
+		replicationFaults: opts.ReplicationFaults,
+		rateLimits:        opts.RateLimits,
+		metrics:           opts.Metrics,
+		logger:            opts.Node.Logger(opts.Logger),
+		node:              opts.Node,
+		health:            opts.Health,
+		epoch:             1,
+	}
+	s.outbox = auction.NewOutbox(opts.Sinks, s.logger)
+
+	if s.backupClient == nil {
+		dialOptions := append([]grpc.DialOption{grpc.WithInsecure()}, opts.DialOptions...)
+		dialOptions = append(dialOptions, faults.DialOptions(opts.ReplicationFaults)...)
+		conn, err := grpc.Dial(opts.BackupAddress, dialOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to backup: %v", err)
+		}
+		s.backupConn = conn
+		s.backupClient = pb.NewReplicationServiceClient(conn)
+	}
+
+	if s.health != nil {
+		s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+		s.health.SetServingStatus(pb.AuctionService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+		s.health.SetServingStatus(pb.AdminService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	}
+
+	// Start sending periodic heartbeats, opening and closing the auction
+	// on schedule and delivering its settlement
+	s.stops = append(s.stops,
+		s.clock.Every(2*time.Second, s.sendHeartbeat),
+		s.clock.Every(auction.SchedulerInterval, s.fireDueTransition),
+		s.clock.Every(auction.OutboxInterval, s.deliverSettlement),
+	)
+
 	return s, nil
 }
 
-// sendHeartbeats sends periodic heartbeat messages to backup
-func (s *PrimaryServer) sendHeartbeats() {
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-	
-	for range ticker.C {
-		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-		_, err := s.backupClient.Heartbeat(ctx, &pb.HeartbeatRequest{})
-		cancel()
-		
-		if err != nil {
-			log.Printf("Failed to send heartbeat to backup: %v", err)
+// Close stops the heartbeats and the scheduler, reports every service as
+// not serving and drops the connection to the backup. It does not stop the
+// gRPC server serving s.
+func (s *PrimaryServer) Close() {
+	s.closeOnce.Do(func() {
+		for _, stop := range s.stops {
+			stop()
+		}
+		if s.health != nil {
+			s.health.Shutdown()
 		}
+		if s.backupConn != nil {
+			s.backupConn.Close()
+		}
+	})
+}
+
+// sendHeartbeat sends one heartbeat message to backup, with the last
+// sequence the backup acknowledged. It holds the lock, like replication,
+// so that sequence is up to date.
+func (s *PrimaryServer) sendHeartbeat() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.steppedDown {
+		return
+	}
+
+	if err := s.heartbeat(context.Background()); err != nil && !s.steppedDown {
+		s.logger.Warn("Failed to send heartbeat to backup", "err", err)
+		s.metrics.HeartbeatFailed()
 	}
 }
 
+// heartbeat sends the backup one heartbeat and steps down if the backup
+// has taken over. A heartbeat the backup accepts shows it still follows
+// this primary, in this epoch, with every update the primary sent. Must be
+// called with s.mutex held.
+func (s *PrimaryServer) heartbeat(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	_, err := s.backupClient.Heartbeat(ctx, &pb.HeartbeatRequest{Epoch: s.epoch, Sequence: s.replicated})
+	if epoch, fenced := auction.FencedEpoch(err); fenced {
+		s.logger.ErrorContext(ctx, "Backup has taken over, stepping down", "epoch", epoch)
+		s.stepDown(epoch)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	s.heartbeatMutex.Lock()
+	s.lastHeartbeat = s.clock.Now()
+	s.heartbeatMutex.Unlock()
+	return nil
+}
+
 func (s *PrimaryServer) Bid(ctx context.Context, req *pb.BidRequest) (*pb.BidResponse, error) {
+	return s.execute(ctx, s.state.BidUpdate(req))
+}
+
+// BuyNow ends the auction for the caller at the buy-now price
+func (s *PrimaryServer) BuyNow(ctx context.Context, req *pb.BuyNowRequest) (*pb.BidResponse, error) {
+	return s.execute(ctx, s.state.BuyNowUpdate(req))
+}
+
+// RetractBid withdraws the latest bid of req.ClientId. Bidders may only
+// retract their own bids; admins may retract anyone's.
+func (s *PrimaryServer) RetractBid(ctx context.Context, req *pb.RetractRequest) (*pb.BidResponse, error) {
+	return s.execute(ctx, s.state.RetractUpdate(req))
+}
+
+// Register adds a bidder with a credit limit. Depending on configuration
+// they may have to be approved by an admin before they can bid.
+func (s *PrimaryServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.BidResponse, error) {
+	return s.execute(ctx, s.state.RegisterUpdate(req))
+}
+
+// ApproveBidder lets a registered bidder start bidding. Only admins may approve.
+func (s *PrimaryServer) ApproveBidder(ctx context.Context, req *pb.ApproveRequest) (*pb.BidResponse, error) {
+	return s.execute(ctx, s.state.ApproveUpdate(req))
+}
+
+// CancelAuction calls the auction off. Only admins may cancel, and only
+// before the auction has ended.
+func (s *PrimaryServer) CancelAuction(ctx context.Context, req *pb.CancelRequest) (*pb.BidResponse, error) {
+	return s.execute(ctx, s.state.CancelUpdate(req))
+}
+
+// execute runs a write: a retry gets the response decided the first time,
+// and a new request is executed and replicated to the backup before it is
+// answered. Updates reach the backup strictly in sequence order, so while
+// one has not been acknowledged no new request is executed; each write
+// resends it first, and fails without running if it still cannot be
+// replicated.
+func (s *PrimaryServer) execute(ctx context.Context, update *pb.UpdateRequest) (*pb.BidResponse, error) {
+	stages := tracing.NewStages(ctx, "lock")
+	defer stages.End()
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	// Once stepped down, writes go to the backup
+	if s.steppedDown {
+		return nil, auction.NotPrimaryError(s.backupAddr)
+	}
+
 	// Stage 2: Coordination - check for duplicate request
-	if cachedResponse, exists := s.processedRequests[req.RequestId]; exists {
-		log.Printf("Duplicate request %s, returning cached response", req.RequestId)
-		return cachedResponse, nil
+	stages.Next("dedup")
+	response, duplicate := s.state.Processed(update.RequestId)
+	if duplicate && response.Sequence <= s.replicated {
+		s.logger.InfoContext(ctx, "Duplicate request, returning cached response")
+		s.metrics.DedupHit()
+		return response, nil
 	}
 
-	// Stage 3: Execution
-	outcome := s.auctionState.PlaceBid(req.ClientId, req.Amount, time.Now())
-	
-	response := &pb.BidResponse{
-		Outcome: outcome,
-		Message: outcomeMessage(outcome, req.Amount),
+	// A retry whose update never reached the backup, or a new request
+	// while any update has not, first resends what the backup is missing
+	if s.replicated < uint64(len(s.log)) {
+		if err := s.replicate(stages.Next("replication")); err != nil {
+			stages.Fail(err)
+			return nil, s.replicationError(err)
+		}
+	}
+	if duplicate {
+		s.metrics.DedupHit()
+		return response, nil
 	}
 
+	// Stage 3: Execution
+	stages.Next("execution")
+	response = s.state.Execute(update)
+	s.log = append(s.log, update)
+
 	// Stage 4: Agreement - replicate to backup and wait for ACK
-	update := &pb.UpdateRequest{
-		RequestId: req.RequestId,
-		Type:      pb.UpdateType_BID,
-		Amount:    req.Amount,
-		ClientId:  req.ClientId,
-		Outcome:   outcome,
+	if err := s.replicate(stages.Next("replication")); err != nil {
+		stages.Fail(err)
+		return nil, s.replicationError(err)
 	}
 
-	if err := s.replicateToBackup(ctx, update); err != nil {
-		log.Printf("Failed to replicate to backup: %v", err)
-		return &pb.BidResponse{
-			Outcome: pb.Outcome_EXCEPTION,
-			Message: "replication failed",
-		}, nil
+	// Stage 5: Response
+	stages.Next("response")
+	return response, nil
+}
+
+// SetFaults replaces the fault-injection rules on the calls this server
+// receives, or on its calls to the backup. Only admins may change them,
+// and only on a server started with fault injection. Rules are not
+// replicated.
+func (s *PrimaryServer) SetFaults(ctx context.Context, req *pb.SetFaultsRequest) (*pb.FaultsResponse, error) {
+	if !s.state.IsAdmin(req.RequestedBy, rbac.SetFaults) {
+		return nil, status.Errorf(codes.PermissionDenied, "%q may not set faults", req.RequestedBy)
 	}
 
-	s.processedRequests[req.RequestId] = response
+	injector := s.faults
+	if req.Replication {
+		injector = s.replicationFaults
+	}
+	if injector == nil {
+		return nil, status.Error(codes.FailedPrecondition, "fault injection is not enabled")
+	}
 
-	// Stage 5: Response
-	return response, nil
+	if err := injector.Set(req.Rules); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.logger.InfoContext(ctx, "Fault injection rules set", "by", req.RequestedBy, "replication", req.Replication, "rules", faults.FormatRules(req.Rules))
+	return &pb.FaultsResponse{Rules: injector.Rules()}, nil
+}
+
+// SetRateLimits replaces the rate limits on the AuctionService calls this
+// server receives. Only admins may change them, and only on a server with
+// a rate limiter. Limits are not replicated.
+func (s *PrimaryServer) SetRateLimits(ctx context.Context, req *pb.SetRateLimitsRequest) (*pb.RateLimits, error) {
+	if !s.state.IsAdmin(req.RequestedBy, rbac.SetRateLimits) {
+		return nil, status.Errorf(codes.PermissionDenied, "%q may not set rate limits", req.RequestedBy)
+	}
+	if s.rateLimits == nil {
+		return nil, status.Error(codes.FailedPrecondition, "rate limiting is not enabled")
+	}
+
+	if err := s.rateLimits.Set(req.Limits); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.logger.InfoContext(ctx, "Rate limits set", "by", req.RequestedBy, "limits", ratelimit.FormatLimits(req.Limits))
+	return s.rateLimits.Limits(), nil
 }
 
+// GetClusterStatus reports this node's role and epoch, the backup, when
+// the backup last acknowledged a heartbeat and the last sequence assigned
+func (s *PrimaryServer) GetClusterStatus(ctx context.Context, req *pb.ClusterStatusRequest) (*pb.ClusterStatus, error) {
+	s.heartbeatMutex.Lock()
+	lastHeartbeat := s.lastHeartbeat
+	s.heartbeatMutex.Unlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Once stepped down the backup is primary
+	role, peerRole := pb.Role_ROLE_PRIMARY, pb.Role_ROLE_BACKUP
+	if s.steppedDown {
+		role, peerRole = pb.Role_ROLE_NONE, pb.Role_ROLE_PRIMARY
+	}
+	status := &pb.ClusterStatus{
+		NodeId:              s.node.ID(),
+		Role:                role,
+		Epoch:               s.epoch,
+		Peers:               []*pb.Peer{{Address: s.backupAddr, Role: peerRole}},
+		LastAppliedSequence: s.state.Sequence(),
+	}
+	if !lastHeartbeat.IsZero() {
+		status.LastHeartbeat = lastHeartbeat.UnixNano()
+	}
+	return status, nil
+}
+
+// TransferLeadership hands the primary role over to the backup. Only
+// admins may transfer leadership.
+func (s *PrimaryServer) TransferLeadership(ctx context.Context, req *pb.TransferLeadershipRequest) (*pb.TransferLeadershipResponse, error) {
+	if !s.state.IsAdmin(req.RequestedBy, rbac.TransferLeadership) {
+		return nil, status.Errorf(codes.PermissionDenied, "%q may not transfer leadership", req.RequestedBy)
+	}
+
+	leader, epoch, err := s.HandOver(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.TransferLeadershipResponse{Leader: leader, Epoch: epoch}, nil
+}
+
+// HandOver makes the backup primary without waiting for it to detect a
+// failure, and returns its address and the epoch it serves in. Holding the
+// lock, so no write is in flight, it resends every update the backup has
+// not acknowledged and asks the backup to take over; from then on writes
+// and results are redirected to the backup and the scheduler and
+// heartbeats stop. Handing over again returns the same leader.
+//
+// If the backup refuses, the server stays primary. If the request is lost,
+// the backup may have taken over all the same, so the server steps down
+// anyway rather than risk two primaries; with heartbeats stopped, a backup
+// that did not take over promotes itself once it detects the failure.
+func (s *PrimaryServer) HandOver(ctx context.Context) (string, uint64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.steppedDown {
+		return s.backupAddr, s.epoch, nil
+	}
+
+	// Bring the backup up to date
+	if err := s.replicate(ctx); err != nil {
+		return "", 0, s.replicationError(err)
+	}
+
+	takeOverCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	response, err := s.backupClient.TakeOver(takeOverCtx, &pb.TakeOverRequest{Sequence: s.state.Sequence()})
+	if status.Code(err) == codes.FailedPrecondition || status.Code(err) == codes.Unimplemented {
+		s.logger.ErrorContext(ctx, "Backup refused to take over", "err", err)
+		return "", 0, status.Errorf(codes.FailedPrecondition, "backup refused to take over: %v", err)
+	}
+
+	if err == nil {
+		s.stepDown(response.Epoch)
+	} else {
+		s.stepDown(s.epoch + 1)
+	}
+
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Lost the request to take over; stepping down in case the backup took over", "backup", s.backupAddr, "err", err)
+		return "", 0, status.Errorf(codes.Unavailable, "backup may not have taken over: %v", err)
+	}
+	s.logger.InfoContext(ctx, "Handed over to the backup", "leader", s.backupAddr, "sequence", s.state.Sequence())
+	return s.backupAddr, s.epoch, nil
+}
+
+// stepDown stops serving as primary, leaving the role to the backup,
+// which serves in epoch: writes and results are redirected to the backup
+// and the scheduler and heartbeats stop. Must be called with s.mutex held.
+func (s *PrimaryServer) stepDown(epoch uint64) {
+	s.steppedDown = true
+	s.epoch = epoch
+	s.node.SetRole(metrics.RoleNone, s.epoch)
+	s.metrics.SteppedDown(s.epoch)
+	if s.health != nil {
+		s.health.SetServingStatus(pb.AuctionService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	for _, stop := range s.stops {
+		stop()
+	}
+}
+
+// History returns every bid with the timestamp and sequence the primary
+// assigned to it
+func (s *PrimaryServer) History(ctx context.Context, req *pb.HistoryRequest) (*pb.HistoryResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.state.History(), nil
+}
+
+// Result reports the auction as the backup has it too. An update the
+// backup has not acknowledged is resent first, since a reader who saw it
+// would see it lost if the primary then failed. A heartbeat then confirms
+// the backup still follows this primary, so one that was replaced, or
+// restarted empty, refuses rather than answer from stale state.
 func (s *PrimaryServer) Result(ctx context.Context, req *pb.ResultRequest) (*pb.ResultResponse, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	status, highestBid, winner := s.auctionState.GetResult(time.Now())
+	// Once stepped down only the backup's result is up to date
+	if s.steppedDown {
+		return nil, auction.NotPrimaryError(s.backupAddr)
+	}
+
+	if err := s.replicate(ctx); err != nil {
+		return nil, s.replicationError(err)
+	}
+	if err := s.heartbeat(ctx); err != nil {
+		// The backup refuses to follow a primary that lost updates
+		if s.steppedDown || status.Code(err) == codes.FailedPrecondition {
+			return nil, auction.NotPrimaryError(s.backupAddr)
+		}
+		return nil, auction.ReplicationError(err)
+	}
+
+	return s.state.Result(), nil
+}
 
-	return &pb.ResultResponse{
-		Status:     status,
-		HighestBid: highestBid,
-		Winner:     winner,
-	}, nil
+// replicate sends the backup every update it has not acknowledged,
+// oldest first, and stops at the first that fails. A backup that is
+// missing earlier updates, e.g. because it restarted, refuses with the
+// sequence it expects next, and is brought up to date from there.
+func (s *PrimaryServer) replicate(ctx context.Context) error {
+	for s.replicated < uint64(len(s.log)) {
+		update := s.log[s.replicated]
+		err := s.replicateToBackup(logging.WithRequestID(ctx, update.RequestId), update)
+		if next, ok := auction.ExpectedSequence(err); ok && next >= 1 && next < update.Sequence {
+			s.logger.WarnContext(ctx, "Backup is behind, resending updates", "from", next)
+			s.replicated = next - 1
+			continue
+		}
+		if epoch, fenced := auction.FencedEpoch(err); fenced {
+			s.logger.ErrorContext(ctx, "Backup has taken over, stepping down", "epoch", epoch)
+			s.stepDown(epoch)
+			return err
+		}
+		if err != nil {
+			s.logger.ErrorContext(ctx, "Failed to replicate to backup", "sequence", update.Sequence, "err", err)
+			s.metrics.SetReplicationLag(len(s.log) - int(s.replicated))
+			return err
+		}
+		s.replicated++
+	}
+	s.metrics.SetReplicationLag(0)
+	return nil
+}
+
+// replicationError is the error for a write that could not be
+// replicated: a redirect if the backup turned out to have taken over.
+func (s *PrimaryServer) replicationError(err error) error {
+	if s.steppedDown {
+		return auction.NotPrimaryError(s.backupAddr)
+	}
+	return auction.ReplicationError(err)
 }
 
 func (s *PrimaryServer) replicateToBackup(ctx context.Context, update *pb.UpdateRequest) error {
 	ackCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
+	update.Epoch = s.epoch
+	sent := s.clock.Now()
 	ack, err := s.backupClient.ReplicateUpdate(ackCtx, update)
-	if err != nil {
-		return err
+	if err == nil && !ack.Acknowledged {
+		err = fmt.Errorf("backup did not acknowledge update")
 	}
+	s.metrics.Replicated(s.clock.Now().Sub(sent), err)
+
+	return err
+}
+
+// fireDueTransition records a transition that has come due and replicates
+// it like any other update, in order after every earlier one. Each
+// transition has a fixed request ID, and a transition is only due while
+// the auction has not recorded it, so a backup that already applied it
+// never fires it again after failover.
+func (s *PrimaryServer) fireDueTransition() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	if !ack.Acknowledged {
-		return fmt.Errorf("backup did not acknowledge update")
+	if s.steppedDown {
+		return
 	}
 
-	return nil
+	// Nothing new is recorded while an earlier update has not reached the
+	// backup
+	if err := s.replicate(context.Background()); err != nil {
+		return
+	}
+
+	update, due := s.state.DueTransition()
+	if !due {
+		return
+	}
+
+	ctx := logging.WithRequestID(context.Background(), update.RequestId)
+	response := s.state.Execute(update)
+	s.log = append(s.log, update)
+	s.logger.InfoContext(ctx, "Scheduler: "+response.Message, "transition", update.Type.String())
+
+	s.replicate(ctx)
 }
 
-func outcomeMessage(outcome pb.Outcome, amount int32) string {
-	switch outcome {
-	case pb.Outcome_SUCCESS:
-		return fmt.Sprintf("bid of %d accepted", amount)
-	case pb.Outcome_FAIL:
-		return "bid rejected - too low or auction closed"
-	case pb.Outcome_EXCEPTION:
-		return "invalid bid amount"
-	default:
-		return "unknown outcome"
+// deliverSettlement queues the recorded settlement for the sinks once the
+// backup has it too, so a backup that takes over delivers the same event
+// instead of settling again, and delivers it outside the lock.
+func (s *PrimaryServer) deliverSettlement() {
+	s.mutex.Lock()
+	event, settled := s.state.Auction().Settled()
+	ready := settled && !s.steppedDown && s.replicated == uint64(len(s.log))
+	s.mutex.Unlock()
+
+	if ready {
+		s.outbox.Add(event)
 	}
+	s.outbox.Flush()
 }
@@ -70,11 +70,121 @@ func (Outcome) EnumDescriptor() ([]byte, []int) {
 	return file_proto_auction_proto_rawDescGZIP(), []int{0}
 }
 
+// Why an operation did not succeed. REASON_NONE accompanies SUCCESS.
+// Infrastructure failures (not the primary, replication failed) are not
+// reasons: they are returned as gRPC Unavailable or Aborted errors with
+// ErrorInfo and RetryInfo details.
+type RejectReason int32
+
+const (
+	RejectReason_REASON_NONE         RejectReason = 0
+	RejectReason_BELOW_HIGHEST       RejectReason = 1
+	RejectReason_BELOW_OWN_PREVIOUS  RejectReason = 2
+	RejectReason_AUCTION_CLOSED      RejectReason = 3
+	RejectReason_AUCTION_NOT_STARTED RejectReason = 4
+	RejectReason_INVALID_AMOUNT      RejectReason = 5
+	RejectReason_INVALID_QUANTITY    RejectReason = 6
+	RejectReason_CURRENCY_MISMATCH   RejectReason = 7
+	// Deprecated: Marked as deprecated in proto/auction.proto.
+	RejectReason_NOT_PRIMARY RejectReason = 8 // no longer sent
+	// Deprecated: Marked as deprecated in proto/auction.proto.
+	RejectReason_REPLICATION_FAILED     RejectReason = 9 // no longer sent
+	RejectReason_NOT_REGISTERED         RejectReason = 10
+	RejectReason_NOT_APPROVED           RejectReason = 11
+	RejectReason_CREDIT_LIMIT_EXCEEDED  RejectReason = 12
+	RejectReason_ALREADY_REGISTERED     RejectReason = 13
+	RejectReason_INVALID_REGISTRATION   RejectReason = 14
+	RejectReason_BUY_NOW_UNAVAILABLE    RejectReason = 15
+	RejectReason_NO_BID_TO_RETRACT      RejectReason = 16
+	RejectReason_RETRACTION_NOT_ALLOWED RejectReason = 17
+	RejectReason_NOT_AUTHORIZED         RejectReason = 18
+	RejectReason_AUCTION_CANCELLED      RejectReason = 19
+)
+
+// Enum value maps for RejectReason.
+var (
+	RejectReason_name = map[int32]string{
+		0:  "REASON_NONE",
+		1:  "BELOW_HIGHEST",
+		2:  "BELOW_OWN_PREVIOUS",
+		3:  "AUCTION_CLOSED",
+		4:  "AUCTION_NOT_STARTED",
+		5:  "INVALID_AMOUNT",
+		6:  "INVALID_QUANTITY",
+		7:  "CURRENCY_MISMATCH",
+		8:  "NOT_PRIMARY",
+		9:  "REPLICATION_FAILED",
+		10: "NOT_REGISTERED",
+		11: "NOT_APPROVED",
+		12: "CREDIT_LIMIT_EXCEEDED",
+		13: "ALREADY_REGISTERED",
+		14: "INVALID_REGISTRATION",
+		15: "BUY_NOW_UNAVAILABLE",
+		16: "NO_BID_TO_RETRACT",
+		17: "RETRACTION_NOT_ALLOWED",
+		18: "NOT_AUTHORIZED",
+		19: "AUCTION_CANCELLED",
+	}
+	RejectReason_value = map[string]int32{
+		"REASON_NONE":            0,
+		"BELOW_HIGHEST":          1,
+		"BELOW_OWN_PREVIOUS":     2,
+		"AUCTION_CLOSED":         3,
+		"AUCTION_NOT_STARTED":    4,
+		"INVALID_AMOUNT":         5,
+		"INVALID_QUANTITY":       6,
+		"CURRENCY_MISMATCH":      7,
+		"NOT_PRIMARY":            8,
+		"REPLICATION_FAILED":     9,
+		"NOT_REGISTERED":         10,
+		"NOT_APPROVED":           11,
+		"CREDIT_LIMIT_EXCEEDED":  12,
+		"ALREADY_REGISTERED":     13,
+		"INVALID_REGISTRATION":   14,
+		"BUY_NOW_UNAVAILABLE":    15,
+		"NO_BID_TO_RETRACT":      16,
+		"RETRACTION_NOT_ALLOWED": 17,
+		"NOT_AUTHORIZED":         18,
+		"AUCTION_CANCELLED":      19,
+	}
+)
+
+func (x RejectReason) Enum() *RejectReason {
+	p := new(RejectReason)
+	*p = x
+	return p
+}
+
+func (x RejectReason) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RejectReason) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_auction_proto_enumTypes[1].Descriptor()
+}
+
+func (RejectReason) Type() protoreflect.EnumType {
+	return &file_proto_auction_proto_enumTypes[1]
+}
+
+func (x RejectReason) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RejectReason.Descriptor instead.
+func (RejectReason) EnumDescriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{1}
+}
+
+// A SCHEDULED auction opens at its start time and an ONGOING one closes
+// at its end time. CANCELLED auctions have no winner.
 type AuctionStatus int32
 
 const (
-	AuctionStatus_ONGOING AuctionStatus = 0
-	AuctionStatus_CLOSED  AuctionStatus = 1
+	AuctionStatus_ONGOING   AuctionStatus = 0
+	AuctionStatus_CLOSED    AuctionStatus = 1
+	AuctionStatus_SCHEDULED AuctionStatus = 2
+	AuctionStatus_CANCELLED AuctionStatus = 3
 )
 
 // Enum value maps for AuctionStatus.
@@ -82,10 +192,14 @@ var (
 	AuctionStatus_name = map[int32]string{
 		0: "ONGOING",
 		1: "CLOSED",
+		2: "SCHEDULED",
+		3: "CANCELLED",
 	}
 	AuctionStatus_value = map[string]int32{
-		"ONGOING": 0,
-		"CLOSED":  1,
+		"ONGOING":   0,
+		"CLOSED":    1,
+		"SCHEDULED": 2,
+		"CANCELLED": 3,
 	}
 )
 
@@ -100,11 +214,11 @@ func (x AuctionStatus) String() string {
 }
 
 func (AuctionStatus) Descriptor() protoreflect.EnumDescriptor {
-	return file_proto_auction_proto_enumTypes[1].Descriptor()
+	return file_proto_auction_proto_enumTypes[2].Descriptor()
 }
 
 func (AuctionStatus) Type() protoreflect.EnumType {
-	return &file_proto_auction_proto_enumTypes[1]
+	return &file_proto_auction_proto_enumTypes[2]
 }
 
 func (x AuctionStatus) Number() protoreflect.EnumNumber {
@@ -113,22 +227,138 @@ func (x AuctionStatus) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use AuctionStatus.Descriptor instead.
 func (AuctionStatus) EnumDescriptor() ([]byte, []int) {
-	return file_proto_auction_proto_rawDescGZIP(), []int{1}
+	return file_proto_auction_proto_rawDescGZIP(), []int{2}
+}
+
+type PricingRule int32
+
+const (
+	PricingRule_UNIFORM_PRICE PricingRule = 0
+	PricingRule_PAY_AS_BID    PricingRule = 1
+)
+
+// Enum value maps for PricingRule.
+var (
+	PricingRule_name = map[int32]string{
+		0: "UNIFORM_PRICE",
+		1: "PAY_AS_BID",
+	}
+	PricingRule_value = map[string]int32{
+		"UNIFORM_PRICE": 0,
+		"PAY_AS_BID":    1,
+	}
+)
+
+func (x PricingRule) Enum() *PricingRule {
+	p := new(PricingRule)
+	*p = x
+	return p
+}
+
+func (x PricingRule) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PricingRule) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_auction_proto_enumTypes[3].Descriptor()
+}
+
+func (PricingRule) Type() protoreflect.EnumType {
+	return &file_proto_auction_proto_enumTypes[3]
+}
+
+func (x PricingRule) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PricingRule.Descriptor instead.
+func (PricingRule) EnumDescriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{3}
+}
+
+type TiePolicy int32
+
+const (
+	TiePolicy_REJECT_EQUAL  TiePolicy = 0
+	TiePolicy_EARLIEST_WINS TiePolicy = 1
+)
+
+// Enum value maps for TiePolicy.
+var (
+	TiePolicy_name = map[int32]string{
+		0: "REJECT_EQUAL",
+		1: "EARLIEST_WINS",
+	}
+	TiePolicy_value = map[string]int32{
+		"REJECT_EQUAL":  0,
+		"EARLIEST_WINS": 1,
+	}
+)
+
+func (x TiePolicy) Enum() *TiePolicy {
+	p := new(TiePolicy)
+	*p = x
+	return p
+}
+
+func (x TiePolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TiePolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_auction_proto_enumTypes[4].Descriptor()
+}
+
+func (TiePolicy) Type() protoreflect.EnumType {
+	return &file_proto_auction_proto_enumTypes[4]
+}
+
+func (x TiePolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TiePolicy.Descriptor instead.
+func (TiePolicy) EnumDescriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{4}
 }
 
 type UpdateType int32
 
 const (
-	UpdateType_BID UpdateType = 0
+	UpdateType_BID      UpdateType = 0
+	UpdateType_BUY_NOW  UpdateType = 1
+	UpdateType_RETRACT  UpdateType = 2
+	UpdateType_REGISTER UpdateType = 3
+	UpdateType_APPROVE  UpdateType = 4
+	UpdateType_OPEN     UpdateType = 5
+	UpdateType_CLOSE    UpdateType = 6
+	UpdateType_CANCEL   UpdateType = 7
+	UpdateType_SETTLE   UpdateType = 8
 )
 
 // Enum value maps for UpdateType.
 var (
 	UpdateType_name = map[int32]string{
 		0: "BID",
+		1: "BUY_NOW",
+		2: "RETRACT",
+		3: "REGISTER",
+		4: "APPROVE",
+		5: "OPEN",
+		6: "CLOSE",
+		7: "CANCEL",
+		8: "SETTLE",
 	}
 	UpdateType_value = map[string]int32{
-		"BID": 0,
+		"BID":      0,
+		"BUY_NOW":  1,
+		"RETRACT":  2,
+		"REGISTER": 3,
+		"APPROVE":  4,
+		"OPEN":     5,
+		"CLOSE":    6,
+		"CANCEL":   7,
+		"SETTLE":   8,
 	}
 )
 
@@ -143,11 +373,11 @@ func (x UpdateType) String() string {
 }
 
 func (UpdateType) Descriptor() protoreflect.EnumDescriptor {
-	return file_proto_auction_proto_enumTypes[2].Descriptor()
+	return file_proto_auction_proto_enumTypes[5].Descriptor()
 }
 
 func (UpdateType) Type() protoreflect.EnumType {
-	return &file_proto_auction_proto_enumTypes[2]
+	return &file_proto_auction_proto_enumTypes[5]
 }
 
 func (x UpdateType) Number() protoreflect.EnumNumber {
@@ -156,33 +386,1357 @@ func (x UpdateType) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use UpdateType.Descriptor instead.
 func (UpdateType) EnumDescriptor() ([]byte, []int) {
-	return file_proto_auction_proto_rawDescGZIP(), []int{2}
+	return file_proto_auction_proto_rawDescGZIP(), []int{5}
+}
+
+type FaultAction int32
+
+const (
+	FaultAction_FAULT_NONE       FaultAction = 0
+	FaultAction_FAULT_DROP       FaultAction = 1 // the request is lost; the caller times out
+	FaultAction_FAULT_DELAY      FaultAction = 2 // the request is held for delay_ms
+	FaultAction_FAULT_DUPLICATE  FaultAction = 3 // the request is handled twice
+	FaultAction_FAULT_FAIL       FaultAction = 4 // the request fails with code without being handled
+	FaultAction_FAULT_DROP_REPLY FaultAction = 5 // the request is handled but the reply is lost
+)
+
+// Enum value maps for FaultAction.
+var (
+	FaultAction_name = map[int32]string{
+		0: "FAULT_NONE",
+		1: "FAULT_DROP",
+		2: "FAULT_DELAY",
+		3: "FAULT_DUPLICATE",
+		4: "FAULT_FAIL",
+		5: "FAULT_DROP_REPLY",
+	}
+	FaultAction_value = map[string]int32{
+		"FAULT_NONE":       0,
+		"FAULT_DROP":       1,
+		"FAULT_DELAY":      2,
+		"FAULT_DUPLICATE":  3,
+		"FAULT_FAIL":       4,
+		"FAULT_DROP_REPLY": 5,
+	}
+)
+
+func (x FaultAction) Enum() *FaultAction {
+	p := new(FaultAction)
+	*p = x
+	return p
+}
+
+func (x FaultAction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (FaultAction) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_auction_proto_enumTypes[6].Descriptor()
+}
+
+func (FaultAction) Type() protoreflect.EnumType {
+	return &file_proto_auction_proto_enumTypes[6]
+}
+
+func (x FaultAction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use FaultAction.Descriptor instead.
+func (FaultAction) EnumDescriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{6}
+}
+
+type Role int32
+
+const (
+	Role_ROLE_NONE    Role = 0
+	Role_ROLE_PRIMARY Role = 1
+	Role_ROLE_BACKUP  Role = 2
+)
+
+// Enum value maps for Role.
+var (
+	Role_name = map[int32]string{
+		0: "ROLE_NONE",
+		1: "ROLE_PRIMARY",
+		2: "ROLE_BACKUP",
+	}
+	Role_value = map[string]int32{
+		"ROLE_NONE":    0,
+		"ROLE_PRIMARY": 1,
+		"ROLE_BACKUP":  2,
+	}
+)
+
+func (x Role) Enum() *Role {
+	p := new(Role)
+	*p = x
+	return p
+}
+
+func (x Role) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Role) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_auction_proto_enumTypes[7].Descriptor()
+}
+
+func (Role) Type() protoreflect.EnumType {
+	return &file_proto_auction_proto_enumTypes[7]
+}
+
+func (x Role) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Role.Descriptor instead.
+func (Role) EnumDescriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{7}
+}
+
+// Amounts are int64 minor units (e.g. cents) of the auction currency.
+// They were int32 before currencies were introduced; both encode as the
+// same varint, so older clients keep working as long as amounts fit in
+// 32 bits. A request without a currency is taken to be in the auction's.
+type BidRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Amount        int64                  `protobuf:"varint,1,opt,name=amount,proto3" json:"amount,omitempty"`
+	ClientId      string                 `protobuf:"bytes,2,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	RequestId     string                 `protobuf:"bytes,3,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Quantity      int32                  `protobuf:"varint,4,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Currency      string                 `protobuf:"bytes,5,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BidRequest) Reset() {
+	*x = BidRequest{}
+	mi := &file_proto_auction_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BidRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BidRequest) ProtoMessage() {}
+
+func (x *BidRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BidRequest.ProtoReflect.Descriptor instead.
+func (*BidRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *BidRequest) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *BidRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *BidRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *BidRequest) GetQuantity() int32 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *BidRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+// A bidder cannot choose their own credit limit: a registered bidder gets
+// the servers' configured default until an admin sets one at approval.
+type RegisterRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClientId      string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterRequest) Reset() {
+	*x = RegisterRequest{}
+	mi := &file_proto_auction_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterRequest) ProtoMessage() {}
+
+func (x *RegisterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterRequest.ProtoReflect.Descriptor instead.
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RegisterRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+// credit_limit (or the deposit lodged) is in minor units of the auction
+// currency and caps the bidder's winning bids across all auctions. Zero
+// keeps the limit the bidder registered with.
+type ApproveRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClientId      string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	ApprovedBy    string                 `protobuf:"bytes,3,opt,name=approved_by,json=approvedBy,proto3" json:"approved_by,omitempty"`
+	CreditLimit   int64                  `protobuf:"varint,4,opt,name=credit_limit,json=creditLimit,proto3" json:"credit_limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApproveRequest) Reset() {
+	*x = ApproveRequest{}
+	mi := &file_proto_auction_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApproveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApproveRequest) ProtoMessage() {}
+
+func (x *ApproveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApproveRequest.ProtoReflect.Descriptor instead.
+func (*ApproveRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ApproveRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *ApproveRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *ApproveRequest) GetApprovedBy() string {
+	if x != nil {
+		return x.ApprovedBy
+	}
+	return ""
+}
+
+func (x *ApproveRequest) GetCreditLimit() int64 {
+	if x != nil {
+		return x.CreditLimit
+	}
+	return 0
+}
+
+type CancelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	CancelledBy   string                 `protobuf:"bytes,2,opt,name=cancelled_by,json=cancelledBy,proto3" json:"cancelled_by,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelRequest) Reset() {
+	*x = CancelRequest{}
+	mi := &file_proto_auction_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelRequest) ProtoMessage() {}
+
+func (x *CancelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelRequest.ProtoReflect.Descriptor instead.
+func (*CancelRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CancelRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *CancelRequest) GetCancelledBy() string {
+	if x != nil {
+		return x.CancelledBy
+	}
+	return ""
+}
+
+// SetFaults replaces the fault-injection rules of the node it is sent to.
+// Rules are local to the node and not replicated. With replication set,
+// they apply to the primary's calls to the backup instead of the calls the
+// node receives.
+type SetFaultsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestedBy   string                 `protobuf:"bytes,1,opt,name=requested_by,json=requestedBy,proto3" json:"requested_by,omitempty"`
+	Rules         []*FaultRule           `protobuf:"bytes,2,rep,name=rules,proto3" json:"rules,omitempty"`
+	Replication   bool                   `protobuf:"varint,3,opt,name=replication,proto3" json:"replication,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetFaultsRequest) Reset() {
+	*x = SetFaultsRequest{}
+	mi := &file_proto_auction_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetFaultsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetFaultsRequest) ProtoMessage() {}
+
+func (x *SetFaultsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetFaultsRequest.ProtoReflect.Descriptor instead.
+func (*SetFaultsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SetFaultsRequest) GetRequestedBy() string {
+	if x != nil {
+		return x.RequestedBy
+	}
+	return ""
+}
+
+func (x *SetFaultsRequest) GetRules() []*FaultRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *SetFaultsRequest) GetReplication() bool {
+	if x != nil {
+		return x.Replication
+	}
+	return false
+}
+
+// SetRateLimits replaces the rate limits of the node it is sent to. Limits
+// are local to the node and not replicated.
+type SetRateLimitsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestedBy   string                 `protobuf:"bytes,1,opt,name=requested_by,json=requestedBy,proto3" json:"requested_by,omitempty"`
+	Limits        *RateLimits            `protobuf:"bytes,2,opt,name=limits,proto3" json:"limits,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetRateLimitsRequest) Reset() {
+	*x = SetRateLimitsRequest{}
+	mi := &file_proto_auction_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetRateLimitsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetRateLimitsRequest) ProtoMessage() {}
+
+func (x *SetRateLimitsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetRateLimitsRequest.ProtoReflect.Descriptor instead.
+func (*SetRateLimitsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SetRateLimitsRequest) GetRequestedBy() string {
+	if x != nil {
+		return x.RequestedBy
+	}
+	return ""
+}
+
+func (x *SetRateLimitsRequest) GetLimits() *RateLimits {
+	if x != nil {
+		return x.Limits
+	}
+	return nil
+}
+
+// RateLimits bound the AuctionService calls a node takes: token buckets
+// refilled at a rate of calls per second and holding up to burst calls, one
+// per client ID and one per connection, and a cap on calls in progress at
+// once. A zero rate or cap leaves that limit off; a zero burst means the
+// rate rounded up.
+type RateLimits struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ClientRate      float64                `protobuf:"fixed64,1,opt,name=client_rate,json=clientRate,proto3" json:"client_rate,omitempty"`
+	ClientBurst     int32                  `protobuf:"varint,2,opt,name=client_burst,json=clientBurst,proto3" json:"client_burst,omitempty"`
+	ConnectionRate  float64                `protobuf:"fixed64,3,opt,name=connection_rate,json=connectionRate,proto3" json:"connection_rate,omitempty"`
+	ConnectionBurst int32                  `protobuf:"varint,4,opt,name=connection_burst,json=connectionBurst,proto3" json:"connection_burst,omitempty"`
+	MaxConcurrent   int32                  `protobuf:"varint,5,opt,name=max_concurrent,json=maxConcurrent,proto3" json:"max_concurrent,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *RateLimits) Reset() {
+	*x = RateLimits{}
+	mi := &file_proto_auction_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateLimits) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateLimits) ProtoMessage() {}
+
+func (x *RateLimits) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateLimits.ProtoReflect.Descriptor instead.
+func (*RateLimits) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RateLimits) GetClientRate() float64 {
+	if x != nil {
+		return x.ClientRate
+	}
+	return 0
+}
+
+func (x *RateLimits) GetClientBurst() int32 {
+	if x != nil {
+		return x.ClientBurst
+	}
+	return 0
+}
+
+func (x *RateLimits) GetConnectionRate() float64 {
+	if x != nil {
+		return x.ConnectionRate
+	}
+	return 0
+}
+
+func (x *RateLimits) GetConnectionBurst() int32 {
+	if x != nil {
+		return x.ConnectionBurst
+	}
+	return 0
+}
+
+func (x *RateLimits) GetMaxConcurrent() int32 {
+	if x != nil {
+		return x.MaxConcurrent
+	}
+	return 0
+}
+
+type FaultsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rules         []*FaultRule           `protobuf:"bytes,1,rep,name=rules,proto3" json:"rules,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FaultsResponse) Reset() {
+	*x = FaultsResponse{}
+	mi := &file_proto_auction_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FaultsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FaultsResponse) ProtoMessage() {}
+
+func (x *FaultsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FaultsResponse.ProtoReflect.Descriptor instead.
+func (*FaultsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FaultsResponse) GetRules() []*FaultRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+// FaultRule makes calls to method (the short name, e.g. "Bid", or "*" for
+// every method) misbehave with the given probability.
+type FaultRule struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Method        string                 `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"`
+	Action        FaultAction            `protobuf:"varint,2,opt,name=action,proto3,enum=auction.FaultAction" json:"action,omitempty"`
+	Probability   float64                `protobuf:"fixed64,3,opt,name=probability,proto3" json:"probability,omitempty"`
+	DelayMs       int64                  `protobuf:"varint,4,opt,name=delay_ms,json=delayMs,proto3" json:"delay_ms,omitempty"` // FAULT_DELAY
+	Code          string                 `protobuf:"bytes,5,opt,name=code,proto3" json:"code,omitempty"`                       // FAULT_FAIL: gRPC code name, default UNAVAILABLE
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FaultRule) Reset() {
+	*x = FaultRule{}
+	mi := &file_proto_auction_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FaultRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FaultRule) ProtoMessage() {}
+
+func (x *FaultRule) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FaultRule.ProtoReflect.Descriptor instead.
+func (*FaultRule) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *FaultRule) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *FaultRule) GetAction() FaultAction {
+	if x != nil {
+		return x.Action
+	}
+	return FaultAction_FAULT_NONE
+}
+
+func (x *FaultRule) GetProbability() float64 {
+	if x != nil {
+		return x.Probability
+	}
+	return 0
+}
+
+func (x *FaultRule) GetDelayMs() int64 {
+	if x != nil {
+		return x.DelayMs
+	}
+	return 0
+}
+
+func (x *FaultRule) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+type ClusterStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClusterStatusRequest) Reset() {
+	*x = ClusterStatusRequest{}
+	mi := &file_proto_auction_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClusterStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClusterStatusRequest) ProtoMessage() {}
+
+func (x *ClusterStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClusterStatusRequest.ProtoReflect.Descriptor instead.
+func (*ClusterStatusRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{9}
+}
+
+// ClusterStatus is one node's view of the cluster. On the backup
+// last_heartbeat is when it last heard from the primary, or when it started
+// if it has not yet; on the primary it is when the backup last acknowledged
+// a heartbeat, 0 if it never has. It is in unix nanoseconds.
+type ClusterStatus struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	NodeId              string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	Role                Role                   `protobuf:"varint,2,opt,name=role,proto3,enum=auction.Role" json:"role,omitempty"`
+	Epoch               uint64                 `protobuf:"varint,3,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Peers               []*Peer                `protobuf:"bytes,4,rep,name=peers,proto3" json:"peers,omitempty"`
+	LastHeartbeat       int64                  `protobuf:"varint,5,opt,name=last_heartbeat,json=lastHeartbeat,proto3" json:"last_heartbeat,omitempty"`
+	LastAppliedSequence uint64                 `protobuf:"varint,6,opt,name=last_applied_sequence,json=lastAppliedSequence,proto3" json:"last_applied_sequence,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *ClusterStatus) Reset() {
+	*x = ClusterStatus{}
+	mi := &file_proto_auction_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClusterStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClusterStatus) ProtoMessage() {}
+
+func (x *ClusterStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClusterStatus.ProtoReflect.Descriptor instead.
+func (*ClusterStatus) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ClusterStatus) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *ClusterStatus) GetRole() Role {
+	if x != nil {
+		return x.Role
+	}
+	return Role_ROLE_NONE
+}
+
+func (x *ClusterStatus) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *ClusterStatus) GetPeers() []*Peer {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
+func (x *ClusterStatus) GetLastHeartbeat() int64 {
+	if x != nil {
+		return x.LastHeartbeat
+	}
+	return 0
+}
+
+func (x *ClusterStatus) GetLastAppliedSequence() uint64 {
+	if x != nil {
+		return x.LastAppliedSequence
+	}
+	return 0
+}
+
+// Peer is another node as this one sees it. A backup that has taken over
+// reports the old primary with ROLE_NONE.
+type Peer struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Role          Role                   `protobuf:"varint,2,opt,name=role,proto3,enum=auction.Role" json:"role,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Peer) Reset() {
+	*x = Peer{}
+	mi := &file_proto_auction_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Peer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Peer) ProtoMessage() {}
+
+func (x *Peer) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Peer.ProtoReflect.Descriptor instead.
+func (*Peer) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Peer) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *Peer) GetRole() Role {
+	if x != nil {
+		return x.Role
+	}
+	return Role_ROLE_NONE
+}
+
+// TransferLeadership makes the backup primary without waiting for it to
+// detect a failure, e.g. before the primary is stopped for an upgrade.
+// Sent to the primary by an admin; leader is the new primary and epoch
+// the epoch it serves in.
+type TransferLeadershipRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestedBy   string                 `protobuf:"bytes,1,opt,name=requested_by,json=requestedBy,proto3" json:"requested_by,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferLeadershipRequest) Reset() {
+	*x = TransferLeadershipRequest{}
+	mi := &file_proto_auction_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferLeadershipRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferLeadershipRequest) ProtoMessage() {}
+
+func (x *TransferLeadershipRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferLeadershipRequest.ProtoReflect.Descriptor instead.
+func (*TransferLeadershipRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *TransferLeadershipRequest) GetRequestedBy() string {
+	if x != nil {
+		return x.RequestedBy
+	}
+	return ""
+}
+
+type TransferLeadershipResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Leader        string                 `protobuf:"bytes,1,opt,name=leader,proto3" json:"leader,omitempty"`
+	Epoch         uint64                 `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferLeadershipResponse) Reset() {
+	*x = TransferLeadershipResponse{}
+	mi := &file_proto_auction_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferLeadershipResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferLeadershipResponse) ProtoMessage() {}
+
+func (x *TransferLeadershipResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferLeadershipResponse.ProtoReflect.Descriptor instead.
+func (*TransferLeadershipResponse) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *TransferLeadershipResponse) GetLeader() string {
+	if x != nil {
+		return x.Leader
+	}
+	return ""
+}
+
+func (x *TransferLeadershipResponse) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+type BuyNowRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClientId      string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BuyNowRequest) Reset() {
+	*x = BuyNowRequest{}
+	mi := &file_proto_auction_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BuyNowRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BuyNowRequest) ProtoMessage() {}
+
+func (x *BuyNowRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BuyNowRequest.ProtoReflect.Descriptor instead.
+func (*BuyNowRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *BuyNowRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *BuyNowRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+type RetractRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClientId      string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	RequestedBy   string                 `protobuf:"bytes,3,opt,name=requested_by,json=requestedBy,proto3" json:"requested_by,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RetractRequest) Reset() {
+	*x = RetractRequest{}
+	mi := &file_proto_auction_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetractRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetractRequest) ProtoMessage() {}
+
+func (x *RetractRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetractRequest.ProtoReflect.Descriptor instead.
+func (*RetractRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *RetractRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *RetractRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *RetractRequest) GetRequestedBy() string {
+	if x != nil {
+		return x.RequestedBy
+	}
+	return ""
+}
+
+// Timestamps are unix nanoseconds assigned by the primary. Sequence numbers
+// order every replicated update, so together they settle bid disputes.
+type BidResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Outcome       Outcome                `protobuf:"varint,1,opt,name=outcome,proto3,enum=auction.Outcome" json:"outcome,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Sequence      uint64                 `protobuf:"varint,3,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Reason        RejectReason           `protobuf:"varint,5,opt,name=reason,proto3,enum=auction.RejectReason" json:"reason,omitempty"`
+	HighestBid    int64                  `protobuf:"varint,6,opt,name=highest_bid,json=highestBid,proto3" json:"highest_bid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BidResponse) Reset() {
+	*x = BidResponse{}
+	mi := &file_proto_auction_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BidResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BidResponse) ProtoMessage() {}
+
+func (x *BidResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BidResponse.ProtoReflect.Descriptor instead.
+func (*BidResponse) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *BidResponse) GetOutcome() Outcome {
+	if x != nil {
+		return x.Outcome
+	}
+	return Outcome_SUCCESS
+}
+
+func (x *BidResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *BidResponse) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *BidResponse) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *BidResponse) GetReason() RejectReason {
+	if x != nil {
+		return x.Reason
+	}
+	return RejectReason_REASON_NONE
+}
+
+func (x *BidResponse) GetHighestBid() int64 {
+	if x != nil {
+		return x.HighestBid
+	}
+	return 0
+}
+
+type ResultRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResultRequest) Reset() {
+	*x = ResultRequest{}
+	mi := &file_proto_auction_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResultRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResultRequest) ProtoMessage() {}
+
+func (x *ResultRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResultRequest.ProtoReflect.Descriptor instead.
+func (*ResultRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{17}
+}
+
+type ResultResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        AuctionStatus          `protobuf:"varint,1,opt,name=status,proto3,enum=auction.AuctionStatus" json:"status,omitempty"`
+	HighestBid    int64                  `protobuf:"varint,2,opt,name=highest_bid,json=highestBid,proto3" json:"highest_bid,omitempty"`
+	Winner        string                 `protobuf:"bytes,3,opt,name=winner,proto3" json:"winner,omitempty"`
+	Allocations   []*Allocation          `protobuf:"bytes,4,rep,name=allocations,proto3" json:"allocations,omitempty"`
+	Quantity      int32                  `protobuf:"varint,5,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Pricing       PricingRule            `protobuf:"varint,6,opt,name=pricing,proto3,enum=auction.PricingRule" json:"pricing,omitempty"`
+	BuyNowPrice   int64                  `protobuf:"varint,7,opt,name=buy_now_price,json=buyNowPrice,proto3" json:"buy_now_price,omitempty"`
+	BoughtNow     bool                   `protobuf:"varint,8,opt,name=bought_now,json=boughtNow,proto3" json:"bought_now,omitempty"`
+	Currency      string                 `protobuf:"bytes,9,opt,name=currency,proto3" json:"currency,omitempty"`
+	StartTime     int64                  `protobuf:"varint,10,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"` // unix nanoseconds
+	EndTime       int64                  `protobuf:"varint,11,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`       // unix nanoseconds
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResultResponse) Reset() {
+	*x = ResultResponse{}
+	mi := &file_proto_auction_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResultResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResultResponse) ProtoMessage() {}
+
+func (x *ResultResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResultResponse.ProtoReflect.Descriptor instead.
+func (*ResultResponse) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ResultResponse) GetStatus() AuctionStatus {
+	if x != nil {
+		return x.Status
+	}
+	return AuctionStatus_ONGOING
+}
+
+func (x *ResultResponse) GetHighestBid() int64 {
+	if x != nil {
+		return x.HighestBid
+	}
+	return 0
+}
+
+func (x *ResultResponse) GetWinner() string {
+	if x != nil {
+		return x.Winner
+	}
+	return ""
+}
+
+func (x *ResultResponse) GetAllocations() []*Allocation {
+	if x != nil {
+		return x.Allocations
+	}
+	return nil
+}
+
+func (x *ResultResponse) GetQuantity() int32 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *ResultResponse) GetPricing() PricingRule {
+	if x != nil {
+		return x.Pricing
+	}
+	return PricingRule_UNIFORM_PRICE
+}
+
+func (x *ResultResponse) GetBuyNowPrice() int64 {
+	if x != nil {
+		return x.BuyNowPrice
+	}
+	return 0
+}
+
+func (x *ResultResponse) GetBoughtNow() bool {
+	if x != nil {
+		return x.BoughtNow
+	}
+	return false
+}
+
+func (x *ResultResponse) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *ResultResponse) GetStartTime() int64 {
+	if x != nil {
+		return x.StartTime
+	}
+	return 0
+}
+
+func (x *ResultResponse) GetEndTime() int64 {
+	if x != nil {
+		return x.EndTime
+	}
+	return 0
 }
 
-type BidRequest struct {
+type Allocation struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Amount        int32                  `protobuf:"varint,1,opt,name=amount,proto3" json:"amount,omitempty"`
-	ClientId      string                 `protobuf:"bytes,2,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
-	RequestId     string                 `protobuf:"bytes,3,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Bidder        string                 `protobuf:"bytes,1,opt,name=bidder,proto3" json:"bidder,omitempty"`
+	Quantity      int32                  `protobuf:"varint,2,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	BidPrice      int64                  `protobuf:"varint,3,opt,name=bid_price,json=bidPrice,proto3" json:"bid_price,omitempty"`
+	Price         int64                  `protobuf:"varint,4,opt,name=price,proto3" json:"price,omitempty"`
+	Sequence      uint64                 `protobuf:"varint,5,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *BidRequest) Reset() {
-	*x = BidRequest{}
-	mi := &file_proto_auction_proto_msgTypes[0]
+func (x *Allocation) Reset() {
+	*x = Allocation{}
+	mi := &file_proto_auction_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BidRequest) String() string {
+func (x *Allocation) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BidRequest) ProtoMessage() {}
+func (*Allocation) ProtoMessage() {}
 
-func (x *BidRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_auction_proto_msgTypes[0]
+func (x *Allocation) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -193,55 +1747,74 @@ func (x *BidRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BidRequest.ProtoReflect.Descriptor instead.
-func (*BidRequest) Descriptor() ([]byte, []int) {
-	return file_proto_auction_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use Allocation.ProtoReflect.Descriptor instead.
+func (*Allocation) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{19}
 }
 
-func (x *BidRequest) GetAmount() int32 {
+func (x *Allocation) GetBidder() string {
 	if x != nil {
-		return x.Amount
+		return x.Bidder
+	}
+	return ""
+}
+
+func (x *Allocation) GetQuantity() int32 {
+	if x != nil {
+		return x.Quantity
 	}
 	return 0
 }
 
-func (x *BidRequest) GetClientId() string {
+func (x *Allocation) GetBidPrice() int64 {
 	if x != nil {
-		return x.ClientId
+		return x.BidPrice
 	}
-	return ""
+	return 0
 }
 
-func (x *BidRequest) GetRequestId() string {
+func (x *Allocation) GetPrice() int64 {
 	if x != nil {
-		return x.RequestId
+		return x.Price
 	}
-	return ""
+	return 0
 }
 
-type BidResponse struct {
+func (x *Allocation) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Allocation) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type HistoryRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Outcome       Outcome                `protobuf:"varint,1,opt,name=outcome,proto3,enum=auction.Outcome" json:"outcome,omitempty"`
-	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *BidResponse) Reset() {
-	*x = BidResponse{}
-	mi := &file_proto_auction_proto_msgTypes[1]
+func (x *HistoryRequest) Reset() {
+	*x = HistoryRequest{}
+	mi := &file_proto_auction_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BidResponse) String() string {
+func (x *HistoryRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BidResponse) ProtoMessage() {}
+func (*HistoryRequest) ProtoMessage() {}
 
-func (x *BidResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_auction_proto_msgTypes[1]
+func (x *HistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -252,46 +1825,33 @@ func (x *BidResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BidResponse.ProtoReflect.Descriptor instead.
-func (*BidResponse) Descriptor() ([]byte, []int) {
-	return file_proto_auction_proto_rawDescGZIP(), []int{1}
-}
-
-func (x *BidResponse) GetOutcome() Outcome {
-	if x != nil {
-		return x.Outcome
-	}
-	return Outcome_SUCCESS
-}
-
-func (x *BidResponse) GetMessage() string {
-	if x != nil {
-		return x.Message
-	}
-	return ""
+// Deprecated: Use HistoryRequest.ProtoReflect.Descriptor instead.
+func (*HistoryRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{20}
 }
 
-type ResultRequest struct {
+type HistoryResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bids          []*BidRecord           `protobuf:"bytes,1,rep,name=bids,proto3" json:"bids,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ResultRequest) Reset() {
-	*x = ResultRequest{}
-	mi := &file_proto_auction_proto_msgTypes[2]
+func (x *HistoryResponse) Reset() {
+	*x = HistoryResponse{}
+	mi := &file_proto_auction_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ResultRequest) String() string {
+func (x *HistoryResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ResultRequest) ProtoMessage() {}
+func (*HistoryResponse) ProtoMessage() {}
 
-func (x *ResultRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_auction_proto_msgTypes[2]
+func (x *HistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -302,35 +1862,46 @@ func (x *ResultRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ResultRequest.ProtoReflect.Descriptor instead.
-func (*ResultRequest) Descriptor() ([]byte, []int) {
-	return file_proto_auction_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use HistoryResponse.ProtoReflect.Descriptor instead.
+func (*HistoryResponse) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{21}
 }
 
-type ResultResponse struct {
+func (x *HistoryResponse) GetBids() []*BidRecord {
+	if x != nil {
+		return x.Bids
+	}
+	return nil
+}
+
+type BidRecord struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Status        AuctionStatus          `protobuf:"varint,1,opt,name=status,proto3,enum=auction.AuctionStatus" json:"status,omitempty"`
-	HighestBid    int32                  `protobuf:"varint,2,opt,name=highest_bid,json=highestBid,proto3" json:"highest_bid,omitempty"`
-	Winner        string                 `protobuf:"bytes,3,opt,name=winner,proto3" json:"winner,omitempty"`
+	Sequence      uint64                 `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Bidder        string                 `protobuf:"bytes,3,opt,name=bidder,proto3" json:"bidder,omitempty"`
+	Amount        int64                  `protobuf:"varint,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	Quantity      int32                  `protobuf:"varint,5,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Retracted     bool                   `protobuf:"varint,6,opt,name=retracted,proto3" json:"retracted,omitempty"`
+	BuyNow        bool                   `protobuf:"varint,7,opt,name=buy_now,json=buyNow,proto3" json:"buy_now,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ResultResponse) Reset() {
-	*x = ResultResponse{}
-	mi := &file_proto_auction_proto_msgTypes[3]
+func (x *BidRecord) Reset() {
+	*x = BidRecord{}
+	mi := &file_proto_auction_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ResultResponse) String() string {
+func (x *BidRecord) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ResultResponse) ProtoMessage() {}
+func (*BidRecord) ProtoMessage() {}
 
-func (x *ResultResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_auction_proto_msgTypes[3]
+func (x *BidRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -341,46 +1912,83 @@ func (x *ResultResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ResultResponse.ProtoReflect.Descriptor instead.
-func (*ResultResponse) Descriptor() ([]byte, []int) {
-	return file_proto_auction_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use BidRecord.ProtoReflect.Descriptor instead.
+func (*BidRecord) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{22}
 }
 
-func (x *ResultResponse) GetStatus() AuctionStatus {
+func (x *BidRecord) GetSequence() uint64 {
 	if x != nil {
-		return x.Status
+		return x.Sequence
 	}
-	return AuctionStatus_ONGOING
+	return 0
 }
 
-func (x *ResultResponse) GetHighestBid() int32 {
+func (x *BidRecord) GetTimestamp() int64 {
 	if x != nil {
-		return x.HighestBid
+		return x.Timestamp
 	}
 	return 0
 }
 
-func (x *ResultResponse) GetWinner() string {
+func (x *BidRecord) GetBidder() string {
 	if x != nil {
-		return x.Winner
+		return x.Bidder
 	}
 	return ""
 }
 
+func (x *BidRecord) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *BidRecord) GetQuantity() int32 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *BidRecord) GetRetracted() bool {
+	if x != nil {
+		return x.Retracted
+	}
+	return false
+}
+
+func (x *BidRecord) GetBuyNow() bool {
+	if x != nil {
+		return x.BuyNow
+	}
+	return false
+}
+
 type UpdateRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
-	Type          UpdateType             `protobuf:"varint,2,opt,name=type,proto3,enum=auction.UpdateType" json:"type,omitempty"`
-	Amount        int32                  `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
-	ClientId      string                 `protobuf:"bytes,4,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
-	Outcome       Outcome                `protobuf:"varint,5,opt,name=outcome,proto3,enum=auction.Outcome" json:"outcome,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	RequestId string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Type      UpdateType             `protobuf:"varint,2,opt,name=type,proto3,enum=auction.UpdateType" json:"type,omitempty"`
+	Amount    int64                  `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	ClientId  string                 `protobuf:"bytes,4,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Outcome   Outcome                `protobuf:"varint,5,opt,name=outcome,proto3,enum=auction.Outcome" json:"outcome,omitempty"`
+	Quantity  int32                  `protobuf:"varint,6,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Currency  string                 `protobuf:"bytes,7,opt,name=currency,proto3" json:"currency,omitempty"`
+	ByAdmin   bool                   `protobuf:"varint,8,opt,name=by_admin,json=byAdmin,proto3" json:"by_admin,omitempty"`
+	Sequence  uint64                 `protobuf:"varint,9,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Timestamp int64                  `protobuf:"varint,10,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Reason    RejectReason           `protobuf:"varint,11,opt,name=reason,proto3,enum=auction.RejectReason" json:"reason,omitempty"`
+	// The epoch the sending primary serves in. A backup refuses updates from
+	// an older epoch, and every update once it has taken over itself.
+	Epoch         uint64 `protobuf:"varint,12,opt,name=epoch,proto3" json:"epoch,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *UpdateRequest) Reset() {
 	*x = UpdateRequest{}
-	mi := &file_proto_auction_proto_msgTypes[4]
+	mi := &file_proto_auction_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -392,7 +2000,7 @@ func (x *UpdateRequest) String() string {
 func (*UpdateRequest) ProtoMessage() {}
 
 func (x *UpdateRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_auction_proto_msgTypes[4]
+	mi := &file_proto_auction_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -405,7 +2013,7 @@ func (x *UpdateRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateRequest.ProtoReflect.Descriptor instead.
 func (*UpdateRequest) Descriptor() ([]byte, []int) {
-	return file_proto_auction_proto_rawDescGZIP(), []int{4}
+	return file_proto_auction_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *UpdateRequest) GetRequestId() string {
@@ -422,7 +2030,7 @@ func (x *UpdateRequest) GetType() UpdateType {
 	return UpdateType_BID
 }
 
-func (x *UpdateRequest) GetAmount() int32 {
+func (x *UpdateRequest) GetAmount() int64 {
 	if x != nil {
 		return x.Amount
 	}
@@ -443,6 +2051,55 @@ func (x *UpdateRequest) GetOutcome() Outcome {
 	return Outcome_SUCCESS
 }
 
+func (x *UpdateRequest) GetQuantity() int32 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *UpdateRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *UpdateRequest) GetByAdmin() bool {
+	if x != nil {
+		return x.ByAdmin
+	}
+	return false
+}
+
+func (x *UpdateRequest) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *UpdateRequest) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *UpdateRequest) GetReason() RejectReason {
+	if x != nil {
+		return x.Reason
+	}
+	return RejectReason_REASON_NONE
+}
+
+func (x *UpdateRequest) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
 type UpdateResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Acknowledged  bool                   `protobuf:"varint,1,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
@@ -452,7 +2109,7 @@ type UpdateResponse struct {
 
 func (x *UpdateResponse) Reset() {
 	*x = UpdateResponse{}
-	mi := &file_proto_auction_proto_msgTypes[5]
+	mi := &file_proto_auction_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -464,7 +2121,7 @@ func (x *UpdateResponse) String() string {
 func (*UpdateResponse) ProtoMessage() {}
 
 func (x *UpdateResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_auction_proto_msgTypes[5]
+	mi := &file_proto_auction_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -477,7 +2134,7 @@ func (x *UpdateResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateResponse.ProtoReflect.Descriptor instead.
 func (*UpdateResponse) Descriptor() ([]byte, []int) {
-	return file_proto_auction_proto_rawDescGZIP(), []int{5}
+	return file_proto_auction_proto_rawDescGZIP(), []int{24}
 }
 
 func (x *UpdateResponse) GetAcknowledged() bool {
@@ -487,15 +2144,21 @@ func (x *UpdateResponse) GetAcknowledged() bool {
 	return false
 }
 
+// HeartbeatRequest carries the sender's epoch, fenced like an update's, and
+// the last sequence the backup acknowledged to it. A primary that does not
+// know of every update the backup applied has lost updates, e.g. by
+// restarting, and its heartbeats are refused.
 type HeartbeatRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	Epoch         uint64                 `protobuf:"varint,1,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Sequence      uint64                 `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *HeartbeatRequest) Reset() {
 	*x = HeartbeatRequest{}
-	mi := &file_proto_auction_proto_msgTypes[6]
+	mi := &file_proto_auction_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -507,7 +2170,7 @@ func (x *HeartbeatRequest) String() string {
 func (*HeartbeatRequest) ProtoMessage() {}
 
 func (x *HeartbeatRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_auction_proto_msgTypes[6]
+	mi := &file_proto_auction_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -520,7 +2183,21 @@ func (x *HeartbeatRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HeartbeatRequest.ProtoReflect.Descriptor instead.
 func (*HeartbeatRequest) Descriptor() ([]byte, []int) {
-	return file_proto_auction_proto_rawDescGZIP(), []int{6}
+	return file_proto_auction_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *HeartbeatRequest) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *HeartbeatRequest) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
 }
 
 type HeartbeatResponse struct {
@@ -532,7 +2209,7 @@ type HeartbeatResponse struct {
 
 func (x *HeartbeatResponse) Reset() {
 	*x = HeartbeatResponse{}
-	mi := &file_proto_auction_proto_msgTypes[7]
+	mi := &file_proto_auction_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -544,7 +2221,7 @@ func (x *HeartbeatResponse) String() string {
 func (*HeartbeatResponse) ProtoMessage() {}
 
 func (x *HeartbeatResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_auction_proto_msgTypes[7]
+	mi := &file_proto_auction_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -557,7 +2234,7 @@ func (x *HeartbeatResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HeartbeatResponse.ProtoReflect.Descriptor instead.
 func (*HeartbeatResponse) Descriptor() ([]byte, []int) {
-	return file_proto_auction_proto_rawDescGZIP(), []int{7}
+	return file_proto_auction_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *HeartbeatResponse) GetAlive() bool {
@@ -567,55 +2244,328 @@ func (x *HeartbeatResponse) GetAlive() bool {
 	return false
 }
 
+// TakeOver asks the backup to become primary during a planned handover.
+// sequence is the last one the primary assigned; the backup refuses with
+// FAILED_PRECONDITION until it has applied every update up to it.
+type TakeOverRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sequence      uint64                 `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TakeOverRequest) Reset() {
+	*x = TakeOverRequest{}
+	mi := &file_proto_auction_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TakeOverRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TakeOverRequest) ProtoMessage() {}
+
+func (x *TakeOverRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TakeOverRequest.ProtoReflect.Descriptor instead.
+func (*TakeOverRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *TakeOverRequest) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+type TakeOverResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Epoch         uint64                 `protobuf:"varint,1,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TakeOverResponse) Reset() {
+	*x = TakeOverResponse{}
+	mi := &file_proto_auction_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TakeOverResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TakeOverResponse) ProtoMessage() {}
+
+func (x *TakeOverResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auction_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TakeOverResponse.ProtoReflect.Descriptor instead.
+func (*TakeOverResponse) Descriptor() ([]byte, []int) {
+	return file_proto_auction_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *TakeOverResponse) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
 var File_proto_auction_proto protoreflect.FileDescriptor
 
 const file_proto_auction_proto_rawDesc = "" +
 	"\n" +
-	"\x13proto/auction.proto\x12\aauction\"`\n" +
+	"\x13proto/auction.proto\x12\aauction\"\x98\x01\n" +
 	"\n" +
 	"BidRequest\x12\x16\n" +
-	"\x06amount\x18\x01 \x01(\x05R\x06amount\x12\x1b\n" +
+	"\x06amount\x18\x01 \x01(\x03R\x06amount\x12\x1b\n" +
 	"\tclient_id\x18\x02 \x01(\tR\bclientId\x12\x1d\n" +
 	"\n" +
-	"request_id\x18\x03 \x01(\tR\trequestId\"S\n" +
+	"request_id\x18\x03 \x01(\tR\trequestId\x12\x1a\n" +
+	"\bquantity\x18\x04 \x01(\x05R\bquantity\x12\x1a\n" +
+	"\bcurrency\x18\x05 \x01(\tR\bcurrency\"a\n" +
+	"\x0fRegisterRequest\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x02 \x01(\tR\trequestIdJ\x04\b\x03\x10\x04R\fcredit_limit\"\x90\x01\n" +
+	"\x0eApproveRequest\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x02 \x01(\tR\trequestId\x12\x1f\n" +
+	"\vapproved_by\x18\x03 \x01(\tR\n" +
+	"approvedBy\x12!\n" +
+	"\fcredit_limit\x18\x04 \x01(\x03R\vcreditLimit\"Q\n" +
+	"\rCancelRequest\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12!\n" +
+	"\fcancelled_by\x18\x02 \x01(\tR\vcancelledBy\"\x81\x01\n" +
+	"\x10SetFaultsRequest\x12!\n" +
+	"\frequested_by\x18\x01 \x01(\tR\vrequestedBy\x12(\n" +
+	"\x05rules\x18\x02 \x03(\v2\x12.auction.FaultRuleR\x05rules\x12 \n" +
+	"\vreplication\x18\x03 \x01(\bR\vreplication\"f\n" +
+	"\x14SetRateLimitsRequest\x12!\n" +
+	"\frequested_by\x18\x01 \x01(\tR\vrequestedBy\x12+\n" +
+	"\x06limits\x18\x02 \x01(\v2\x13.auction.RateLimitsR\x06limits\"\xcb\x01\n" +
+	"\n" +
+	"RateLimits\x12\x1f\n" +
+	"\vclient_rate\x18\x01 \x01(\x01R\n" +
+	"clientRate\x12!\n" +
+	"\fclient_burst\x18\x02 \x01(\x05R\vclientBurst\x12'\n" +
+	"\x0fconnection_rate\x18\x03 \x01(\x01R\x0econnectionRate\x12)\n" +
+	"\x10connection_burst\x18\x04 \x01(\x05R\x0fconnectionBurst\x12%\n" +
+	"\x0emax_concurrent\x18\x05 \x01(\x05R\rmaxConcurrent\":\n" +
+	"\x0eFaultsResponse\x12(\n" +
+	"\x05rules\x18\x01 \x03(\v2\x12.auction.FaultRuleR\x05rules\"\xa2\x01\n" +
+	"\tFaultRule\x12\x16\n" +
+	"\x06method\x18\x01 \x01(\tR\x06method\x12,\n" +
+	"\x06action\x18\x02 \x01(\x0e2\x14.auction.FaultActionR\x06action\x12 \n" +
+	"\vprobability\x18\x03 \x01(\x01R\vprobability\x12\x19\n" +
+	"\bdelay_ms\x18\x04 \x01(\x03R\adelayMs\x12\x12\n" +
+	"\x04code\x18\x05 \x01(\tR\x04code\"\x16\n" +
+	"\x14ClusterStatusRequest\"\xe1\x01\n" +
+	"\rClusterStatus\x12\x17\n" +
+	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12!\n" +
+	"\x04role\x18\x02 \x01(\x0e2\r.auction.RoleR\x04role\x12\x14\n" +
+	"\x05epoch\x18\x03 \x01(\x04R\x05epoch\x12#\n" +
+	"\x05peers\x18\x04 \x03(\v2\r.auction.PeerR\x05peers\x12%\n" +
+	"\x0elast_heartbeat\x18\x05 \x01(\x03R\rlastHeartbeat\x122\n" +
+	"\x15last_applied_sequence\x18\x06 \x01(\x04R\x13lastAppliedSequence\"C\n" +
+	"\x04Peer\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12!\n" +
+	"\x04role\x18\x02 \x01(\x0e2\r.auction.RoleR\x04role\">\n" +
+	"\x19TransferLeadershipRequest\x12!\n" +
+	"\frequested_by\x18\x01 \x01(\tR\vrequestedBy\"J\n" +
+	"\x1aTransferLeadershipResponse\x12\x16\n" +
+	"\x06leader\x18\x01 \x01(\tR\x06leader\x12\x14\n" +
+	"\x05epoch\x18\x02 \x01(\x04R\x05epoch\"K\n" +
+	"\rBuyNowRequest\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x02 \x01(\tR\trequestId\"o\n" +
+	"\x0eRetractRequest\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x02 \x01(\tR\trequestId\x12!\n" +
+	"\frequested_by\x18\x03 \x01(\tR\vrequestedBy\"\xdd\x01\n" +
 	"\vBidResponse\x12*\n" +
 	"\aoutcome\x18\x01 \x01(\x0e2\x10.auction.OutcomeR\aoutcome\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"\x0f\n" +
-	"\rResultRequest\"y\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1a\n" +
+	"\bsequence\x18\x03 \x01(\x04R\bsequence\x12\x1c\n" +
+	"\ttimestamp\x18\x04 \x01(\x03R\ttimestamp\x12-\n" +
+	"\x06reason\x18\x05 \x01(\x0e2\x15.auction.RejectReasonR\x06reason\x12\x1f\n" +
+	"\vhighest_bid\x18\x06 \x01(\x03R\n" +
+	"highestBid\"\x0f\n" +
+	"\rResultRequest\"\x95\x03\n" +
 	"\x0eResultResponse\x12.\n" +
 	"\x06status\x18\x01 \x01(\x0e2\x16.auction.AuctionStatusR\x06status\x12\x1f\n" +
-	"\vhighest_bid\x18\x02 \x01(\x05R\n" +
+	"\vhighest_bid\x18\x02 \x01(\x03R\n" +
 	"highestBid\x12\x16\n" +
-	"\x06winner\x18\x03 \x01(\tR\x06winner\"\xb8\x01\n" +
+	"\x06winner\x18\x03 \x01(\tR\x06winner\x125\n" +
+	"\vallocations\x18\x04 \x03(\v2\x13.auction.AllocationR\vallocations\x12\x1a\n" +
+	"\bquantity\x18\x05 \x01(\x05R\bquantity\x12.\n" +
+	"\apricing\x18\x06 \x01(\x0e2\x14.auction.PricingRuleR\apricing\x12\"\n" +
+	"\rbuy_now_price\x18\a \x01(\x03R\vbuyNowPrice\x12\x1d\n" +
+	"\n" +
+	"bought_now\x18\b \x01(\bR\tboughtNow\x12\x1a\n" +
+	"\bcurrency\x18\t \x01(\tR\bcurrency\x12\x1d\n" +
+	"\n" +
+	"start_time\x18\n" +
+	" \x01(\x03R\tstartTime\x12\x19\n" +
+	"\bend_time\x18\v \x01(\x03R\aendTime\"\xad\x01\n" +
+	"\n" +
+	"Allocation\x12\x16\n" +
+	"\x06bidder\x18\x01 \x01(\tR\x06bidder\x12\x1a\n" +
+	"\bquantity\x18\x02 \x01(\x05R\bquantity\x12\x1b\n" +
+	"\tbid_price\x18\x03 \x01(\x03R\bbidPrice\x12\x14\n" +
+	"\x05price\x18\x04 \x01(\x03R\x05price\x12\x1a\n" +
+	"\bsequence\x18\x05 \x01(\x04R\bsequence\x12\x1c\n" +
+	"\ttimestamp\x18\x06 \x01(\x03R\ttimestamp\"\x10\n" +
+	"\x0eHistoryRequest\"9\n" +
+	"\x0fHistoryResponse\x12&\n" +
+	"\x04bids\x18\x01 \x03(\v2\x12.auction.BidRecordR\x04bids\"\xc8\x01\n" +
+	"\tBidRecord\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x04R\bsequence\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x12\x16\n" +
+	"\x06bidder\x18\x03 \x01(\tR\x06bidder\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x03R\x06amount\x12\x1a\n" +
+	"\bquantity\x18\x05 \x01(\x05R\bquantity\x12\x1c\n" +
+	"\tretracted\x18\x06 \x01(\bR\tretracted\x12\x17\n" +
+	"\abuy_now\x18\a \x01(\bR\x06buyNow\"\x8a\x03\n" +
 	"\rUpdateRequest\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x01 \x01(\tR\trequestId\x12'\n" +
 	"\x04type\x18\x02 \x01(\x0e2\x13.auction.UpdateTypeR\x04type\x12\x16\n" +
-	"\x06amount\x18\x03 \x01(\x05R\x06amount\x12\x1b\n" +
+	"\x06amount\x18\x03 \x01(\x03R\x06amount\x12\x1b\n" +
 	"\tclient_id\x18\x04 \x01(\tR\bclientId\x12*\n" +
-	"\aoutcome\x18\x05 \x01(\x0e2\x10.auction.OutcomeR\aoutcome\"4\n" +
+	"\aoutcome\x18\x05 \x01(\x0e2\x10.auction.OutcomeR\aoutcome\x12\x1a\n" +
+	"\bquantity\x18\x06 \x01(\x05R\bquantity\x12\x1a\n" +
+	"\bcurrency\x18\a \x01(\tR\bcurrency\x12\x19\n" +
+	"\bby_admin\x18\b \x01(\bR\abyAdmin\x12\x1a\n" +
+	"\bsequence\x18\t \x01(\x04R\bsequence\x12\x1c\n" +
+	"\ttimestamp\x18\n" +
+	" \x01(\x03R\ttimestamp\x12-\n" +
+	"\x06reason\x18\v \x01(\x0e2\x15.auction.RejectReasonR\x06reason\x12\x14\n" +
+	"\x05epoch\x18\f \x01(\x04R\x05epoch\"4\n" +
 	"\x0eUpdateResponse\x12\"\n" +
-	"\facknowledged\x18\x01 \x01(\bR\facknowledged\"\x12\n" +
-	"\x10HeartbeatRequest\")\n" +
+	"\facknowledged\x18\x01 \x01(\bR\facknowledged\"D\n" +
+	"\x10HeartbeatRequest\x12\x14\n" +
+	"\x05epoch\x18\x01 \x01(\x04R\x05epoch\x12\x1a\n" +
+	"\bsequence\x18\x02 \x01(\x04R\bsequence\")\n" +
 	"\x11HeartbeatResponse\x12\x14\n" +
-	"\x05alive\x18\x01 \x01(\bR\x05alive*/\n" +
+	"\x05alive\x18\x01 \x01(\bR\x05alive\"-\n" +
+	"\x0fTakeOverRequest\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x04R\bsequence\"(\n" +
+	"\x10TakeOverResponse\x12\x14\n" +
+	"\x05epoch\x18\x01 \x01(\x04R\x05epoch*/\n" +
 	"\aOutcome\x12\v\n" +
 	"\aSUCCESS\x10\x00\x12\b\n" +
 	"\x04FAIL\x10\x01\x12\r\n" +
-	"\tEXCEPTION\x10\x02*(\n" +
+	"\tEXCEPTION\x10\x02*\xd3\x03\n" +
+	"\fRejectReason\x12\x0f\n" +
+	"\vREASON_NONE\x10\x00\x12\x11\n" +
+	"\rBELOW_HIGHEST\x10\x01\x12\x16\n" +
+	"\x12BELOW_OWN_PREVIOUS\x10\x02\x12\x12\n" +
+	"\x0eAUCTION_CLOSED\x10\x03\x12\x17\n" +
+	"\x13AUCTION_NOT_STARTED\x10\x04\x12\x12\n" +
+	"\x0eINVALID_AMOUNT\x10\x05\x12\x14\n" +
+	"\x10INVALID_QUANTITY\x10\x06\x12\x15\n" +
+	"\x11CURRENCY_MISMATCH\x10\a\x12\x13\n" +
+	"\vNOT_PRIMARY\x10\b\x1a\x02\b\x01\x12\x1a\n" +
+	"\x12REPLICATION_FAILED\x10\t\x1a\x02\b\x01\x12\x12\n" +
+	"\x0eNOT_REGISTERED\x10\n" +
+	"\x12\x10\n" +
+	"\fNOT_APPROVED\x10\v\x12\x19\n" +
+	"\x15CREDIT_LIMIT_EXCEEDED\x10\f\x12\x16\n" +
+	"\x12ALREADY_REGISTERED\x10\r\x12\x18\n" +
+	"\x14INVALID_REGISTRATION\x10\x0e\x12\x17\n" +
+	"\x13BUY_NOW_UNAVAILABLE\x10\x0f\x12\x15\n" +
+	"\x11NO_BID_TO_RETRACT\x10\x10\x12\x1a\n" +
+	"\x16RETRACTION_NOT_ALLOWED\x10\x11\x12\x12\n" +
+	"\x0eNOT_AUTHORIZED\x10\x12\x12\x15\n" +
+	"\x11AUCTION_CANCELLED\x10\x13*F\n" +
 	"\rAuctionStatus\x12\v\n" +
 	"\aONGOING\x10\x00\x12\n" +
 	"\n" +
-	"\x06CLOSED\x10\x01*\x15\n" +
+	"\x06CLOSED\x10\x01\x12\r\n" +
+	"\tSCHEDULED\x10\x02\x12\r\n" +
+	"\tCANCELLED\x10\x03*0\n" +
+	"\vPricingRule\x12\x11\n" +
+	"\rUNIFORM_PRICE\x10\x00\x12\x0e\n" +
+	"\n" +
+	"PAY_AS_BID\x10\x01*0\n" +
+	"\tTiePolicy\x12\x10\n" +
+	"\fREJECT_EQUAL\x10\x00\x12\x11\n" +
+	"\rEARLIEST_WINS\x10\x01*w\n" +
 	"\n" +
 	"UpdateType\x12\a\n" +
-	"\x03BID\x10\x002}\n" +
+	"\x03BID\x10\x00\x12\v\n" +
+	"\aBUY_NOW\x10\x01\x12\v\n" +
+	"\aRETRACT\x10\x02\x12\f\n" +
+	"\bREGISTER\x10\x03\x12\v\n" +
+	"\aAPPROVE\x10\x04\x12\b\n" +
+	"\x04OPEN\x10\x05\x12\t\n" +
+	"\x05CLOSE\x10\x06\x12\n" +
+	"\n" +
+	"\x06CANCEL\x10\a\x12\n" +
+	"\n" +
+	"\x06SETTLE\x10\b*y\n" +
+	"\vFaultAction\x12\x0e\n" +
+	"\n" +
+	"FAULT_NONE\x10\x00\x12\x0e\n" +
+	"\n" +
+	"FAULT_DROP\x10\x01\x12\x0f\n" +
+	"\vFAULT_DELAY\x10\x02\x12\x13\n" +
+	"\x0fFAULT_DUPLICATE\x10\x03\x12\x0e\n" +
+	"\n" +
+	"FAULT_FAIL\x10\x04\x12\x14\n" +
+	"\x10FAULT_DROP_REPLY\x10\x05*8\n" +
+	"\x04Role\x12\r\n" +
+	"\tROLE_NONE\x10\x00\x12\x10\n" +
+	"\fROLE_PRIMARY\x10\x01\x12\x0f\n" +
+	"\vROLE_BACKUP\x10\x022\xec\x02\n" +
 	"\x0eAuctionService\x120\n" +
 	"\x03Bid\x12\x13.auction.BidRequest\x1a\x14.auction.BidResponse\x129\n" +
-	"\x06Result\x12\x16.auction.ResultRequest\x1a\x17.auction.ResultResponse2\x9c\x01\n" +
+	"\x06Result\x12\x16.auction.ResultRequest\x1a\x17.auction.ResultResponse\x126\n" +
+	"\x06BuyNow\x12\x16.auction.BuyNowRequest\x1a\x14.auction.BidResponse\x12;\n" +
+	"\n" +
+	"RetractBid\x12\x17.auction.RetractRequest\x1a\x14.auction.BidResponse\x12<\n" +
+	"\aHistory\x12\x17.auction.HistoryRequest\x1a\x18.auction.HistoryResponse\x12:\n" +
+	"\bRegister\x12\x18.auction.RegisterRequest\x1a\x14.auction.BidResponse2\xbd\x03\n" +
+	"\fAdminService\x12>\n" +
+	"\rApproveBidder\x12\x17.auction.ApproveRequest\x1a\x14.auction.BidResponse\x12=\n" +
+	"\rCancelAuction\x12\x16.auction.CancelRequest\x1a\x14.auction.BidResponse\x12?\n" +
+	"\tSetFaults\x12\x19.auction.SetFaultsRequest\x1a\x17.auction.FaultsResponse\x12I\n" +
+	"\x10GetClusterStatus\x12\x1d.auction.ClusterStatusRequest\x1a\x16.auction.ClusterStatus\x12]\n" +
+	"\x12TransferLeadership\x12\".auction.TransferLeadershipRequest\x1a#.auction.TransferLeadershipResponse\x12C\n" +
+	"\rSetRateLimits\x12\x1d.auction.SetRateLimitsRequest\x1a\x13.auction.RateLimits2\xdd\x01\n" +
 	"\x12ReplicationService\x12B\n" +
 	"\x0fReplicateUpdate\x12\x16.auction.UpdateRequest\x1a\x17.auction.UpdateResponse\x12B\n" +
-	"\tHeartbeat\x12\x19.auction.HeartbeatRequest\x1a\x1a.auction.HeartbeatResponseB4Z2github.com/joachimblom-hanssen/Distributed_5/protob\x06proto3"
+	"\tHeartbeat\x12\x19.auction.HeartbeatRequest\x1a\x1a.auction.HeartbeatResponse\x12?\n" +
+	"\bTakeOver\x12\x18.auction.TakeOverRequest\x1a\x19.auction.TakeOverResponseB4Z2github.com/joachimblom-hanssen/Distributed_5/protob\x06proto3"
 
 var (
 	file_proto_auction_proto_rawDescOnce sync.Once
@@ -629,39 +2579,99 @@ func file_proto_auction_proto_rawDescGZIP() []byte {
 	return file_proto_auction_proto_rawDescData
 }
 
-var file_proto_auction_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
-var file_proto_auction_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_proto_auction_proto_enumTypes = make([]protoimpl.EnumInfo, 8)
+var file_proto_auction_proto_msgTypes = make([]protoimpl.MessageInfo, 29)
 var file_proto_auction_proto_goTypes = []any{
-	(Outcome)(0),              // 0: auction.Outcome
-	(AuctionStatus)(0),        // 1: auction.AuctionStatus
-	(UpdateType)(0),           // 2: auction.UpdateType
-	(*BidRequest)(nil),        // 3: auction.BidRequest
-	(*BidResponse)(nil),       // 4: auction.BidResponse
-	(*ResultRequest)(nil),     // 5: auction.ResultRequest
-	(*ResultResponse)(nil),    // 6: auction.ResultResponse
-	(*UpdateRequest)(nil),     // 7: auction.UpdateRequest
-	(*UpdateResponse)(nil),    // 8: auction.UpdateResponse
-	(*HeartbeatRequest)(nil),  // 9: auction.HeartbeatRequest
-	(*HeartbeatResponse)(nil), // 10: auction.HeartbeatResponse
+	(Outcome)(0),                       // 0: auction.Outcome
+	(RejectReason)(0),                  // 1: auction.RejectReason
+	(AuctionStatus)(0),                 // 2: auction.AuctionStatus
+	(PricingRule)(0),                   // 3: auction.PricingRule
+	(TiePolicy)(0),                     // 4: auction.TiePolicy
+	(UpdateType)(0),                    // 5: auction.UpdateType
+	(FaultAction)(0),                   // 6: auction.FaultAction
+	(Role)(0),                          // 7: auction.Role
+	(*BidRequest)(nil),                 // 8: auction.BidRequest
+	(*RegisterRequest)(nil),            // 9: auction.RegisterRequest
+	(*ApproveRequest)(nil),             // 10: auction.ApproveRequest
+	(*CancelRequest)(nil),              // 11: auction.CancelRequest
+	(*SetFaultsRequest)(nil),           // 12: auction.SetFaultsRequest
+	(*SetRateLimitsRequest)(nil),       // 13: auction.SetRateLimitsRequest
+	(*RateLimits)(nil),                 // 14: auction.RateLimits
+	(*FaultsResponse)(nil),             // 15: auction.FaultsResponse
+	(*FaultRule)(nil),                  // 16: auction.FaultRule
+	(*ClusterStatusRequest)(nil),       // 17: auction.ClusterStatusRequest
+	(*ClusterStatus)(nil),              // 18: auction.ClusterStatus
+	(*Peer)(nil),                       // 19: auction.Peer
+	(*TransferLeadershipRequest)(nil),  // 20: auction.TransferLeadershipRequest
+	(*TransferLeadershipResponse)(nil), // 21: auction.TransferLeadershipResponse
+	(*BuyNowRequest)(nil),              // 22: auction.BuyNowRequest
+	(*RetractRequest)(nil),             // 23: auction.RetractRequest
+	(*BidResponse)(nil),                // 24: auction.BidResponse
+	(*ResultRequest)(nil),              // 25: auction.ResultRequest
+	(*ResultResponse)(nil),             // 26: auction.ResultResponse
+	(*Allocation)(nil),                 // 27: auction.Allocation
+	(*HistoryRequest)(nil),             // 28: auction.HistoryRequest
+	(*HistoryResponse)(nil),            // 29: auction.HistoryResponse
+	(*BidRecord)(nil),                  // 30: auction.BidRecord
+	(*UpdateRequest)(nil),              // 31: auction.UpdateRequest
+	(*UpdateResponse)(nil),             // 32: auction.UpdateResponse
+	(*HeartbeatRequest)(nil),           // 33: auction.HeartbeatRequest
+	(*HeartbeatResponse)(nil),          // 34: auction.HeartbeatResponse
+	(*TakeOverRequest)(nil),            // 35: auction.TakeOverRequest
+	(*TakeOverResponse)(nil),           // 36: auction.TakeOverResponse
 }
 var file_proto_auction_proto_depIdxs = []int32{
-	0,  // 0: auction.BidResponse.outcome:type_name -> auction.Outcome
-	1,  // 1: auction.ResultResponse.status:type_name -> auction.AuctionStatus
-	2,  // 2: auction.UpdateRequest.type:type_name -> auction.UpdateType
-	0,  // 3: auction.UpdateRequest.outcome:type_name -> auction.Outcome
-	3,  // 4: auction.AuctionService.Bid:input_type -> auction.BidRequest
-	5,  // 5: auction.AuctionService.Result:input_type -> auction.ResultRequest
-	7,  // 6: auction.ReplicationService.ReplicateUpdate:input_type -> auction.UpdateRequest
-	9,  // 7: auction.ReplicationService.Heartbeat:input_type -> auction.HeartbeatRequest
-	4,  // 8: auction.AuctionService.Bid:output_type -> auction.BidResponse
-	6,  // 9: auction.AuctionService.Result:output_type -> auction.ResultResponse
-	8,  // 10: auction.ReplicationService.ReplicateUpdate:output_type -> auction.UpdateResponse
-	10, // 11: auction.ReplicationService.Heartbeat:output_type -> auction.HeartbeatResponse
-	8,  // [8:12] is the sub-list for method output_type
-	4,  // [4:8] is the sub-list for method input_type
-	4,  // [4:4] is the sub-list for extension type_name
-	4,  // [4:4] is the sub-list for extension extendee
-	0,  // [0:4] is the sub-list for field type_name
+	16, // 0: auction.SetFaultsRequest.rules:type_name -> auction.FaultRule
+	14, // 1: auction.SetRateLimitsRequest.limits:type_name -> auction.RateLimits
+	16, // 2: auction.FaultsResponse.rules:type_name -> auction.FaultRule
+	6,  // 3: auction.FaultRule.action:type_name -> auction.FaultAction
+	7,  // 4: auction.ClusterStatus.role:type_name -> auction.Role
+	19, // 5: auction.ClusterStatus.peers:type_name -> auction.Peer
+	7,  // 6: auction.Peer.role:type_name -> auction.Role
+	0,  // 7: auction.BidResponse.outcome:type_name -> auction.Outcome
+	1,  // 8: auction.BidResponse.reason:type_name -> auction.RejectReason
+	2,  // 9: auction.ResultResponse.status:type_name -> auction.AuctionStatus
+	27, // 10: auction.ResultResponse.allocations:type_name -> auction.Allocation
+	3,  // 11: auction.ResultResponse.pricing:type_name -> auction.PricingRule
+	30, // 12: auction.HistoryResponse.bids:type_name -> auction.BidRecord
+	5,  // 13: auction.UpdateRequest.type:type_name -> auction.UpdateType
+	0,  // 14: auction.UpdateRequest.outcome:type_name -> auction.Outcome
+	1,  // 15: auction.UpdateRequest.reason:type_name -> auction.RejectReason
+	8,  // 16: auction.AuctionService.Bid:input_type -> auction.BidRequest
+	25, // 17: auction.AuctionService.Result:input_type -> auction.ResultRequest
+	22, // 18: auction.AuctionService.BuyNow:input_type -> auction.BuyNowRequest
+	23, // 19: auction.AuctionService.RetractBid:input_type -> auction.RetractRequest
+	28, // 20: auction.AuctionService.History:input_type -> auction.HistoryRequest
+	9,  // 21: auction.AuctionService.Register:input_type -> auction.RegisterRequest
+	10, // 22: auction.AdminService.ApproveBidder:input_type -> auction.ApproveRequest
+	11, // 23: auction.AdminService.CancelAuction:input_type -> auction.CancelRequest
+	12, // 24: auction.AdminService.SetFaults:input_type -> auction.SetFaultsRequest
+	17, // 25: auction.AdminService.GetClusterStatus:input_type -> auction.ClusterStatusRequest
+	20, // 26: auction.AdminService.TransferLeadership:input_type -> auction.TransferLeadershipRequest
+	13, // 27: auction.AdminService.SetRateLimits:input_type -> auction.SetRateLimitsRequest
+	31, // 28: auction.ReplicationService.ReplicateUpdate:input_type -> auction.UpdateRequest
+	33, // 29: auction.ReplicationService.Heartbeat:input_type -> auction.HeartbeatRequest
+	35, // 30: auction.ReplicationService.TakeOver:input_type -> auction.TakeOverRequest
+	24, // 31: auction.AuctionService.Bid:output_type -> auction.BidResponse
+	26, // 32: auction.AuctionService.Result:output_type -> auction.ResultResponse
+	24, // 33: auction.AuctionService.BuyNow:output_type -> auction.BidResponse
+	24, // 34: auction.AuctionService.RetractBid:output_type -> auction.BidResponse
+	29, // 35: auction.AuctionService.History:output_type -> auction.HistoryResponse
+	24, // 36: auction.AuctionService.Register:output_type -> auction.BidResponse
+	24, // 37: auction.AdminService.ApproveBidder:output_type -> auction.BidResponse
+	24, // 38: auction.AdminService.CancelAuction:output_type -> auction.BidResponse
+	15, // 39: auction.AdminService.SetFaults:output_type -> auction.FaultsResponse
+	18, // 40: auction.AdminService.GetClusterStatus:output_type -> auction.ClusterStatus
+	21, // 41: auction.AdminService.TransferLeadership:output_type -> auction.TransferLeadershipResponse
+	14, // 42: auction.AdminService.SetRateLimits:output_type -> auction.RateLimits
+	32, // 43: auction.ReplicationService.ReplicateUpdate:output_type -> auction.UpdateResponse
+	34, // 44: auction.ReplicationService.Heartbeat:output_type -> auction.HeartbeatResponse
+	36, // 45: auction.ReplicationService.TakeOver:output_type -> auction.TakeOverResponse
+	31, // [31:46] is the sub-list for method output_type
+	16, // [16:31] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
 }
 
 func init() { file_proto_auction_proto_init() }
@@ -674,10 +2684,10 @@ func file_proto_auction_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_auction_proto_rawDesc), len(file_proto_auction_proto_rawDesc)),
-			NumEnums:      3,
-			NumMessages:   8,
+			NumEnums:      8,
+			NumMessages:   29,
 			NumExtensions: 0,
-			NumServices:   2,
+			NumServices:   3,
 		},
 		GoTypes:           file_proto_auction_proto_goTypes,
 		DependencyIndexes: file_proto_auction_proto_depIdxs,
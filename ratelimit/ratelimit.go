@@ -0,0 +1,286 @@
+// Package ratelimit keeps clients that flood a server from starving the
+// others. Every AuctionService call must get a token from the bucket of
+// its client ID and from that of its connection, and a slot under a cap
+// on calls in progress; a call over a limit is refused at once with
+// ResourceExhausted and a RetryInfo saying when to retry, instead of
+// queueing for the server's lock.
+//
+// The limits can be changed while the server runs, from a flag or through
+// AdminService.SetRateLimits. Replication, admin calls and health checks
+// are never limited.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/auth"
+	"github.com/joachimblom-hanssen/Distributed_5/clock"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// sweepInterval is how often buckets that are full again, and so no
+// different from new ones, are dropped.
+const sweepInterval = time.Minute
+
+// Limiter holds the limits and the buckets of one server. It is safe for
+// concurrent use.
+type Limiter struct {
+	mutex       sync.Mutex
+	limits      *pb.RateLimits
+	clients     map[string]*rate.Limiter
+	connections map[string]*rate.Limiter
+	inFlight    int32
+	lastSweep   time.Time
+	clock       clock.Clock
+}
+
+// New returns a limiter enforcing limits, refilling buckets by clk;
+// nil means clock.Real.
+func New(limits *pb.RateLimits, clk clock.Clock) (*Limiter, error) {
+	if clk == nil {
+		clk = clock.Real
+	}
+	l := &Limiter{
+		limits:      &pb.RateLimits{},
+		clients:     make(map[string]*rate.Limiter),
+		connections: make(map[string]*rate.Limiter),
+		lastSweep:   clk.Now(),
+		clock:       clk,
+	}
+	if err := l.Set(limits); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// FromFlags returns a limiter enforcing the limits in spec. An empty spec
+// sets no limits until they are changed at runtime.
+func FromFlags(spec string) (*Limiter, error) {
+	limits, err := ParseLimits(spec)
+	if err != nil {
+		return nil, err
+	}
+	return New(limits, clock.Real)
+}
+
+// Set replaces the limits. Buckets already in use keep their tokens and
+// refill at the new rate.
+func (l *Limiter) Set(limits *pb.RateLimits) error {
+	if limits == nil {
+		limits = &pb.RateLimits{}
+	}
+	if limits.ClientRate < 0 || limits.ClientBurst < 0 || limits.ConnectionRate < 0 || limits.ConnectionBurst < 0 || limits.MaxConcurrent < 0 {
+		return fmt.Errorf("rate limits must not be negative")
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.limits = proto.Clone(limits).(*pb.RateLimits)
+	for _, bucket := range l.clients {
+		bucket.SetLimit(rate.Limit(limits.ClientRate))
+		bucket.SetBurst(burst(limits.ClientRate, limits.ClientBurst))
+	}
+	for _, bucket := range l.connections {
+		bucket.SetLimit(rate.Limit(limits.ConnectionRate))
+		bucket.SetBurst(burst(limits.ConnectionRate, limits.ConnectionBurst))
+	}
+	return nil
+}
+
+// Limits returns a copy of the current limits.
+func (l *Limiter) Limits() *pb.RateLimits {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return proto.Clone(l.limits).(*pb.RateLimits)
+}
+
+// burst is the size of a bucket: burst if set, otherwise the rate rounded
+// up.
+func burst(rate float64, burst int32) int {
+	if burst > 0 {
+		return int(burst)
+	}
+	return int(math.Max(1, math.Ceil(rate)))
+}
+
+// admit takes a token from the buckets of client, if known, and of
+// connection, and a slot of the concurrency cap, or returns the error to
+// refuse the call with. release gives the slot back.
+func (l *Limiter) admit(client, connection string) (release func(), err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	if l.limits.MaxConcurrent > 0 && l.inFlight >= l.limits.MaxConcurrent {
+		return nil, auction.RateLimitedError("concurrency", auction.RetryDelay)
+	}
+
+	var taken []*rate.Reservation
+	take := func(buckets map[string]*rate.Limiter, key string, limit float64, size int32, name string) error {
+		if key == "" || limit == 0 {
+			return nil
+		}
+		bucket, exists := buckets[key]
+		if !exists {
+			bucket = rate.NewLimiter(rate.Limit(limit), burst(limit, size))
+			buckets[key] = bucket
+		}
+		reservation := bucket.ReserveN(now, 1)
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			reservation.CancelAt(now)
+			return auction.RateLimitedError(name, delay)
+		}
+		taken = append(taken, reservation)
+		return nil
+	}
+	if err := take(l.clients, client, l.limits.ClientRate, l.limits.ClientBurst, "client"); err != nil {
+		return nil, err
+	}
+	if err := take(l.connections, connection, l.limits.ConnectionRate, l.limits.ConnectionBurst, "connection"); err != nil {
+		// The call is refused, so it does not count against its client
+		for _, reservation := range taken {
+			reservation.CancelAt(now)
+		}
+		return nil, err
+	}
+
+	l.inFlight++
+	return func() {
+		l.mutex.Lock()
+		l.inFlight--
+		l.mutex.Unlock()
+	}, nil
+}
+
+// sweep drops buckets that have filled up again. Must be called with
+// l.mutex held.
+func (l *Limiter) sweep(now time.Time) {
+	for _, buckets := range []map[string]*rate.Limiter{l.clients, l.connections} {
+		for key, bucket := range buckets {
+			if bucket.TokensAt(now) >= float64(bucket.Burst()) {
+				delete(buckets, key)
+			}
+		}
+	}
+	l.lastSweep = now
+}
+
+// ServerOptions apply l, if non-nil, to the AuctionService calls a gRPC
+// server receives. The client is the authenticated caller if there is one
+// and the request's client ID otherwise; calls naming no client, such as
+// Result, are only limited per connection. They should come after
+// auth.ServerOptions.
+func ServerOptions(l *Limiter) []grpc.ServerOption {
+	if l == nil {
+		return nil
+	}
+	return []grpc.ServerOption{grpc.ChainUnaryInterceptor(l.limit)}
+}
+
+func (l *Limiter) limit(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !strings.HasPrefix(info.FullMethod, "/"+pb.AuctionService_ServiceDesc.ServiceName+"/") {
+		return handler(ctx, req)
+	}
+
+	client, authenticated := auth.Subject(ctx)
+	if r, ok := req.(interface{ GetClientId() string }); ok && !authenticated {
+		client = r.GetClientId()
+	}
+	connection := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		connection = p.Addr.String()
+	}
+
+	release, err := l.admit(client, connection)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return handler(ctx, req)
+}
+
+// ParseLimits parses limits such as "client=5:10,connection=50,concurrent=32":
+// a token bucket per client ID and per connection as rate[:burst], with
+// the rate in calls per second, and the most calls in progress at once.
+// Limits left out are off; an empty spec or "none" sets none.
+func ParseLimits(spec string) (*pb.RateLimits, error) {
+	limits := &pb.RateLimits{}
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "none" {
+		return limits, nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			return nil, fmt.Errorf("rate limit %q: want name=value", part)
+		}
+		var err error
+		switch name {
+		case "client":
+			limits.ClientRate, limits.ClientBurst, err = parseBucket(value)
+		case "connection":
+			limits.ConnectionRate, limits.ConnectionBurst, err = parseBucket(value)
+		case "concurrent":
+			var n int64
+			n, err = strconv.ParseInt(value, 10, 32)
+			limits.MaxConcurrent = int32(n)
+		default:
+			return nil, fmt.Errorf("unknown rate limit %q (want client, connection or concurrent)", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rate limit %q: %v", part, err)
+		}
+	}
+	return limits, nil
+}
+
+func parseBucket(value string) (float64, int32, error) {
+	rateText, burstText, hasBurst := strings.Cut(value, ":")
+	r, err := strconv.ParseFloat(rateText, 64)
+	if err != nil || r < 0 {
+		return 0, 0, fmt.Errorf("bad rate %q", rateText)
+	}
+	if !hasBurst {
+		return r, 0, nil
+	}
+	b, err := strconv.ParseInt(burstText, 10, 32)
+	if err != nil || b < 0 {
+		return 0, 0, fmt.Errorf("bad burst %q", burstText)
+	}
+	return r, int32(b), nil
+}
+
+// FormatLimits renders limits as ParseLimits reads them.
+func FormatLimits(limits *pb.RateLimits) string {
+	var parts []string
+	if limits.GetClientRate() > 0 {
+		parts = append(parts, fmt.Sprintf("client=%s:%d", strconv.FormatFloat(limits.ClientRate, 'g', -1, 64), burst(limits.ClientRate, limits.ClientBurst)))
+	}
+	if limits.GetConnectionRate() > 0 {
+		parts = append(parts, fmt.Sprintf("connection=%s:%d", strconv.FormatFloat(limits.ConnectionRate, 'g', -1, 64), burst(limits.ConnectionRate, limits.ConnectionBurst)))
+	}
+	if limits.GetMaxConcurrent() > 0 {
+		parts = append(parts, fmt.Sprintf("concurrent=%d", limits.MaxConcurrent))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,231 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/auth"
+	"github.com/joachimblom-hanssen/Distributed_5/clock"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newLimiter(t *testing.T, spec string) (*Limiter, *clock.Fake) {
+	t.Helper()
+	limits, err := ParseLimits(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFake(start)
+	l, err := New(limits, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, clk
+}
+
+// mustAdmit admits a call and gives its slot back at once.
+func mustAdmit(t *testing.T, l *Limiter, client, connection string) {
+	t.Helper()
+	release, err := l.admit(client, connection)
+	if err != nil {
+		t.Fatalf("%s on %s refused: %v", client, connection, err)
+	}
+	release()
+}
+
+// mustRefuse fails unless a call is refused for limit, to be retried after
+// retryAfter.
+func mustRefuse(t *testing.T, l *Limiter, client, connection, limit string, retryAfter time.Duration) {
+	t.Helper()
+	release, err := l.admit(client, connection)
+	if err == nil {
+		release()
+		t.Fatalf("%s on %s admitted, want refused by the %s limit", client, connection, limit)
+	}
+	if status.Code(err) != codes.ResourceExhausted || limitOf(err) != limit {
+		t.Fatalf("%s on %s: %v, want refused by the %s limit", client, connection, err, limit)
+	}
+	if delay, ok := auction.RetryAfter(err); !ok || delay != retryAfter {
+		t.Errorf("%s on %s: retry after %v, want %v", client, connection, delay, retryAfter)
+	}
+}
+
+// limitOf is the limit a RateLimitedError names.
+func limitOf(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Reason == auction.ReasonRateLimited {
+			return info.Metadata["limit"]
+		}
+	}
+	return ""
+}
+
+func TestPerClientLimit(t *testing.T) {
+	l, clk := newLimiter(t, "client=2:3")
+
+	for i := 0; i < 3; i++ {
+		mustAdmit(t, l, "alice", "conn-1")
+	}
+	mustRefuse(t, l, "alice", "conn-2", "client", 500*time.Millisecond)
+	mustAdmit(t, l, "bob", "conn-1")
+
+	// Tokens come back at the client rate
+	clk.Advance(500 * time.Millisecond)
+	mustAdmit(t, l, "alice", "conn-1")
+	mustRefuse(t, l, "alice", "conn-1", "client", 500*time.Millisecond)
+
+	// Calls that name no client are not limited per client
+	for i := 0; i < 10; i++ {
+		mustAdmit(t, l, "", "conn-1")
+	}
+}
+
+func TestPerConnectionLimit(t *testing.T) {
+	l, clk := newLimiter(t, "client=1:1,connection=1:2")
+
+	mustAdmit(t, l, "alice", "conn-1")
+	mustAdmit(t, l, "", "conn-1")
+	mustRefuse(t, l, "bob", "conn-1", "connection", time.Second)
+	mustAdmit(t, l, "", "conn-2")
+
+	// bob's refused call did not take his only client token
+	mustAdmit(t, l, "bob", "conn-2")
+
+	clk.Advance(time.Second)
+	mustAdmit(t, l, "", "conn-1")
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	l, _ := newLimiter(t, "concurrent=2")
+
+	first, err := l.admit("alice", "conn-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := l.admit("bob", "conn-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustRefuse(t, l, "carol", "conn-3", "concurrency", auction.RetryDelay)
+
+	first()
+	third, err := l.admit("carol", "conn-3")
+	if err != nil {
+		t.Fatalf("refused after a call finished: %v", err)
+	}
+	second()
+	third()
+}
+
+func TestSetChangesLimitsOfBucketsInUse(t *testing.T) {
+	l, clk := newLimiter(t, "client=1:1")
+
+	mustAdmit(t, l, "alice", "conn-1")
+	mustRefuse(t, l, "alice", "conn-1", "client", time.Second)
+
+	if err := l.Set(&pb.RateLimits{ClientRate: 10, ClientBurst: 1}); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(100 * time.Millisecond)
+	mustAdmit(t, l, "alice", "conn-1")
+
+	if err := l.Set(&pb.RateLimits{ClientRate: -1}); err == nil {
+		t.Error("Set accepted a negative rate")
+	}
+	if err := l.Set(nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		mustAdmit(t, l, "alice", "conn-1")
+	}
+}
+
+func TestSweepDropsFullBuckets(t *testing.T) {
+	l, clk := newLimiter(t, "client=1:1,connection=1:1")
+	mustAdmit(t, l, "alice", "conn-1")
+
+	clk.Advance(sweepInterval)
+	mustAdmit(t, l, "bob", "conn-2")
+	if _, ok := l.clients["alice"]; ok {
+		t.Error("alice's full bucket was kept")
+	}
+	if _, ok := l.connections["conn-2"]; !ok {
+		t.Error("the bucket of the call that triggered the sweep was dropped")
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	l, _ := newLimiter(t, "client=1:1")
+	bid := &grpc.UnaryServerInfo{FullMethod: pb.AuctionService_Bid_FullMethodName}
+	call := func(ctx context.Context, info *grpc.UnaryServerInfo, req interface{}) codes.Code {
+		_, err := l.limit(ctx, req, info, func(context.Context, interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return status.Code(err)
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4000}})
+
+	if code := call(ctx, bid, &pb.BidRequest{ClientId: "alice"}); code != codes.OK {
+		t.Fatalf("first bid: %v", code)
+	}
+	if code := call(ctx, bid, &pb.BidRequest{ClientId: "alice"}); code != codes.ResourceExhausted {
+		t.Errorf("second bid by the same client ID: %v, want ResourceExhausted", code)
+	}
+
+	// An authenticated caller is limited as themselves, whatever the
+	// request names
+	if code := call(auth.WithSubject(ctx, "bob"), bid, &pb.BidRequest{ClientId: "alice"}); code != codes.OK {
+		t.Errorf("bid authenticated as bob: %v", code)
+	}
+	if code := call(auth.WithSubject(ctx, "bob"), bid, &pb.BidRequest{ClientId: "carol"}); code != codes.ResourceExhausted {
+		t.Errorf("second bid authenticated as bob: %v, want ResourceExhausted", code)
+	}
+
+	for _, method := range []string{
+		pb.ReplicationService_ReplicateUpdate_FullMethodName,
+		pb.AdminService_SetRateLimits_FullMethodName,
+		"/grpc.health.v1.Health/Check",
+	} {
+		for i := 0; i < 3; i++ {
+			if code := call(auth.WithSubject(ctx, "alice"), &grpc.UnaryServerInfo{FullMethod: method}, nil); code != codes.OK {
+				t.Errorf("%s: %v, want it never limited", method, code)
+			}
+		}
+	}
+}
+
+func TestParseAndFormatLimits(t *testing.T) {
+	tests := []struct {
+		spec, formatted string
+	}{
+		{"", "none"},
+		{"none", "none"},
+		{"client=5:10,connection=50,concurrent=32", "client=5:10,connection=50:50,concurrent=32"},
+		{"client=0.5", "client=0.5:1"},
+	}
+	for _, test := range tests {
+		limits, err := ParseLimits(test.spec)
+		if err != nil {
+			t.Fatalf("ParseLimits(%q): %v", test.spec, err)
+		}
+		if got := FormatLimits(limits); got != test.formatted {
+			t.Errorf("FormatLimits(ParseLimits(%q)) = %q, want %q", test.spec, got, test.formatted)
+		}
+	}
+
+	for _, spec := range []string{"client", "client=fast", "client=1:-1", "connection=-1", "concurrent=many", "global=1"} {
+		if _, err := ParseLimits(spec); err == nil {
+			t.Errorf("ParseLimits(%q) succeeded, want an error", spec)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package rbac decides which authenticated callers may call which methods
+// of AuctionService and AdminService, by the roles a policy file gives
+// them.
+//
+// A role is a set of permissions, each the short name of a method, such as
+// "Bid", or "*" for all of them. RetractAny, "RetractBid:any", also lets a
+// role retract other bidders' bids. The built-in roles are bidder,
+// auctioneer and operator; a policy file can redefine them or add more:
+//
+//	{
+//	  "members": {"alice": ["bidder"], "carol": ["auctioneer"], "ops": ["operator"]},
+//	  "default_roles": ["bidder"]
+//	}
+//
+// Callers are identified by their token (see package auth), so a policy
+// needs authentication on.
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auth"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Permissions. Each is the short name of the method it allows, except
+// RetractAny, the permission to retract other bidders' bids.
+const (
+	Bid                = "Bid"
+	BuyNow             = "BuyNow"
+	RetractBid         = "RetractBid"
+	RetractAny         = "RetractBid:any"
+	Register           = "Register"
+	Result             = "Result"
+	History            = "History"
+	ApproveBidder      = "ApproveBidder"
+	CancelAuction      = "CancelAuction"
+	GetClusterStatus   = "GetClusterStatus"
+	SetFaults          = "SetFaults"
+	SetRateLimits      = "SetRateLimits"
+	TransferLeadership = "TransferLeadership"
+)
+
+// Built-in role names.
+const (
+	Bidder     = "bidder"
+	Auctioneer = "auctioneer"
+	Operator   = "operator"
+)
+
+// DefaultRoles are the permissions of the built-in roles.
+var DefaultRoles = map[string][]string{
+	Bidder:     {Bid, BuyNow, RetractBid, Register, Result, History},
+	Auctioneer: {ApproveBidder, CancelAuction, RetractBid, RetractAny, Result, History, GetClusterStatus},
+	Operator:   {SetFaults, SetRateLimits, TransferLeadership, GetClusterStatus, Result, History},
+}
+
+// Policy gives callers roles. It is not changed after loading, so it is
+// safe for concurrent use.
+type Policy struct {
+	// Roles maps each role to its permissions; roles left out of a policy
+	// file keep their DefaultRoles permissions.
+	Roles map[string][]string `json:"roles"`
+	// Members maps each client ID to its roles.
+	Members map[string][]string `json:"members"`
+	// DefaultRoles are the roles of callers not listed in Members.
+	DefaultRoles []string `json:"default_roles"`
+}
+
+// FromFlags loads the policy file at path, or returns nil if path is
+// empty, which leaves authorization to the servers' admin lists.
+func FromFlags(path string) (*Policy, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	roles := make(map[string][]string)
+	for role, permissions := range DefaultRoles {
+		roles[role] = permissions
+	}
+	for role, permissions := range p.Roles {
+		roles[role] = permissions
+	}
+	p.Roles = roles
+
+	for member, memberRoles := range p.Members {
+		for _, role := range memberRoles {
+			if _, ok := p.Roles[role]; !ok {
+				return nil, fmt.Errorf("%s: %q has unknown role %q", path, member, role)
+			}
+		}
+	}
+	for _, role := range p.DefaultRoles {
+		if _, ok := p.Roles[role]; !ok {
+			return nil, fmt.Errorf("%s: unknown default role %q", path, role)
+		}
+	}
+	return p, nil
+}
+
+// RolesOf returns the roles of clientID.
+func (p *Policy) RolesOf(clientID string) []string {
+	if roles, ok := p.Members[clientID]; ok {
+		return roles
+	}
+	return p.DefaultRoles
+}
+
+// Allows reports whether one of clientID's roles grants permission.
+func (p *Policy) Allows(clientID, permission string) bool {
+	for _, role := range p.RolesOf(clientID) {
+		for _, granted := range p.Roles[role] {
+			if granted == permission || granted == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ServerOptions refuse calls to AuctionService and AdminService that p,
+// if non-nil, does not allow the caller, with PermissionDenied. They must
+// come after auth.ServerOptions, which authenticate the caller.
+func ServerOptions(p *Policy) []grpc.ServerOption {
+	if p == nil {
+		return nil
+	}
+	return []grpc.ServerOption{grpc.ChainUnaryInterceptor(p.authorize)}
+}
+
+func (p *Policy) authorize(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	service, method, _ := strings.Cut(strings.TrimPrefix(info.FullMethod, "/"), "/")
+	if service != pb.AuctionService_ServiceDesc.ServiceName && service != pb.AdminService_ServiceDesc.ServiceName {
+		return handler(ctx, req)
+	}
+
+	subject, ok := auth.Subject(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization needs an authenticated caller")
+	}
+	if !p.Allows(subject, method) {
+		return nil, status.Errorf(codes.PermissionDenied, "%q may not call %s", subject, method)
+	}
+	if retract, ok := req.(*pb.RetractRequest); ok && retract.ClientId != subject && !p.Allows(subject, RetractAny) {
+		return nil, status.Errorf(codes.PermissionDenied, "%q may not retract other bidders' bids", subject)
+	}
+	return handler(ctx, req)
+}
@@ -0,0 +1,127 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auth"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultPolicy gives each built-in role one member and nobody a default
+// role.
+var defaultPolicy = &Policy{
+	Roles: DefaultRoles,
+	Members: map[string][]string{
+		"alice": {Bidder},
+		"carol": {Auctioneer},
+		"ops":   {Operator},
+	},
+}
+
+// call runs a call to method by subject, or by an unauthenticated caller
+// if subject is empty, through the policy's interceptor and returns its
+// status code.
+func call(p *Policy, subject, method string, req interface{}) codes.Code {
+	ctx := context.Background()
+	if subject != "" {
+		ctx = auth.WithSubject(ctx, subject)
+	}
+	_, err := p.authorize(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	return status.Code(err)
+}
+
+func TestDefaultRoles(t *testing.T) {
+	// Who may call each method under DefaultRoles
+	allowed := map[string][]string{
+		pb.AuctionService_Bid_FullMethodName:              {"alice"},
+		pb.AuctionService_BuyNow_FullMethodName:           {"alice"},
+		pb.AuctionService_RetractBid_FullMethodName:       {"alice", "carol"},
+		pb.AuctionService_Register_FullMethodName:         {"alice"},
+		pb.AuctionService_Result_FullMethodName:           {"alice", "carol", "ops"},
+		pb.AuctionService_History_FullMethodName:          {"alice", "carol", "ops"},
+		pb.AdminService_ApproveBidder_FullMethodName:      {"carol"},
+		pb.AdminService_CancelAuction_FullMethodName:      {"carol"},
+		pb.AdminService_GetClusterStatus_FullMethodName:   {"carol", "ops"},
+		pb.AdminService_SetFaults_FullMethodName:          {"ops"},
+		pb.AdminService_SetRateLimits_FullMethodName:      {"ops"},
+		pb.AdminService_TransferLeadership_FullMethodName: {"ops"},
+	}
+
+	// Every method of both services must be in the table, so a new one
+	// cannot go untested
+	for _, service := range []grpc.ServiceDesc{pb.AuctionService_ServiceDesc, pb.AdminService_ServiceDesc} {
+		for _, method := range service.Methods {
+			if _, ok := allowed["/"+service.ServiceName+"/"+method.MethodName]; !ok {
+				t.Errorf("%s.%s is not covered", service.ServiceName, method.MethodName)
+			}
+		}
+	}
+
+	for method, callers := range allowed {
+		for _, caller := range []string{"alice", "carol", "ops", "mallory"} {
+			want := codes.PermissionDenied
+			for _, allowedCaller := range callers {
+				if caller == allowedCaller {
+					want = codes.OK
+				}
+			}
+			// A retraction of the caller's own bid
+			if got := call(defaultPolicy, caller, method, &pb.RetractRequest{ClientId: caller}); got != want {
+				t.Errorf("%s calling %s: %v, want %v", caller, method, got, want)
+			}
+		}
+	}
+}
+
+func TestRetractAny(t *testing.T) {
+	tests := []struct {
+		caller string
+		want   codes.Code
+	}{
+		{"alice", codes.PermissionDenied},
+		{"carol", codes.OK},
+		{"ops", codes.PermissionDenied},
+	}
+	for _, test := range tests {
+		req := &pb.RetractRequest{ClientId: "bob", RequestedBy: test.caller}
+		if got := call(defaultPolicy, test.caller, pb.AuctionService_RetractBid_FullMethodName, req); got != test.want {
+			t.Errorf("%s retracting bob's bid: %v, want %v", test.caller, got, test.want)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	wildcard := &Policy{
+		Roles:        map[string][]string{"admin": {"*"}, Bidder: DefaultRoles[Bidder]},
+		Members:      map[string][]string{"root": {"admin"}},
+		DefaultRoles: []string{Bidder},
+	}
+
+	tests := []struct {
+		name    string
+		policy  *Policy
+		subject string
+		method  string
+		want    codes.Code
+	}{
+		{"unauthenticated", defaultPolicy, "", pb.AuctionService_Result_FullMethodName, codes.Unauthenticated},
+		{"replication is not authorized here", defaultPolicy, "", pb.ReplicationService_Heartbeat_FullMethodName, codes.OK},
+		{"health checks are open", defaultPolicy, "", "/grpc.health.v1.Health/Check", codes.OK},
+		{"wildcard", wildcard, "root", pb.AdminService_SetFaults_FullMethodName, codes.OK},
+		{"default role", wildcard, "anyone", pb.AuctionService_Bid_FullMethodName, codes.OK},
+		{"default role denied", wildcard, "anyone", pb.AdminService_CancelAuction_FullMethodName, codes.PermissionDenied},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := call(test.policy, test.subject, test.method, nil); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
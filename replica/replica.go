@@ -0,0 +1,400 @@
+// Package replica holds the state the primary and the backup both keep:
+// the auction, the bidder registry and the response to every request
+// executed, with the sequence and timestamp of the last update.
+//
+// Every write is turned into an UpdateRequest before it runs. The primary
+// executes it, deciding its outcome and stamp, and replicates it; the
+// backup applies the same update through the same code, so both replicas
+// end up in the same state.
+package replica
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/clock"
+	"github.com/joachimblom-hanssen/Distributed_5/metrics"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"github.com/joachimblom-hanssen/Distributed_5/rbac"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Options configures a State.
+type Options struct {
+	StartTime time.Time
+	Config    auction.Config
+	Registry  *auction.Registry // nil means no registration
+	Admins    []string
+	// Policy, if set, decides who may use admin permissions in place of
+	// Admins.
+	Policy *rbac.Policy
+	// Clock defaults to the wall clock.
+	Clock clock.Clock
+	// Metrics records the bids executed; nil records nothing.
+	Metrics *metrics.Metrics
+}
+
+// State is the replicated state of one node. It is not safe for
+// concurrent use; servers guard it with their lock.
+type State struct {
+	auction   *auction.Auction
+	registry  *auction.Registry
+	processed map[string]*pb.BidResponse
+	admins    map[string]bool
+	policy    *rbac.Policy
+	clock     clock.Clock
+	metrics   *metrics.Metrics
+
+	sequence      uint64
+	lastTimestamp time.Time
+}
+
+func New(opts Options) *State {
+	if opts.Clock == nil {
+		opts.Clock = clock.Real
+	}
+	if opts.Registry == nil {
+		opts.Registry = auction.NewRegistry(false, false, 0)
+	}
+
+	s := &State{
+		auction:   auction.NewAuction(opts.StartTime, opts.Config),
+		registry:  opts.Registry,
+		processed: make(map[string]*pb.BidResponse),
+		admins:    make(map[string]bool),
+		policy:    opts.Policy,
+		clock:     opts.Clock,
+		metrics:   opts.Metrics,
+	}
+	for _, admin := range opts.Admins {
+		s.admins[admin] = true
+	}
+	return s
+}
+
+// Auction returns the auction the state holds.
+func (s *State) Auction() *auction.Auction {
+	return s.auction
+}
+
+// Sequence is the sequence number of the last update executed or applied.
+// Updates are only applied in order, so a backup has applied every update
+// up to it and none after it: there are no gaps behind it.
+func (s *State) Sequence() uint64 {
+	return s.sequence
+}
+
+// IsAdmin reports whether clientID holds the admin permission, the name
+// of a method or rbac.RetractAny: as the policy says if there is one,
+// otherwise if it is one of the admins. It only reads what was configured,
+// so it needs no lock.
+func (s *State) IsAdmin(clientID, permission string) bool {
+	if s.policy != nil {
+		return s.policy.Allows(clientID, permission)
+	}
+	return s.admins[clientID]
+}
+
+// BidUpdate is the update for a bid.
+func (s *State) BidUpdate(req *pb.BidRequest) *pb.UpdateRequest {
+	return &pb.UpdateRequest{
+		RequestId: req.RequestId,
+		Type:      pb.UpdateType_BID,
+		Amount:    req.Amount,
+		ClientId:  req.ClientId,
+		Quantity:  req.Quantity,
+		Currency:  req.Currency,
+	}
+}
+
+// BuyNowUpdate is the update for a buy-now. Its amount is the price, set
+// when it is executed.
+func (s *State) BuyNowUpdate(req *pb.BuyNowRequest) *pb.UpdateRequest {
+	return &pb.UpdateRequest{
+		RequestId: req.RequestId,
+		Type:      pb.UpdateType_BUY_NOW,
+		ClientId:  req.ClientId,
+	}
+}
+
+// RetractUpdate is the update for a retraction. Bidders may only retract
+// their own bids; admins may retract anyone's.
+func (s *State) RetractUpdate(req *pb.RetractRequest) *pb.UpdateRequest {
+	requestedBy := req.RequestedBy
+	if requestedBy == "" {
+		requestedBy = req.ClientId
+	}
+	update := &pb.UpdateRequest{
+		RequestId: req.RequestId,
+		Type:      pb.UpdateType_RETRACT,
+		ClientId:  req.ClientId,
+		ByAdmin:   s.IsAdmin(requestedBy, rbac.RetractAny),
+	}
+	if requestedBy != req.ClientId && !update.ByAdmin {
+		refuse(update)
+	}
+	return update
+}
+
+// RegisterUpdate is the update for a registration, carrying the configured
+// default credit limit in its amount so the backup applies the same one.
+func (s *State) RegisterUpdate(req *pb.RegisterRequest) *pb.UpdateRequest {
+	return &pb.UpdateRequest{
+		RequestId: req.RequestId,
+		Type:      pb.UpdateType_REGISTER,
+		Amount:    s.registry.DefaultCreditLimit(),
+		ClientId:  req.ClientId,
+	}
+}
+
+// ApproveUpdate is the update for an approval, carrying the credit limit
+// the admin set in its amount. Only admins may approve.
+func (s *State) ApproveUpdate(req *pb.ApproveRequest) *pb.UpdateRequest {
+	update := &pb.UpdateRequest{
+		RequestId: req.RequestId,
+		Type:      pb.UpdateType_APPROVE,
+		Amount:    req.CreditLimit,
+		ClientId:  req.ClientId,
+	}
+	if !s.IsAdmin(req.ApprovedBy, rbac.ApproveBidder) {
+		refuse(update)
+	}
+	return update
+}
+
+// CancelUpdate is the update for a cancellation. Only admins may cancel.
+func (s *State) CancelUpdate(req *pb.CancelRequest) *pb.UpdateRequest {
+	update := &pb.UpdateRequest{
+		RequestId: req.RequestId,
+		Type:      pb.UpdateType_CANCEL,
+	}
+	if !s.IsAdmin(req.CancelledBy, rbac.CancelAuction) {
+		refuse(update)
+	}
+	return update
+}
+
+// DueTransition returns the update for the scheduled transition that has
+// come due, if any. Each transition has a fixed request ID, and it is only
+// due while the auction has not recorded it, so a backup that applied it
+// never fires it again after failover.
+func (s *State) DueTransition() (*pb.UpdateRequest, bool) {
+	updateType, due := s.auction.DueTransition(s.clock.Now())
+	if !due {
+		return nil, false
+	}
+	return &pb.UpdateRequest{
+		RequestId: auction.TransitionRequestID(updateType),
+		Type:      updateType,
+	}, true
+}
+
+// refuse fails an update its caller was not authorized for, so it is
+// recorded without running.
+func refuse(update *pb.UpdateRequest) {
+	update.Outcome, update.Reason = pb.Outcome_EXCEPTION, pb.RejectReason_NOT_AUTHORIZED
+}
+
+// Processed returns the response recorded for requestID, if any.
+func (s *State) Processed(requestID string) (*pb.BidResponse, bool) {
+	response, exists := s.processed[requestID]
+	return response, exists
+}
+
+// Execute stamps update with the next sequence number and a timestamp,
+// runs it and fills in its outcome, and records and returns the response
+// for the client. An update that already failed, because its caller was
+// not authorized, is stamped without running. A bid that triggers the
+// buy-now is turned into a BUY_NOW update, which is how the backup must
+// apply it.
+func (s *State) Execute(update *pb.UpdateRequest) *pb.BidResponse {
+	stamp := s.nextStamp()
+	update.Sequence, update.Timestamp = stamp.Sequence, stamp.Time.UnixNano()
+
+	message := ""
+	if update.Outcome == pb.Outcome_SUCCESS {
+		isBid := update.Type == pb.UpdateType_BID
+		update.Outcome, update.Reason, message = s.run(update, stamp)
+		if isBid {
+			s.metrics.Bid(update.Outcome, update.Reason)
+		}
+	}
+	response := s.respond(update, message)
+	s.processed[update.RequestId] = response
+	return response
+}
+
+// Apply applies the next update the primary executed, with the primary's
+// stamp so sequence numbers and timestamps are identical on both replicas.
+//
+// Updates must be applied in sequence order, or a later write could be
+// applied on top of state missing an earlier one: an update that does not
+// follow the last one applied is refused with an OutOfOrderError. Each
+// successful update is run again and must succeed here too; one that does
+// not is refused, leaving the state as it was. Operations the primary
+// refused never changed its state, so they are not run.
+func (s *State) Apply(update *pb.UpdateRequest) (*pb.BidResponse, error) {
+	if update.Sequence != s.sequence+1 {
+		return nil, auction.OutOfOrderError(s.sequence + 1)
+	}
+
+	stamp := auction.Stamp{Sequence: update.Sequence, Time: time.Unix(0, update.Timestamp)}
+	if update.Outcome == pb.Outcome_SUCCESS {
+		if outcome, reason, _ := s.run(update, stamp); outcome != pb.Outcome_SUCCESS {
+			return nil, status.Errorf(codes.Aborted, "update %d diverged: it succeeded on the primary but was rejected here: %s", update.Sequence, auction.ReasonMessage(reason))
+		}
+	}
+	s.sequence, s.lastTimestamp = stamp.Sequence, stamp.Time
+
+	response := s.respond(update, "")
+	s.processed[update.RequestId] = response
+	return response, nil
+}
+
+// run runs update against the state at stamp and returns its outcome,
+// with a message when the rejection has more detail than its reason.
+func (s *State) run(update *pb.UpdateRequest, stamp auction.Stamp) (pb.Outcome, pb.RejectReason, string) {
+	defer s.registry.UpdateExposures(auction.DefaultAuctionID, s.auction.Allocations())
+
+	switch update.Type {
+	case pb.UpdateType_BID:
+		buyNowPrice := s.auction.BuyNowPrice()
+		if err := s.checkBidder(update.ClientId, update.Amount, update.Quantity); err != nil {
+			return pb.Outcome_FAIL, auction.RejectReasonOf(err), err.Error()
+		}
+		outcome, reason := s.auction.PlaceBid(update.ClientId, update.Amount, update.Quantity, update.Currency, stamp)
+		// A bid at or above the buy-now price closes the auction
+		if outcome == pb.Outcome_SUCCESS && s.auction.Buyer() == update.ClientId {
+			update.Type, update.Amount = pb.UpdateType_BUY_NOW, buyNowPrice
+		}
+		return outcome, reason, ""
+	case pb.UpdateType_BUY_NOW:
+		update.Amount = s.auction.BuyNowPrice()
+		if err := s.checkBidder(update.ClientId, update.Amount, s.auction.Quantity()); err != nil {
+			return pb.Outcome_FAIL, auction.RejectReasonOf(err), err.Error()
+		}
+		outcome, reason := s.auction.BuyNow(update.ClientId, stamp)
+		return outcome, reason, ""
+	case pb.UpdateType_RETRACT:
+		outcome, reason := s.auction.RetractBid(update.ClientId, update.ByAdmin, stamp.Time)
+		return outcome, reason, ""
+	case pb.UpdateType_REGISTER:
+		outcome, reason := s.registry.Register(update.ClientId, update.Amount)
+		return outcome, reason, ""
+	case pb.UpdateType_APPROVE:
+		outcome, reason := s.registry.Approve(update.ClientId, update.Amount)
+		return outcome, reason, ""
+	case pb.UpdateType_CANCEL:
+		outcome, reason := s.auction.Cancel(stamp.Time)
+		return outcome, reason, ""
+	case pb.UpdateType_OPEN:
+		s.auction.Open()
+	case pb.UpdateType_CLOSE:
+		s.auction.Close()
+	case pb.UpdateType_SETTLE:
+		s.auction.Settle(stamp.Time)
+	}
+	return pb.Outcome_SUCCESS, pb.RejectReason_REASON_NONE, ""
+}
+
+// respond builds the response to an executed or applied update. message,
+// if set, replaces the one its outcome implies.
+func (s *State) respond(update *pb.UpdateRequest, message string) *pb.BidResponse {
+	if message == "" {
+		message = s.message(update)
+	}
+	return &pb.BidResponse{
+		Outcome:    update.Outcome,
+		Message:    message,
+		Sequence:   update.Sequence,
+		Timestamp:  update.Timestamp,
+		Reason:     update.Reason,
+		HighestBid: s.auction.HighestBid(),
+	}
+}
+
+// message describes the outcome of update to the client.
+func (s *State) message(update *pb.UpdateRequest) string {
+	if update.Outcome != pb.Outcome_SUCCESS {
+		return auction.ReasonMessage(update.Reason)
+	}
+	switch update.Type {
+	case pb.UpdateType_BUY_NOW:
+		return fmt.Sprintf("bought now at %s, auction closed", auction.FormatAmount(update.Amount, s.auction.Currency()))
+	case pb.UpdateType_RETRACT:
+		return "bid retracted"
+	case pb.UpdateType_REGISTER:
+		if bidder, _ := s.registry.Bidder(update.ClientId); !bidder.Approved {
+			return "registered - awaiting approval"
+		}
+		return "registered"
+	case pb.UpdateType_APPROVE:
+		return "bidder approved"
+	case pb.UpdateType_CANCEL:
+		return "auction cancelled"
+	case pb.UpdateType_OPEN:
+		return "auction opened"
+	case pb.UpdateType_CLOSE:
+		return "auction closed"
+	case pb.UpdateType_SETTLE:
+		return "auction settled"
+	default:
+		return fmt.Sprintf("bid of %s accepted", auction.FormatAmount(update.Amount, s.auction.Currency()))
+	}
+}
+
+// checkBidder applies the registry's registration, approval and credit
+// checks to a bid of amount per unit for quantity units.
+func (s *State) checkBidder(clientID string, amount int64, quantity int32) error {
+	if quantity == 0 {
+		quantity = 1
+	}
+	if s.auction.TriggersBuyNow(amount, quantity) {
+		amount, quantity = s.auction.BuyNowPrice(), s.auction.Quantity()
+	}
+	total, ok := auction.BidTotal(amount, quantity)
+	if !ok {
+		// The auction rejects the amount or quantity as invalid
+		return nil
+	}
+	return s.registry.CheckBid(clientID, auction.DefaultAuctionID, total)
+}
+
+// nextStamp assigns the next replication sequence number and a timestamp
+// that never goes backwards, even if the wall clock does.
+func (s *State) nextStamp() auction.Stamp {
+	now := s.clock.Now()
+	if !now.After(s.lastTimestamp) {
+		now = s.lastTimestamp.Add(time.Nanosecond)
+	}
+	s.lastTimestamp = now
+	s.sequence++
+	return auction.Stamp{Sequence: s.sequence, Time: now}
+}
+
+// History returns every bid with the timestamp and sequence the primary
+// assigned to it.
+func (s *State) History() *pb.HistoryResponse {
+	return &pb.HistoryResponse{Bids: s.auction.History()}
+}
+
+// Result reports the state of the auction now.
+func (s *State) Result() *pb.ResultResponse {
+	a := s.auction
+	status, highestBid, winner := a.GetResult(s.clock.Now())
+	return &pb.ResultResponse{
+		Status:      status,
+		HighestBid:  highestBid,
+		Winner:      winner,
+		Allocations: a.Allocations(),
+		Quantity:    a.Quantity(),
+		Pricing:     a.Pricing(),
+		BuyNowPrice: a.BuyNowPrice(),
+		BoughtNow:   a.Buyer() != "",
+		Currency:    a.Currency(),
+		StartTime:   a.StartTime().UnixNano(),
+		EndTime:     a.EndTime().UnixNano(),
+	}
+}
@@ -0,0 +1,83 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/clock"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Faults are the chances that the network misbehaves on a replication call.
+// Whatever is left over is delivered and acknowledged normally.
+type Faults struct {
+	Drop    float64 // the update never arrives; the call fails
+	LoseAck float64 // the update arrives but the acknowledgement does not
+	Delay   float64 // the update arrives after the caller has timed out
+}
+
+// DefaultFaults is a network that misbehaves on about one call in four.
+var DefaultFaults = Faults{Drop: 0.1, LoseAck: 0.1, Delay: 0.05}
+
+// replicationTimeout matches the primary's wait for an acknowledgement, so
+// a delayed update lands after the primary has given up on it.
+const replicationTimeout = 2 * time.Second
+
+// network carries replication calls from the primary to the backup. Every
+// decision comes from the simulation's random source and delayed updates
+// are delivered by the fake clock, so a seed always plays out the same way.
+type network struct {
+	backup pb.ReplicationServiceServer
+	clock  clock.Clock
+	rand   *rand.Rand
+	faults Faults
+	trace  func(format string, args ...interface{})
+}
+
+func (n *network) ReplicateUpdate(ctx context.Context, in *pb.UpdateRequest, opts ...grpc.CallOption) (*pb.UpdateResponse, error) {
+	roll := n.rand.Float64()
+	switch {
+	case roll < n.faults.Drop:
+		n.trace("network drops update %s", in.RequestId)
+		return nil, status.Error(codes.Unavailable, "simulated: update dropped")
+	case roll < n.faults.Drop+n.faults.LoseAck:
+		n.trace("network loses ack for update %s", in.RequestId)
+		n.backup.ReplicateUpdate(context.Background(), in)
+		return nil, status.Error(codes.DeadlineExceeded, "simulated: acknowledgement lost")
+	case roll < n.faults.Drop+n.faults.LoseAck+n.faults.Delay:
+		delay := replicationTimeout + time.Duration(n.rand.Int63n(int64(3*time.Second)))
+		n.trace("network delays update %s by %v", in.RequestId, delay)
+		n.clock.AfterFunc(delay, func() {
+			n.trace("delayed update %s arrives", in.RequestId)
+			n.backup.ReplicateUpdate(context.Background(), in)
+		})
+		return nil, status.Error(codes.DeadlineExceeded, "simulated: update delayed")
+	}
+	return n.backup.ReplicateUpdate(ctx, in)
+}
+
+// Heartbeat is dropped like an update, but never delayed: a late heartbeat
+// carries no information.
+func (n *network) Heartbeat(ctx context.Context, in *pb.HeartbeatRequest, opts ...grpc.CallOption) (*pb.HeartbeatResponse, error) {
+	if n.rand.Float64() < n.faults.Drop {
+		return nil, status.Error(codes.Unavailable, "simulated: heartbeat dropped")
+	}
+	return n.backup.Heartbeat(ctx, in)
+}
+
+// TakeOver is dropped like a heartbeat.
+func (n *network) TakeOver(ctx context.Context, in *pb.TakeOverRequest, opts ...grpc.CallOption) (*pb.TakeOverResponse, error) {
+	if n.rand.Float64() < n.faults.Drop {
+		return nil, status.Error(codes.Unavailable, "simulated: take-over request dropped")
+	}
+	return n.backup.TakeOver(ctx, in)
+}
+
+func (f Faults) String() string {
+	return fmt.Sprintf("drop=%.2f lose-ack=%.2f delay=%.2f", f.Drop, f.LoseAck, f.Delay)
+}
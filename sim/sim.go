@@ -0,0 +1,246 @@
+// Package sim runs a primary and a backup against a fake clock and a
+// simulated replication network, driven entirely by one random seed. A run
+// places bids, moves time forward, loses, repeats and delays replication
+// traffic and may crash the primary, checking after every step that no
+// acknowledged bid has been lost. The same seed always replays the same
+// run, so a failure can be reproduced and debugged step by step:
+//
+//	report := sim.Run(42, sim.Options{})
+//	if report.Err != nil {
+//		fmt.Println(strings.Join(report.Trace, "\n"))
+//	}
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/auction"
+	"github.com/joachimblom-hanssen/Distributed_5/backup"
+	"github.com/joachimblom-hanssen/Distributed_5/clock"
+	"github.com/joachimblom-hanssen/Distributed_5/primary"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Epoch is where the fake clock starts. Runs do not depend on the wall
+// clock.
+var Epoch = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// Options configures a run.
+type Options struct {
+	Steps  int // defaults to 200
+	Config auction.Config
+	Faults *Faults // nil means DefaultFaults
+	// CrashChance is the chance per step that the primary crashes, if it
+	// has not already. Defaults to 0.01; negative never crashes it.
+	CrashChance float64
+}
+
+// Report is the outcome of one run.
+type Report struct {
+	Seed  int64
+	Steps int   // steps taken before the run ended
+	Err   error // the first invariant violated, or nil
+	Trace []string
+}
+
+var bidders = []string{"alice", "bob", "carol", "dave"}
+
+// maxAttempts is how often a simulated client sends a request before
+// giving up on it, as the real client does.
+const maxAttempts = 3
+
+// Run plays one simulation for seed.
+func Run(seed int64, opts Options) *Report {
+	if opts.Steps <= 0 {
+		opts.Steps = 200
+	}
+	if opts.Faults == nil {
+		opts.Faults = &DefaultFaults
+	}
+	if opts.CrashChance == 0 {
+		opts.CrashChance = 0.01
+	}
+
+	r := &run{
+		report: &Report{Seed: seed},
+		rand:   rand.New(rand.NewSource(seed)),
+		clock:  clock.NewFake(Epoch),
+	}
+	r.backup = backup.NewBackupServer(backup.Options{
+		StartTime: Epoch,
+		Config:    opts.Config,
+		Clock:     r.clock,
+	})
+	defer r.backup.Close()
+
+	var err error
+	r.primary, err = primary.NewPrimaryServer(primary.Options{
+		StartTime: Epoch,
+		Config:    opts.Config,
+		Clock:     r.clock,
+		Replication: &network{
+			backup: r.backup,
+			clock:  r.clock,
+			rand:   r.rand,
+			faults: *opts.Faults,
+			trace:  r.tracef,
+		},
+	})
+	if err != nil {
+		r.report.Err = err
+		return r.report
+	}
+	defer r.crashPrimary()
+
+	for r.report.Steps < opts.Steps {
+		r.report.Steps++
+		r.step(opts)
+		if err := r.check(); err != nil {
+			r.report.Err = fmt.Errorf("step %d: %w", r.report.Steps, err)
+			r.tracef("VIOLATION: %v", err)
+			break
+		}
+	}
+	return r.report
+}
+
+type run struct {
+	report   *Report
+	rand     *rand.Rand
+	clock    *clock.Fake
+	primary  *primary.PrimaryServer // nil once crashed
+	backup   *backup.BackupServer
+	requests int
+	// acked holds every successful bid a client was told about.
+	acked []ackedBid
+	// highestAcked is the highest amount of an acknowledged bid.
+	highestAcked int64
+}
+
+type ackedBid struct {
+	requestID string
+	response  *pb.BidResponse
+}
+
+func (r *run) tracef(format string, args ...interface{}) {
+	elapsed := r.clock.Now().Sub(Epoch)
+	r.report.Trace = append(r.report.Trace, fmt.Sprintf("%8v  %s", elapsed, fmt.Sprintf(format, args...)))
+}
+
+// step takes one random action.
+func (r *run) step(opts Options) {
+	roll := r.rand.Float64()
+	switch {
+	case r.primary != nil && roll < opts.CrashChance:
+		r.tracef("primary crashes")
+		r.crashPrimary()
+	case roll < 0.65:
+		r.bid()
+	case roll < 0.75:
+		r.result()
+	default:
+		d := time.Duration(r.rand.Int63n(int64(1500 * time.Millisecond)))
+		r.tracef("advance %v", d)
+		r.clock.Advance(d)
+	}
+}
+
+func (r *run) crashPrimary() {
+	if r.primary != nil {
+		r.primary.Close()
+		r.primary = nil
+	}
+}
+
+// server is the node clients talk to: the primary while it is up, then the
+// backup.
+func (r *run) server() pb.AuctionServiceServer {
+	if r.primary != nil {
+		return r.primary
+	}
+	return r.backup
+}
+
+// bid places a bid near the highest one and retries it with the same
+// request ID on a retryable error, waiting as the server asks.
+func (r *run) bid() {
+	r.requests++
+	req := &pb.BidRequest{
+		ClientId:  bidders[r.rand.Intn(len(bidders))],
+		Amount:    r.highestAcked + r.rand.Int63n(30) - 5,
+		Quantity:  1,
+		RequestId: fmt.Sprintf("sim-%d", r.requests),
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		resp, err := r.server().Bid(context.Background(), req)
+		if err == nil {
+			r.tracef("%s: %s bids %d: %s %s", req.RequestId, req.ClientId, req.Amount, resp.Outcome, resp.Reason)
+			if resp.Outcome == pb.Outcome_SUCCESS {
+				r.acked = append(r.acked, ackedBid{req.RequestId, resp})
+				if req.Amount > r.highestAcked {
+					r.highestAcked = req.Amount
+				}
+			}
+			return
+		}
+
+		r.tracef("%s: %s bids %d: %s (attempt %d)", req.RequestId, req.ClientId, req.Amount, status.Code(err), attempt)
+		delay, ok := auction.RetryAfter(err)
+		if !ok || status.Code(err) == codes.InvalidArgument {
+			return
+		}
+		r.clock.Advance(delay)
+	}
+}
+
+func (r *run) result() {
+	resp, err := r.server().Result(context.Background(), &pb.ResultRequest{})
+	if err != nil {
+		r.tracef("result: %v", err)
+		return
+	}
+	r.tracef("result: %s, highest %d by %q", resp.Status, resp.HighestBid, resp.Winner)
+}
+
+// check verifies the invariants against the node clients are talking to:
+// every acknowledged bid is in its history and its highest bid is at least
+// the highest acknowledged one. Once the primary has crashed this is the
+// backup, so it checks that acknowledged bids survived the failover.
+func (r *run) check() error {
+	ctx := context.Background()
+	history, err := r.server().History(ctx, &pb.HistoryRequest{})
+	if err != nil {
+		return fmt.Errorf("history: %v", err)
+	}
+	recorded := make(map[uint64]*pb.BidRecord)
+	for _, record := range history.Bids {
+		recorded[record.Sequence] = record
+	}
+	for _, ack := range r.acked {
+		record, ok := recorded[ack.response.Sequence]
+		if !ok || record.Timestamp != ack.response.Timestamp {
+			return fmt.Errorf("acknowledged bid %s (sequence %d) is missing from the history", ack.requestID, ack.response.Sequence)
+		}
+	}
+
+	// A node that cannot confirm its result is current, e.g. a backup that
+	// has not taken over yet, refuses to answer rather than show a stale
+	// one, so there is nothing to check.
+	result, err := r.server().Result(ctx, &pb.ResultRequest{})
+	if _, retryable := auction.RetryAfter(err); retryable {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("result: %v", err)
+	}
+	if result.HighestBid < r.highestAcked {
+		return fmt.Errorf("highest bid is %d, but a bid of %d was acknowledged", result.HighestBid, r.highestAcked)
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+// Package tlsconfig secures the gRPC connections of the servers and
+// clients with TLS, from PEM files named on the command line.
+//
+// A server serves TLS on its one port and verifies a client certificate
+// against the CA whenever the client presents one. ReplicationService
+// requires one, so only cluster members, holding certificates signed by the
+// cluster CA, can send ReplicateUpdate, Heartbeat or TakeOver; clients of
+// AuctionService only need the CA to verify the server.
+//
+// A config being watched checks its files every ReloadInterval and reads
+// them again once they change, so certificates can be rotated without a
+// restart. Connections already set up keep the certificates they were set
+// up with.
+//
+// Replication is authenticated only by the client certificate, so a server
+// refuses to start without TLS unless it is told to run insecure.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/clock"
+	pb "github.com/joachimblom-hanssen/Distributed_5/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ReloadInterval is how often a watched config checks its files for
+// changes.
+const ReloadInterval = 10 * time.Second
+
+// Files names the PEM files a node's TLS configuration is read from: its
+// own certificate and key, and the CA that signed the other end's.
+type Files struct {
+	Cert string
+	Key  string
+	CA   string
+}
+
+// Config is a TLS configuration kept in step with its files. It is safe
+// for concurrent use.
+type Config struct {
+	files    Files
+	mutex    sync.Mutex
+	modTimes [3]time.Time
+	cert     *tls.Certificate // nil for a client without a certificate
+	pool     *x509.CertPool   // nil means the system roots
+}
+
+// FromFlags reads the configuration from files, or returns nil if no file
+// is named, which leaves TLS off. A server needs a certificate and key,
+// and a CA to verify cluster members by; a client needs a certificate and
+// key only to replicate, and without a CA trusts the system roots.
+func FromFlags(files Files, server bool) (*Config, error) {
+	if files == (Files{}) {
+		return nil, nil
+	}
+	if (files.Cert == "") != (files.Key == "") {
+		return nil, fmt.Errorf("a TLS certificate and key must be given together")
+	}
+	if server && (files.Cert == "" || files.CA == "") {
+		return nil, fmt.Errorf("a server needs a TLS certificate, key and CA")
+	}
+
+	c := &Config{files: files}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ServerFromFlags is FromFlags for a server. Without TLS anyone who can
+// reach the port can replicate to the server, so unless insecure is set a
+// server must be given its certificate, key and CA.
+func ServerFromFlags(files Files, insecure bool) (*Config, error) {
+	switch {
+	case insecure && files != (Files{}):
+		return nil, fmt.Errorf("-insecure turns TLS off and cannot be given with TLS files")
+	case insecure:
+		return nil, nil
+	case files == (Files{}):
+		return nil, fmt.Errorf("replication needs mutual TLS: give -tls-cert, -tls-key and -tls-ca, or -insecure to serve without TLS")
+	}
+	return FromFlags(files, true)
+}
+
+// Watch checks the files every interval on clk and reads them again once
+// they change, until stop is called. Files that fail to load, e.g. a
+// certificate written before its key, leave the previous ones in use. A nil
+// config has nothing to watch.
+func (c *Config) Watch(clk clock.Clock, interval time.Duration) (stop func()) {
+	if c == nil {
+		return func() {}
+	}
+	return clk.Every(interval, func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		if err := c.reload(); err != nil {
+			slog.Warn("Failed to reload TLS certificates, keeping the previous ones", "err", err)
+		}
+	})
+}
+
+// reload reads the files again if any of them changed since they were
+// last read. Must be called with c.mutex held.
+func (c *Config) reload() error {
+	var modTimes [3]time.Time
+	for i, name := range []string{c.files.Cert, c.files.Key, c.files.CA} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if modTimes == c.modTimes {
+		return nil
+	}
+
+	var cert *tls.Certificate
+	if c.files.Cert != "" {
+		pair, err := tls.LoadX509KeyPair(c.files.Cert, c.files.Key)
+		if err != nil {
+			return err
+		}
+		cert = &pair
+	}
+	var pool *x509.CertPool
+	if c.files.CA != "" {
+		pem, err := os.ReadFile(c.files.CA)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", c.files.CA)
+		}
+	}
+
+	if !c.modTimes[0].IsZero() || !c.modTimes[2].IsZero() {
+		slog.Info("Reloaded TLS certificates", "cert", c.files.Cert, "ca", c.files.CA)
+	}
+	c.cert, c.pool, c.modTimes = cert, pool, modTimes
+	return nil
+}
+
+// current returns the certificate and CA pool last read.
+func (c *Config) current() (*tls.Certificate, *x509.CertPool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cert, c.pool
+}
+
+// ServerOptions serve TLS with c, if non-nil, on a gRPC server and refuse
+// ReplicationService calls from clients without a verified certificate.
+func ServerOptions(c *Config) []grpc.ServerOption {
+	if c == nil {
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.Creds(&reloading{config: c, server: true}),
+		grpc.ChainUnaryInterceptor(requireClientCert(pb.ReplicationService_ServiceDesc.ServiceName)),
+	}
+}
+
+// DialOptions dial with TLS using c, if non-nil, in place of an insecure
+// connection. They must come after grpc.WithInsecure to replace it.
+func DialOptions(c *Config) []grpc.DialOption {
+	if c == nil {
+		return nil
+	}
+	return []grpc.DialOption{grpc.WithTransportCredentials(&reloading{config: c})}
+}
+
+// requireClientCert refuses calls to service from clients that presented
+// no certificate signed by the CA.
+func requireClientCert(service string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if strings.HasPrefix(info.FullMethod, "/"+service+"/") && !verifiedClient(ctx) {
+			return nil, status.Errorf(codes.Unauthenticated, "%s requires a client certificate signed by the cluster CA", service)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func verifiedClient(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	return ok && len(info.State.VerifiedChains) > 0
+}
+
+// reloading is TLS transport credentials built afresh for every handshake
+// from the certificates the config last read.
+type reloading struct {
+	config     *Config
+	server     bool
+	serverName string
+}
+
+func (r *reloading) credentials() credentials.TransportCredentials {
+	cert, pool := r.config.current()
+	config := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: r.serverName}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{*cert}
+	}
+	if r.server {
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	} else {
+		config.RootCAs = pool
+	}
+	return credentials.NewTLS(config)
+}
+
+func (r *reloading) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return r.credentials().ClientHandshake(ctx, authority, conn)
+}
+
+func (r *reloading) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return r.credentials().ServerHandshake(conn)
+}
+
+func (r *reloading) Info() credentials.ProtocolInfo {
+	return credentials.NewTLS(&tls.Config{ServerName: r.serverName}).Info()
+}
+
+func (r *reloading) Clone() credentials.TransportCredentials {
+	clone := *r
+	return &clone
+}
+
+func (r *reloading) OverrideServerName(name string) error {
+	r.serverName = name
+	return nil
+}
@@ -0,0 +1,154 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joachimblom-hanssen/Distributed_5/clock"
+)
+
+// writeCert writes a fresh self-signed certificate named name, with its
+// key, to the files, which double as the CA.
+func writeCert(t *testing.T, files Files, name string, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []struct {
+		path, blockType string
+		der             []byte
+	}{
+		{files.Cert, "CERTIFICATE", cert},
+		{files.Key, "PRIVATE KEY", der},
+		{files.CA, "CERTIFICATE", cert},
+	} {
+		if err := os.WriteFile(file.path, pem.EncodeToMemory(&pem.Block{Type: file.blockType, Bytes: file.der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file.path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newFiles(t *testing.T) Files {
+	dir := t.TempDir()
+	return Files{
+		Cert: filepath.Join(dir, "node.pem"),
+		Key:  filepath.Join(dir, "node.key"),
+		CA:   filepath.Join(dir, "ca.pem"),
+	}
+}
+
+func commonName(t *testing.T, c *Config) string {
+	t.Helper()
+	cert, _ := c.current()
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
+
+func TestServerFromFlags(t *testing.T) {
+	files := newFiles(t)
+	writeCert(t, files, "node", time.Now())
+
+	tests := []struct {
+		name     string
+		files    Files
+		insecure bool
+		tls      bool
+		ok       bool
+	}{
+		{"TLS", files, false, true, true},
+		{"insecure", Files{}, true, false, true},
+		{"neither", Files{}, false, false, false},
+		{"both", files, true, false, false},
+		{"no CA", Files{Cert: files.Cert, Key: files.Key}, false, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := ServerFromFlags(test.files, test.insecure)
+			if (err == nil) != test.ok {
+				t.Fatalf("ServerFromFlags = %v, want ok %v", err, test.ok)
+			}
+			if (c != nil) != test.tls {
+				t.Errorf("TLS on = %v, want %v", c != nil, test.tls)
+			}
+		})
+	}
+}
+
+func TestWatchReloadsChangedFiles(t *testing.T) {
+	files := newFiles(t)
+	modTime := time.Now().Add(-time.Hour)
+	writeCert(t, files, "first", modTime)
+
+	c, err := FromFlags(files, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFake(time.Now())
+	stop := c.Watch(clk, ReloadInterval)
+	defer stop()
+
+	// Rotated files are not read until the next check
+	writeCert(t, files, "second", modTime.Add(time.Minute))
+	if got := commonName(t, c); got != "first" {
+		t.Errorf("certificate %q before the check, want first", got)
+	}
+	clk.Advance(ReloadInterval)
+	if got := commonName(t, c); got != "second" {
+		t.Errorf("certificate %q after the check, want second", got)
+	}
+
+	// Files that fail to load leave the previous ones in use
+	if err := os.WriteFile(files.Key, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(ReloadInterval)
+	if got := commonName(t, c); got != "second" {
+		t.Errorf("certificate %q after a bad key, want second", got)
+	}
+
+	stop()
+	writeCert(t, files, "third", modTime.Add(2*time.Minute))
+	clk.Advance(ReloadInterval)
+	if got := commonName(t, c); got != "second" {
+		t.Errorf("certificate %q after stop, want second", got)
+	}
+}
+
+func TestWatchNilConfig(t *testing.T) {
+	var c *Config
+	c.Watch(clock.NewFake(time.Now()), ReloadInterval)()
+}
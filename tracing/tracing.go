@@ -0,0 +1,165 @@
+// Package tracing follows a request from the client through the primary to
+// ReplicateUpdate on the backup with OpenTelemetry.
+//
+// The trace context travels in gRPC metadata (W3C traceparent), so a bid
+// is one trace: the client's PlaceBid span, the primary's server span with
+// a span per stage of handling the bid, and the backup's ReplicateUpdate
+// span under the replication stage. Spans go to an OTLP collector or to a
+// file of JSON lines.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+const instrumentationName = "github.com/joachimblom-hanssen/Distributed_5"
+
+// DefaultCollector is where "otlp" sends spans.
+const DefaultCollector = "localhost:4317"
+
+// Setup makes service trace to exporter: "otlp" for a collector at
+// DefaultCollector, "otlp:<host:port>" for another one, or "file:<path>"
+// to append spans to path as JSON. An empty exporter records nothing but
+// still passes on the trace context of incoming calls.
+//
+// The returned function flushes buffered spans and stops the exporter.
+func Setup(service, exporter string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if exporter == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	spanExporter, err := newExporter(exporter)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func newExporter(exporter string) (sdktrace.SpanExporter, error) {
+	kind, argument, _ := strings.Cut(exporter, ":")
+	switch kind {
+	case "otlp":
+		endpoint := argument
+		if endpoint == "" {
+			endpoint = DefaultCollector
+		}
+		return otlptracegrpc.New(context.Background(),
+			otlptracegrpc.WithEndpoint(endpoint),
+			otlptracegrpc.WithInsecure(),
+		)
+	case "file":
+		if argument == "" {
+			return nil, fmt.Errorf("file exporter needs a path, e.g. file:traces.json")
+		}
+		f, err := os.OpenFile(argument, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(f))
+	}
+	return nil, fmt.Errorf("unknown trace exporter %q (want otlp, otlp:<host:port> or file:<path>)", exporter)
+}
+
+// ServerOptions start a span for every call a gRPC server receives, as a
+// child of the caller's span.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{grpc.StatsHandler(skipHeartbeats{otelgrpc.NewServerHandler()})}
+}
+
+// DialOptions start a span for every call made on a gRPC client
+// connection and send its context along with the call.
+func DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{grpc.WithStatsHandler(skipHeartbeats{otelgrpc.NewClientHandler()})}
+}
+
+// skipHeartbeats leaves heartbeats, one every two seconds, out of the
+// traces.
+type skipHeartbeats struct {
+	stats.Handler
+}
+
+type heartbeatKey struct{}
+
+func (h skipHeartbeats) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	if strings.HasSuffix(info.FullMethodName, "/Heartbeat") {
+		return context.WithValue(ctx, heartbeatKey{}, true)
+	}
+	return h.Handler.TagRPC(ctx, info)
+}
+
+func (h skipHeartbeats) HandleRPC(ctx context.Context, rpcStats stats.RPCStats) {
+	if ctx.Value(heartbeatKey{}) != nil {
+		return
+	}
+	h.Handler.HandleRPC(ctx, rpcStats)
+}
+
+// Start starts a span named name as a child of the span in ctx.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// Fail marks span as failed with err.
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Stages traces the stages of handling one request, such as the dedup
+// check, execution, replication and response, as consecutive spans under
+// the request's span.
+type Stages struct {
+	ctx  context.Context
+	span trace.Span
+}
+
+// NewStages starts the first stage, first, of the request in ctx.
+func NewStages(ctx context.Context, first string) *Stages {
+	s := &Stages{ctx: ctx}
+	s.Next(first)
+	return s
+}
+
+// Next ends the current stage and starts the next one, returning its
+// context for the calls the stage makes.
+func (s *Stages) Next(name string) context.Context {
+	s.End()
+	ctx, span := Start(s.ctx, name)
+	s.span = span
+	return ctx
+}
+
+// Fail marks the current stage as failed with err.
+func (s *Stages) Fail(err error) {
+	Fail(s.span, err)
+}
+
+// End ends the current stage.
+func (s *Stages) End() {
+	if s.span != nil {
+		s.span.End()
+	}
+}